@@ -46,6 +46,11 @@ var commands = cli.Commands{
 		Usage:  "Populates missing metadata for scenes.",
 		Action: landsatPopulateMetadata,
 	},
+	cli.Command{
+		Name:   "encrypt_keystore",
+		Usage:  "Encrypt a Planet key store read from stdin with BF_PLANET_KEYS_SECRET, writing it to stdout",
+		Action: encryptKeyStoreAction,
+	},
 	cli.Command{
 		Name:    "migrate",
 		Aliases: []string{"m"},
@@ -53,7 +53,11 @@ func TestMain(m *testing.M) {
 	mockAWSServer := httptest.NewServer(mockAWSHandler{})
 	defer mockAWSServer.Close()
 	os.Setenv("LANDSAT_HOST", mockAWSServer.URL)
-	getDbConnectionFunc = func(ctx util.LogContext) (*sql.DB, error) { return nil, nil }
+	// A database that is never reached; the activation tracker reads from it
+	// when it starts
+	getDbConnectionFunc = func(ctx util.LogContext) (*sql.DB, error) {
+		return sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	}
 	code := m.Run()
 	os.Exit(code)
 }
@@ -0,0 +1,43 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/venicegeo/bf-ia-broker/planet"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+func encryptKeyStoreAction(*cli.Context) {
+	plaintext, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal("Could not read the key store: ", err)
+	}
+	if _, err = planet.NewKeyStore(plaintext); err != nil {
+		log.Fatal(err)
+	}
+	secret, err := planet.KeyStoreSecret()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ciphertext, err := planet.EncryptKeyStore(plaintext, secret)
+	if err != nil {
+		log.Fatal("Could not encrypt the key store: ", err)
+	}
+	os.Stdout.Write(append(ciphertext, '\n'))
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/venicegeo/bf-ia-broker/federated"
 	landsatlocalindex "github.com/venicegeo/bf-ia-broker/landsat_localindex"
 	landsat "github.com/venicegeo/bf-ia-broker/landsat_planet"
 	"github.com/venicegeo/bf-ia-broker/planet"
@@ -37,16 +38,93 @@ func getPortStr() string {
 }
 
 func createRouter(ctx util.LogContext) (*mux.Router, error) {
+	if keyStore, err := planet.LoadKeyStore(); err == nil {
+		planet.SetKeyStore(keyStore)
+	} else {
+		return nil, err
+	}
+
 	router := mux.NewRouter()
 	router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
 		writer.Write([]byte("OK"))
 	})
+	router.Handle("/planet/discover", planet.NewDiscoverHandler())
 	router.Handle("/planet/discover/{itemType}", planet.NewDiscoverHandler())
+	router.Handle("/planet/itemtypes", planet.NewItemTypesHandler())
+	router.Handle("/planet/order", planet.NewOrderHandler())
+	router.Handle("/planet/order/{id}", planet.NewOrderStatusHandler())
+	router.Handle("/planet/stats", planet.NewStatsHandler())
+	router.Handle("/planet/stats/{itemType}", planet.NewStatsHandler())
+	router.Handle("/planet/opensearch/{itemType}", planet.NewOpenSearchHandler())
+	router.Handle("/planet/preview/{itemType}/{id}", planet.NewPreviewHandler())
+	router.Handle("/planet/tiles/{itemType}/{id}/{z}/{x}/{y}.png", planet.NewTileHandler())
 	router.Handle("/planet/{itemType}/{id}", planet.NewMetadataHandler())
-	router.Handle("/planet/activate/{itemType}/{id}", planet.NewActivateHandler())
+
+	if database, err := getDbConnectionFunc(ctx); err == nil {
+		switch cache := util.GetMetadataCache(); cache {
+		case "memory":
+			// The default, already in place
+		case "postgres":
+			planet.SetMetadataCache(planet.NewSQLMetadataCache(database), time.Duration(util.GetMetadataCacheTTL())*time.Second)
+		default:
+			return nil, fmt.Errorf("Unknown %v value of %v; use memory or postgres", util.BF_METADATA_CACHE, cache)
+		}
+		activationTracker := planet.NewActivationTracker(planet.NewSQLActivationStore(database))
+		go activationTracker.Run(nil)
+		activateHandler := planet.NewActivateHandler()
+		activateHandler.Tracker = activationTracker
+		router.Handle("/planet/activate/{itemType}/{id}", activateHandler)
+		router.Handle("/planet/activation/{itemType}/{id}", planet.NewActivationStatusHandler(activationTracker))
+	} else {
+		return nil, err
+	}
 
 	if landsatLocalDiscoverHandler, err := landsatlocalindex.NewDiscoverHandler(getDbConnectionFunc); err == nil {
 		router.Handle("/localindex/discover/landsat_pds", landsatLocalDiscoverHandler)
+		router.Handle("/discover", federated.NewDiscoverHandler(
+			federated.Provider{Name: "localindex", Search: landsatLocalDiscoverHandler.FederatedSearch},
+			federated.Provider{Name: "planet", Search: planet.NewDiscoverHandler().FederatedSearch},
+		))
+	} else {
+		return nil, err
+	}
+
+	if landsatLocalStatsHandler, err := landsatlocalindex.NewStatsHandler(getDbConnectionFunc); err == nil {
+		router.Handle("/localindex/stats/landsat_pds", landsatLocalStatsHandler)
+	} else {
+		return nil, err
+	}
+
+	if landsatLocalWRS2Handler, err := landsatlocalindex.NewWRS2Handler(getDbConnectionFunc); err == nil {
+		router.Handle("/localindex/wrs2", landsatLocalWRS2Handler)
+	} else {
+		return nil, err
+	}
+
+	router.Handle("/stac", landsatlocalindex.NewSTACCatalogHandler())
+	router.Handle("/stac/collections", landsatlocalindex.NewSTACCollectionsHandler())
+	router.Handle("/stac/collections/{collectionId}", landsatlocalindex.NewSTACCollectionsHandler())
+	if stacItemsHandler, err := landsatlocalindex.NewSTACItemsHandler(getDbConnectionFunc); err == nil {
+		router.Handle("/stac/collections/{collectionId}/items", stacItemsHandler)
+		router.Handle("/stac/collections/{collectionId}/items/{itemId}", stacItemsHandler)
+	} else {
+		return nil, err
+	}
+	if stacSearchHandler, err := landsatlocalindex.NewSTACSearchHandler(getDbConnectionFunc); err == nil {
+		router.Handle("/stac/search", stacSearchHandler)
+	} else {
+		return nil, err
+	}
+
+	router.Handle("/localindex/opensearch/landsat_pds", landsatlocalindex.NewOpenSearchHandler())
+
+	router.Handle("/ogcapi", landsatlocalindex.NewOGCLandingPageHandler())
+	router.Handle("/ogcapi/conformance", landsatlocalindex.NewOGCConformanceHandler())
+	router.Handle("/ogcapi/collections", landsatlocalindex.NewOGCCollectionsHandler())
+	router.Handle("/ogcapi/collections/{collectionId}", landsatlocalindex.NewOGCCollectionsHandler())
+	if ogcItemsHandler, err := landsatlocalindex.NewOGCItemsHandler(getDbConnectionFunc); err == nil {
+		router.Handle("/ogcapi/collections/{collectionId}/items", ogcItemsHandler)
+		router.Handle("/ogcapi/collections/{collectionId}/items/{featureId}", ogcItemsHandler)
 	} else {
 		return nil, err
 	}
@@ -0,0 +1,191 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federated
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	landsat "github.com/venicegeo/bf-ia-broker/landsat_planet"
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// SearchFunc searches a provider with a request to /discover. The request is
+// the provider's own copy, with its body already read and size-capped, and
+// the writer discards whatever is written to it; failures the request or the
+// provider's upstream is at fault for are util.HTTPErr.
+type SearchFunc func(http.ResponseWriter, *http.Request) (*model.MultiBrokerResult, error)
+
+// Provider is a source of scenes for federated discovery
+type Provider struct {
+	Name   string
+	Search SearchFunc
+}
+
+// ProviderStatus is how a provider's search went: how many scenes it found,
+// or why it failed
+type ProviderStatus struct {
+	Count  int    `json:"count"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// providerResults are the normalized results of one provider
+type providerResults struct {
+	results []model.BasicBrokerResult
+	status  ProviderStatus
+}
+
+// FeatureCollection is the response of federated discovery: the scenes found,
+// and how each provider's search went
+type FeatureCollection struct {
+	*geojson.FeatureCollection
+	Providers map[string]ProviderStatus `json:"providers"`
+}
+
+// scene is a scene as found by one or more providers
+type scene struct {
+	model.BasicBrokerResult
+	// ids are the scene's ID with each provider that found it
+	ids map[string]string
+}
+
+// pagingParams are the parameters of one provider's paging, which mean
+// nothing to the others
+var pagingParams = []string{"cursor", "page_size"}
+
+// discardWriter is the response writer of a provider's search, so providers
+// never write to the real response, least of all at the same time
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(bytes []byte) (int, error) {
+	return len(bytes), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+// search searches the providers concurrently, each with its own copy of the
+// request; body is the request's body, already read
+func search(r *http.Request, body []byte, providers []Provider) []providerResults {
+	results := make([]providerResults, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider Provider, request *http.Request) {
+			defer wg.Done()
+			results[i] = searchProvider(&discardWriter{header: http.Header{}}, request, provider)
+		}(i, provider, providerRequest(r, body))
+	}
+	wg.Wait()
+	return results
+}
+
+func searchProvider(w http.ResponseWriter, r *http.Request, provider Provider) providerResults {
+	multiResult, err := provider.Search(w, r)
+	var basicResults []model.BasicBrokerResult
+	if err == nil {
+		basicResults, err = multiResult.BasicResults()
+	}
+	if err != nil {
+		status := ProviderStatus{Status: http.StatusBadGateway, Error: err.Error()}
+		if herr, ok := err.(util.HTTPErr); ok {
+			status.Status, status.Error = herr.Status, herr.Message
+		}
+		return providerResults{status: status}
+	}
+	return providerResults{results: basicResults, status: ProviderStatus{Count: len(basicResults)}}
+}
+
+// merge combines the results of the providers, which are given in order of
+// preference. A Landsat 8 scene found by more than one provider, whatever its
+// ID with each, is a single scene with the fields of the preferred provider.
+func merge(providers []Provider, results []providerResults) []*scene {
+	scenes := []*scene{}
+	byKey := map[string]*scene{}
+	for i, provider := range providers {
+		for _, result := range results[i].results {
+			key, ok := landsat.SceneKey(result.ID)
+			if !ok {
+				key = provider.Name + "/" + result.ID
+			}
+			if existing, found := byKey[key]; found {
+				existing.ids[provider.Name] = result.ID
+				continue
+			}
+			s := &scene{BasicBrokerResult: result, ids: map[string]string{provider.Name: result.ID}}
+			byKey[key] = s
+			scenes = append(scenes, s)
+		}
+	}
+	return scenes
+}
+
+// sortScenes sorts scenes in place
+func sortScenes(scenes []*scene, searchSort model.SearchSort) {
+	sort.SliceStable(scenes, func(i, j int) bool {
+		return model.LessSearchResult(
+			model.BrokerSearchResult{BasicBrokerResult: scenes[i].BasicBrokerResult},
+			model.BrokerSearchResult{BasicBrokerResult: scenes[j].BasicBrokerResult},
+			searchSort)
+	})
+}
+
+// featureCollection writes the scenes as GeoJSON; each feature's providers
+// property gives its ID with each provider that found it
+func featureCollection(scenes []*scene) (*geojson.FeatureCollection, error) {
+	features := make([]*geojson.Feature, len(scenes))
+	for i, s := range scenes {
+		feature, err := s.GeoJSONFeature()
+		if err != nil {
+			return nil, err
+		}
+		feature.Properties["providers"] = s.ids
+		features[i] = feature
+	}
+	return geojson.NewFeatureCollection(features), nil
+}
+
+// providerRequest copies a request for a provider, with its own URL, body and
+// form, so providers may read and change them concurrently; paging
+// parameters are dropped
+func providerRequest(r *http.Request, body []byte) *http.Request {
+	request := r.WithContext(r.Context())
+	providerURL := *r.URL
+	query := providerURL.Query()
+	request.Form = url.Values{}
+	for name, values := range r.Form {
+		request.Form[name] = values
+	}
+	for _, name := range pagingParams {
+		query.Del(name)
+		request.Form.Del(name)
+	}
+	providerURL.RawQuery = query.Encode()
+	request.URL = &providerURL
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return request
+}
@@ -0,0 +1,193 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federated
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// maxGeometryBodySize caps the size of an AOI geometry POSTed to discovery
+const maxGeometryBodySize = 4 << 20
+
+// maxSearchLimit is the most scenes federated discovery may be asked for
+const maxSearchLimit = 2500
+
+const invalidProvider = "The providers value of %v is invalid; it must list one or more of %v."
+
+// defaultSearchSort puts the most recent scenes first
+var defaultSearchSort = model.SearchSort{Field: model.SortByAcquiredDate, Descending: true}
+
+// DiscoverHandler is a handler for /discover
+// @Title federatedDiscoverHandler
+// @Description Discovers scenes from several providers at once, the local index and Planet Labs, merging the scenes each finds; POST a GeoJSON Polygon or MultiPolygon to search an arbitrary AOI
+// @Accept  plain,json
+// @Param   providers       query   string  false        "Comma-separated providers to search, localindex and planet; defaults to all"
+// @Param   Authorization   header  string  false        "For planet: Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   itemTypes       query   string  false        "For planet: comma-separated Planet Labs Item Types; defaults to landsat"
+// @Param   bbox            query   string  false        "The bounding box, as a GeoJSON Bounding box (x1,y1,x2,y2)"
+// @Param   cloudCover      query   string  false        "The maximum cloud cover, as a percentage (0-100)"
+// @Param   acquiredDate    query   string  false        "The minimum (earliest) acquired date, as RFC 3339"
+// @Param   maxAcquiredDate query   string  false        "The maximum acquired date, as RFC 3339"
+// @Param   sort            query   string  false        "Sort by acquiredDate, cloudCover or aoiCoverage; prefix with - for descending; defaults to -acquiredDate. Each provider searches in this order; planet sorts only by acquiredDate"
+// @Param   limit           query   int     false        "The maximum number of scenes to return, and to ask each provider for (1-2500)"
+// @Param   geometry        body    string  false        "POST only: the AOI, as a GeoJSON Polygon or MultiPolygon (overrides bbox)"
+// @Success 200 {object}  federated.FeatureCollection
+// @Failure 400 {object}  string
+// @Router /discover [get,post]
+type DiscoverHandler struct {
+	Context util.BasicLogContext
+	// Providers are searched in this order of preference
+	Providers []Provider
+}
+
+// NewDiscoverHandler creates a new handler searching the given providers,
+// preferring them in the order given
+func NewDiscoverHandler(providers ...Provider) DiscoverHandler {
+	return DiscoverHandler{Providers: providers}
+}
+
+// ServeHTTP implements the http.Handler interface for the DiscoverHandler type
+func (h DiscoverHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /discover request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+
+	var body []byte
+	if request.Method == "POST" {
+		var err error
+		if body, err = ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxGeometryBodySize)); err != nil {
+			message := fmt.Sprintf("The request body could not be read: %v", err)
+			util.LogSimpleErr(&h.Context, message, err)
+			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := request.ParseForm(); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	providers, err := h.selectProviders(request.FormValue("providers"))
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := model.ParseSearchLimit(request.FormValue("limit"), maxSearchLimit)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	searchSort := defaultSearchSort
+	if requestedSort, err := model.ParseSearchSort(request.FormValue("sort"), false); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	} else if requestedSort != nil {
+		searchSort = *requestedSort
+	}
+
+	results := search(request, body, providers)
+
+	response := FeatureCollection{Providers: map[string]ProviderStatus{}}
+	for i, provider := range providers {
+		response.Providers[provider.Name] = results[i].status
+	}
+	scenes := merge(providers, results)
+	sortScenes(scenes, searchSort)
+	if limit > 0 && len(scenes) > limit {
+		scenes = scenes[:limit]
+	}
+	if response.FeatureCollection, err = featureCollection(scenes); err != nil {
+		err = util.LogSimpleErr(&h.Context, "Failed to write output GeoJSON. ", err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(response)
+	if err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output GeoJSON from:\n%#v", response), err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(responseStatus(results))
+	writer.Write(bytes)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /discover response", Severity: util.INFO})
+}
+
+// selectProviders returns the providers named in a providers parameter, in
+// order of preference, or all of them if none are named
+func (h DiscoverHandler) selectProviders(value string) ([]Provider, error) {
+	if value == "" {
+		return h.Providers, nil
+	}
+	names := make([]string, len(h.Providers))
+	for i, provider := range h.Providers {
+		names[i] = provider.Name
+	}
+	requested := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, known := range names {
+			found = found || known == name
+		}
+		if !found {
+			return nil, fmt.Errorf(invalidProvider, value, strings.Join(names, ", "))
+		}
+		requested[name] = true
+	}
+	var providers []Provider
+	for _, provider := range h.Providers {
+		if requested[provider.Name] {
+			providers = append(providers, provider)
+		}
+	}
+	return providers, nil
+}
+
+// responseStatus is OK if any provider succeeded. If all failed, it is the
+// status they share, as when the request is at fault for each, or else Bad
+// Gateway.
+func responseStatus(results []providerResults) int {
+	status := 0
+	for _, result := range results {
+		switch {
+		case result.status.Status == 0:
+			return http.StatusOK
+		case status == 0:
+			status = result.status.Status
+		case status != result.status.Status:
+			status = http.StatusBadGateway
+		}
+	}
+	if status == 0 {
+		return http.StatusOK
+	}
+	return status
+}
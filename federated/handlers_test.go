@@ -0,0 +1,233 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federated
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+var testPolygon = geojson.NewPolygon([][][]float64{{{30, 10}, {40, 40}, {20, 40}, {10, 20}, {30, 10}}})
+
+func testResult(id string, acquired time.Time, cloudCover float64) model.BasicBrokerResult {
+	return model.BasicBrokerResult{
+		ID:           id,
+		AcquiredDate: acquired,
+		CloudCover:   cloudCover,
+		Geometry:     testPolygon,
+		SensorName:   "Landsat8",
+	}
+}
+
+// The same scene, as the local index and Planet name it
+var (
+	localScene  = testResult("LC08_L1TP_123045_20180101_20180104_01_T1", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 10)
+	planetScene = testResult("LC81230452018001LGN00", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 12)
+	planetOnly  = testResult("LC81230452018017LGN00", time.Date(2018, 1, 17, 0, 0, 0, 0, time.UTC), 50)
+	localOnly   = testResult("LC08_L1TP_123045_20171216_20171224_01_T1", time.Date(2017, 12, 16, 0, 0, 0, 0, time.UTC), 5)
+)
+
+func resultsProvider(name string, results ...model.BasicBrokerResult) Provider {
+	return Provider{Name: name, Search: func(http.ResponseWriter, *http.Request) (*model.MultiBrokerResult, error) {
+		creators := make([]model.GeoJSONFeatureCreator, len(results))
+		for i, result := range results {
+			creators[i] = model.IndexedLandsatBrokerResult{BasicBrokerResult: result}
+		}
+		return &model.MultiBrokerResult{FeatureCreators: creators}, nil
+	}}
+}
+
+func failingProvider(name string, err error) Provider {
+	return Provider{Name: name, Search: func(http.ResponseWriter, *http.Request) (*model.MultiBrokerResult, error) {
+		return nil, err
+	}}
+}
+
+func serveDiscover(t *testing.T, handler DiscoverHandler, request *http.Request) (*http.Response, map[string]interface{}) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	response := recorder.Result()
+	body, _ := ioutil.ReadAll(response.Body)
+	var decoded map[string]interface{}
+	assert.Nil(t, json.Unmarshal(body, &decoded), string(body))
+	return response, decoded
+}
+
+func featureIDs(body map[string]interface{}) []string {
+	ids := []string{}
+	for _, feature := range body["features"].([]interface{}) {
+		ids = append(ids, feature.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func TestDiscoverHandlerMergesProviders(t *testing.T) {
+	handler := NewDiscoverHandler(
+		resultsProvider("localindex", localScene, localOnly),
+		resultsProvider("planet", planetScene, planetOnly),
+	)
+	request, _ := http.NewRequest("GET", "/discover", nil)
+
+	response, body := serveDiscover(t, handler, request)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []string{planetOnly.ID, localScene.ID, localOnly.ID}, featureIDs(body))
+	merged := body["features"].([]interface{})[1].(map[string]interface{})
+	properties := merged["properties"].(map[string]interface{})
+	assert.Equal(t, 10.0, properties["cloudCover"])
+	assert.Equal(t, map[string]interface{}{"localindex": localScene.ID, "planet": planetScene.ID}, properties["providers"])
+	assert.Equal(t, map[string]interface{}{
+		"localindex": map[string]interface{}{"count": 2.0},
+		"planet":     map[string]interface{}{"count": 2.0},
+	}, body["providers"])
+}
+
+func TestDiscoverHandlerSortAndLimit(t *testing.T) {
+	handler := NewDiscoverHandler(
+		resultsProvider("localindex", localScene, localOnly),
+		resultsProvider("planet", planetScene, planetOnly),
+	)
+	request, _ := http.NewRequest("GET", "/discover?sort=cloudCover&limit=2", nil)
+
+	response, body := serveDiscover(t, handler, request)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []string{localOnly.ID, localScene.ID}, featureIDs(body))
+}
+
+func TestDiscoverHandlerSelectsProviders(t *testing.T) {
+	handler := NewDiscoverHandler(
+		resultsProvider("localindex", localScene),
+		resultsProvider("planet", planetScene, planetOnly),
+	)
+	request, _ := http.NewRequest("GET", "/discover?providers=planet", nil)
+
+	response, body := serveDiscover(t, handler, request)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []string{planetOnly.ID, planetScene.ID}, featureIDs(body))
+	assert.Len(t, body["providers"], 1)
+}
+
+func TestDiscoverHandlerUnknownProvider(t *testing.T) {
+	handler := NewDiscoverHandler(resultsProvider("localindex", localScene))
+	request, _ := http.NewRequest("GET", "/discover?providers=localindex,sentinel", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestDiscoverHandlerReportsProviderFailure(t *testing.T) {
+	handler := NewDiscoverHandler(
+		resultsProvider("localindex", localScene),
+		failingProvider("planet", util.HTTPErr{Status: http.StatusUnauthorized, Message: "Bad key"}),
+	)
+	request, _ := http.NewRequest("GET", "/discover", nil)
+
+	response, body := serveDiscover(t, handler, request)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []string{localScene.ID}, featureIDs(body))
+	assert.Equal(t, map[string]interface{}{
+		"localindex": map[string]interface{}{"count": 1.0},
+		"planet":     map[string]interface{}{"count": 0.0, "status": 401.0, "error": "Bad key"},
+	}, body["providers"])
+}
+
+func TestDiscoverHandlerAllProvidersFail(t *testing.T) {
+	badRequest := util.HTTPErr{Status: http.StatusBadRequest, Message: "Bad bbox"}
+	sameHandler := NewDiscoverHandler(failingProvider("localindex", badRequest), failingProvider("planet", badRequest))
+	mixedHandler := NewDiscoverHandler(failingProvider("localindex", errors.New("No database")), failingProvider("planet", badRequest))
+	request, _ := http.NewRequest("GET", "/discover", nil)
+
+	sameResponse, sameBody := serveDiscover(t, sameHandler, request)
+	mixedResponse, mixedBody := serveDiscover(t, mixedHandler, request)
+
+	assert.Equal(t, http.StatusBadRequest, sameResponse.StatusCode)
+	assert.Empty(t, featureIDs(sameBody))
+	assert.Equal(t, http.StatusBadGateway, mixedResponse.StatusCode)
+	assert.Equal(t, "No database", mixedBody["providers"].(map[string]interface{})["localindex"].(map[string]interface{})["error"])
+}
+
+func TestDiscoverHandlerCopiesBody(t *testing.T) {
+	geometry := `{"type":"Polygon","coordinates":[[[30,10],[40,40],[20,40],[10,20],[30,10]]]}`
+	readBody := func(name string) Provider {
+		return Provider{Name: name, Search: func(w http.ResponseWriter, r *http.Request) (*model.MultiBrokerResult, error) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil || string(body) != geometry {
+				return nil, errors.New("Body not copied")
+			}
+			r.Form.Set("itemTypes", name)
+			return &model.MultiBrokerResult{}, nil
+		}}
+	}
+	handler := NewDiscoverHandler(readBody("localindex"), readBody("planet"))
+	request, _ := http.NewRequest("POST", "/discover?cloudCover=20", strings.NewReader(geometry))
+
+	response, body := serveDiscover(t, handler, request)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, map[string]interface{}{
+		"localindex": map[string]interface{}{"count": 0.0},
+		"planet":     map[string]interface{}{"count": 0.0},
+	}, body["providers"])
+	assert.Equal(t, "", request.Form.Get("itemTypes"))
+}
+
+func TestDiscoverHandlerIsolatesProviders(t *testing.T) {
+	isolated := func(name string) Provider {
+		return Provider{Name: name, Search: func(w http.ResponseWriter, r *http.Request) (*model.MultiBrokerResult, error) {
+			// A provider refusing an oversize body writes to its writer
+			w.Header().Set("Connection", "close")
+			w.Write([]byte("written by " + name))
+			if r.FormValue("cursor") != "" || r.FormValue("page_size") != "" || r.URL.Query().Get("cursor") != "" {
+				return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: "Another provider's cursor"}
+			}
+			if r.FormValue("cloudCover") != "20" {
+				return nil, errors.New("Filters not passed on")
+			}
+			return &model.MultiBrokerResult{}, nil
+		}}
+	}
+	handler := NewDiscoverHandler(isolated("localindex"), isolated("planet"))
+	request, _ := http.NewRequest("GET", "/discover?cloudCover=20&cursor=abc&page_size=10", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Empty(t, recorder.Header().Get("Connection"))
+	assert.NotContains(t, recorder.Body.String(), "written by")
+	var body map[string]interface{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{
+		"localindex": map[string]interface{}{"count": 0.0},
+		"planet":     map[string]interface{}{"count": 0.0},
+	}, body["providers"])
+	assert.Equal(t, "abc", request.FormValue("cursor"), "Expected the request itself to be left alone")
+}
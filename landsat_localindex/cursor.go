@@ -0,0 +1,70 @@
+package landsatlocalindex
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
+	"github.com/venicegeo/bf-ia-broker/model"
+)
+
+// searchCursor is the opaque cursor of a page of discovered scenes: the sort
+// it was made for and the key of the last scene before the page
+type searchCursor struct {
+	Sort      string     `json:"sort"`
+	Date      *time.Time `json:"date,omitempty"`
+	Value     *float64   `json:"value,omitempty"`
+	ProductID string     `json:"id"`
+}
+
+func encodeCursor(searchSort model.SearchSort, key db.SceneKey) (string, error) {
+	cursor := searchCursor{Sort: searchSort.String(), ProductID: key.ProductID}
+	switch value := key.SortValue.(type) {
+	case time.Time:
+		cursor.Date = &value
+	case float64:
+		cursor.Value = &value
+	default:
+		return "", errors.New("Unsupported sort value in scene key")
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor reads the key a cursor holds, which must have been made for
+// the same sort
+func decodeCursor(encoded string, searchSort model.SearchSort) (*db.SceneKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cursor searchCursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort != searchSort.String() {
+		return nil, errors.New("Cursor was made for a different sort")
+	}
+	key := db.SceneKey{ProductID: cursor.ProductID}
+	switch {
+	case cursor.ProductID == "":
+		return nil, errors.New("Cursor has no product ID")
+	case cursor.Date != nil:
+		key.SortValue = *cursor.Date
+	case cursor.Value != nil:
+		key.SortValue = *cursor.Value
+	default:
+		return nil, errors.New("Cursor has no sort value")
+	}
+	// The key's type must suit the sort, or the query would compare a date
+	// with a number
+	if _, isDate := key.SortValue.(time.Time); isDate != (searchSort.Field == model.SortByAcquiredDate || searchSort.Field == model.SortByTide) {
+		return nil, errors.New("Cursor's sort value does not suit its sort")
+	}
+	return &key, nil
+}
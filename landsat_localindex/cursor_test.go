@@ -0,0 +1,106 @@
+package landsatlocalindex
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
+	"github.com/venicegeo/bf-ia-broker/model"
+)
+
+var (
+	testingDateSort       = model.SearchSort{Field: model.SortByAcquiredDate, Descending: true}
+	testingCloudCoverSort = model.SearchSort{Field: model.SortByCloudCover}
+)
+
+// makeTestingCursor encodes a cursor as it is sent, whatever its contents
+func makeTestingCursor(t *testing.T, cursor searchCursor) string {
+	data, err := json.Marshal(cursor)
+	assert.Nil(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestCursorRoundTripDate(t *testing.T) {
+	// Mock
+	acquired := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	key := db.SceneKey{SortValue: acquired, ProductID: "LC08_L1TP_123045_20180102_20180104_01_T1"}
+
+	// Tested code
+	encoded, encodeErr := encodeCursor(testingDateSort, key)
+	decoded, decodeErr := decodeCursor(encoded, testingDateSort)
+
+	// Asserts
+	assert.Nil(t, encodeErr)
+	assert.Nil(t, decodeErr)
+	assert.Equal(t, key.ProductID, decoded.ProductID)
+	assert.True(t, acquired.Equal(decoded.SortValue.(time.Time)), "Expected %v but got %v", acquired, decoded.SortValue)
+}
+
+func TestCursorRoundTripNumber(t *testing.T) {
+	// Mock
+	key := db.SceneKey{SortValue: 0.125, ProductID: "LC08_L1TP_123045_20180102_20180104_01_T1"}
+
+	// Tested code
+	encoded, encodeErr := encodeCursor(testingCloudCoverSort, key)
+	decoded, decodeErr := decodeCursor(encoded, testingCloudCoverSort)
+
+	// Asserts
+	assert.Nil(t, encodeErr)
+	assert.Nil(t, decodeErr)
+	assert.Equal(t, key, *decoded)
+}
+
+func TestEncodeCursorUnsupportedValue(t *testing.T) {
+	// Tested code
+	_, err := encodeCursor(testingCloudCoverSort, db.SceneKey{SortValue: "0.125", ProductID: "id"})
+
+	// Asserts
+	assert.NotNil(t, err)
+}
+
+func TestDecodeCursorOtherSort(t *testing.T) {
+	// Mock
+	encoded, err := encodeCursor(testingDateSort, db.SceneKey{SortValue: time.Now(), ProductID: "id"})
+	assert.Nil(t, err)
+
+	// Tested code
+	_, ascendingErr := decodeCursor(encoded, model.SearchSort{Field: model.SortByAcquiredDate})
+	_, cloudCoverErr := decodeCursor(encoded, testingCloudCoverSort)
+
+	// Asserts
+	assert.NotNil(t, ascendingErr, "Expected a cursor for a descending sort to be refused for an ascending one")
+	assert.NotNil(t, cloudCoverErr, "Expected a cursor for a date sort to be refused for a cloud cover one")
+}
+
+func TestDecodeCursorTypeMismatch(t *testing.T) {
+	// Mock
+	now := time.Now()
+	value := 0.5
+	dateForNumber := makeTestingCursor(t, searchCursor{Sort: testingCloudCoverSort.String(), Date: &now, ProductID: "id"})
+	numberForDate := makeTestingCursor(t, searchCursor{Sort: testingDateSort.String(), Value: &value, ProductID: "id"})
+
+	// Tested code
+	_, dateForNumberErr := decodeCursor(dateForNumber, testingCloudCoverSort)
+	_, numberForDateErr := decodeCursor(numberForDate, testingDateSort)
+
+	// Asserts
+	assert.NotNil(t, dateForNumberErr)
+	assert.NotNil(t, numberForDateErr)
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	value := 0.5
+	for _, encoded := range []string{
+		"not base64!",
+		base64.StdEncoding.EncodeToString([]byte(`{"sort":"cloudCover","value":0.5,"id":"id"}`)),
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		makeTestingCursor(t, searchCursor{Sort: testingCloudCoverSort.String(), Value: &value}),
+		makeTestingCursor(t, searchCursor{Sort: testingCloudCoverSort.String(), ProductID: "id"}),
+	} {
+		_, err := decodeCursor(encoded, testingCloudCoverSort)
+		assert.NotNil(t, err, "Expected cursor %v to be refused", encoded)
+	}
+}
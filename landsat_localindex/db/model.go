@@ -3,6 +3,7 @@ package db
 import (
 	"time"
 
+	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/geojson-go/geojson"
 )
 
@@ -22,4 +23,60 @@ type LandsatLocalIndexScene struct {
 	SceneURLString  string
 	Bounds          SingleOrMultiPolygon
 	BoundingBox     geojson.BoundingBox
+	AOICoverage     float64 // Percentage of the search AOI covered; only set by searches
+}
+
+// SceneFilter is what indexed scenes must match to be found by a search. The
+// AOI, path and row are ignored while nil.
+type SceneFilter struct {
+	AOI             SingleOrMultiPolygon
+	MaxCloudCover   float64 // 0-1
+	MinAcquiredDate time.Time
+	MaxAcquiredDate time.Time
+	Path            *int
+	Row             *int
+}
+
+// SceneCount is the number of matching scenes acquired in the interval
+// starting at Start and, when counted by path and row, in that WRS-2 tile
+type SceneCount struct {
+	Start time.Time
+	Path  *int
+	Row   *int
+	Count int
+}
+
+// CloudCoverCount is the number of matching scenes whose cloud cover, as a
+// percentage, is at least Min and below Max
+type CloudCoverCount struct {
+	Min   float64
+	Max   float64
+	Count int
+}
+
+// WRS2Tile is the footprint of a WRS-2 path and row
+type WRS2Tile struct {
+	Path     int
+	Row      int
+	Center   [2]float64 // longitude, latitude
+	Boundary SingleOrMultiPolygon
+}
+
+// SceneKey is the position of a scene in sorted search results: its sort value
+// (a time.Time for dates, otherwise a float64) and product ID
+type SceneKey struct {
+	SortValue interface{}
+	ProductID string
+}
+
+// Key returns the position of a scene in search results sorted by field
+func (s LandsatLocalIndexScene) Key(field model.SortField) SceneKey {
+	switch field {
+	case model.SortByCloudCover:
+		return SceneKey{SortValue: s.CloudCover, ProductID: s.ProductID}
+	case model.SortByAOICoverage:
+		return SceneKey{SortValue: s.AOICoverage, ProductID: s.ProductID}
+	default:
+		return SceneKey{SortValue: s.AcquisitionDate, ProductID: s.ProductID}
+	}
 }
@@ -3,8 +3,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"time"
+	"math"
 
+	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/geojson-go/geojson"
 )
 
@@ -50,27 +51,176 @@ func GetSceneByID(tx *sql.Tx, productID string) (*LandsatLocalIndexScene, error)
 	return &scene, nil
 }
 
-// SearchScenes does a lookup in indexed scenes based on a bounding box, cloud cover, and time window
-// Note: Any cloud cover that is <0 is usually corrupt in some way, and shall be excluded
-func SearchScenes(tx *sql.Tx, bbox geojson.BoundingBox, maxCloudCover float64, minAcquiredDate time.Time, maxAcquiredDate time.Time) ([]LandsatLocalIndexScene, error) {
-	rows, err := tx.Query(`
-		SELECT product_id, acquisition_date, cloud_cover, scene_url, ST_AsGeoJSON(bounds)
-		FROM public.scenes
-		WHERE cloud_cover >= 0
+// aoiCoverageExpression is the percentage of the search AOI a scene covers
+const aoiCoverageExpression = `COALESCE(ST_Area(ST_Intersection(bounds, aoi.geom)) / NULLIF(ST_Area(aoi.geom), 0) * 100, 0)`
+
+// sortExpressions are what search results may be ordered by; tides are not
+// indexed, so sorting by tide orders the scenes by date
+var sortExpressions = map[model.SortField]string{
+	model.SortByAcquiredDate: "acquisition_date",
+	model.SortByCloudCover:   "cloud_cover",
+	model.SortByAOICoverage:  aoiCoverageExpression,
+	model.SortByTide:         "acquisition_date",
+}
+
+// searchConditions are the conditions scenes must meet to match a search, with
+// the AOI (if any) as aoi.geom, the cloud cover and date bounds as $1 to $3,
+// and the WRS-2 path and row (if any) as $5 and $6
+const searchConditions = `
+			cloud_cover >= 0
 			AND cloud_cover < $1
 			AND acquisition_date > $2
 			AND acquisition_date < $3
+			AND wrs_path = COALESCE($5, wrs_path)
+			AND wrs_row = COALESCE($6, wrs_row)
 			AND corner_ll IS NOT NULL 
-			AND ST_Intersects(bounds, ST_MakeEnvelope($4, $5, $6, $7, 4326))
-		ORDER BY acquisition_date DESC
-		LIMIT 100`,
-		maxCloudCover*100, // Cloud cover is imported as 0-100, not as 0-1
-		minAcquiredDate, maxAcquiredDate,
-		bbox[0], bbox[1], bbox[2], bbox[3],
+			AND (aoi.geom IS NULL OR ST_Intersects(bounds, aoi.geom))`
+
+// searchArgs are the arguments $1 to $6 of a search: the cloud cover and date
+// bounds, the AOI as GeoJSON, and the path and row of searchConditions
+func searchArgs(filter SceneFilter) []interface{} {
+	var aoi interface{}
+	if filter.AOI != nil {
+		aoi = filter.AOI.String()
+	}
+	return []interface{}{
+		filter.MaxCloudCover * 100, // Cloud cover is imported as 0-100, not as 0-1
+		filter.MinAcquiredDate, filter.MaxAcquiredDate,
+		aoi,
+		filter.Path, filter.Row,
+	}
+}
+
+// CountScenes counts the indexed scenes matching a search
+func CountScenes(tx *sql.Tx, filter SceneFilter) (int, error) {
+	var count int
+	err := tx.QueryRow(`
+		WITH aoi AS (SELECT ST_SetSRID(ST_GeomFromGeoJSON($4), 4326) AS geom)
+		SELECT count(*)
+		FROM public.scenes, aoi
+		WHERE`+searchConditions,
+		searchArgs(filter)...,
+	).Scan(&count)
+	return count, err
+}
+
+// The intervals scenes may be counted by
+const (
+	CountByDay   = "day"
+	CountByMonth = "month"
+	CountByYear  = "year"
+)
+
+// CountScenesByDate counts the indexed scenes matching a search in each
+// interval (day, month or year) they were acquired in and, if byPathRow is
+// set, in each WRS-2 path and row. Intervals without scenes are left out.
+func CountScenesByDate(tx *sql.Tx, filter SceneFilter, interval string, byPathRow bool) ([]SceneCount, error) {
+	columns := "date_trunc($7, acquisition_date)"
+	if byPathRow {
+		columns += ", wrs_path, wrs_row"
+	}
+	rows, err := tx.Query(`
+		WITH aoi AS (SELECT ST_SetSRID(ST_GeomFromGeoJSON($4), 4326) AS geom)
+		SELECT `+columns+`, count(*)
+		FROM public.scenes, aoi
+		WHERE`+searchConditions+`
+		GROUP BY `+columns+`
+		ORDER BY `+columns,
+		append(searchArgs(filter), interval)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SceneCount{}
+	for rows.Next() {
+		var count SceneCount
+		if byPathRow {
+			count.Path, count.Row = new(int), new(int)
+			err = rows.Scan(&count.Start, count.Path, count.Row, &count.Count)
+		} else {
+			err = rows.Scan(&count.Start, &count.Count)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, count)
+	}
+	return results, rows.Err()
+}
+
+// CountScenesByCloudCover counts the indexed scenes matching a search in
+// cloud cover buckets of the given width, as a percentage. Scenes with 100%
+// cloud cover fall in the last bucket; buckets without scenes are left out.
+func CountScenesByCloudCover(tx *sql.Tx, filter SceneFilter, width float64) ([]CloudCoverCount, error) {
+	rows, err := tx.Query(`
+		WITH aoi AS (SELECT ST_SetSRID(ST_GeomFromGeoJSON($4), 4326) AS geom)
+		SELECT LEAST(floor(cloud_cover / $7), ceil(100 / $7) - 1) AS bucket, count(*)
+		FROM public.scenes, aoi
+		WHERE`+searchConditions+`
+		GROUP BY bucket
+		ORDER BY bucket`,
+		append(searchArgs(filter), width)...,
 	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	results := []CloudCoverCount{}
+	for rows.Next() {
+		var bucket float64
+		var count CloudCoverCount
+		if err = rows.Scan(&bucket, &count.Count); err != nil {
+			return nil, err
+		}
+		count.Min = bucket * width
+		count.Max = math.Min(count.Min+width, 100)
+		results = append(results, count)
+	}
+	return results, rows.Err()
+}
+
+// SearchScenes does a lookup in indexed scenes based on an area of interest, cloud cover, and time window.
+// Each scene found records the percentage of the area of interest that it covers.
+// Up to limit scenes are returned, ordered as searchSort says and then by product ID; if after is not nil,
+// only the scenes following it in that order are returned.
+// Note: Any cloud cover that is <0 is usually corrupt in some way, and shall be excluded
+func SearchScenes(tx *sql.Tx, filter SceneFilter, searchSort model.SearchSort, limit int, after *SceneKey, offset int) ([]LandsatLocalIndexScene, error) {
+	sortExpression := sortExpressions[searchSort.Field]
+	direction, comparison := "ASC", ">"
+	if searchSort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	args := append(searchArgs(filter), limit)
+	// Keyset pagination: both columns are sorted the same way, so the rows
+	// following the key are those comparing after it as a pair
+	keyset := ""
+	if after != nil {
+		keyset = fmt.Sprintf(`
+			AND (%v, product_id) %v ($8, $9)`, sortExpression, comparison)
+		args = append(args, after.SortValue, after.ProductID)
+	}
+	offsetClause := ""
+	if offset > 0 {
+		args = append(args, offset)
+		offsetClause = fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	rows, err := tx.Query(`
+		WITH aoi AS (SELECT ST_SetSRID(ST_GeomFromGeoJSON($4), 4326) AS geom)
+		SELECT product_id, acquisition_date, cloud_cover, scene_url, ST_AsGeoJSON(bounds),
+			`+aoiCoverageExpression+`
+		FROM public.scenes, aoi
+		WHERE`+searchConditions+keyset+`
+		ORDER BY `+sortExpression+` `+direction+`, product_id `+direction+`
+		LIMIT $7`+offsetClause,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	results := []LandsatLocalIndexScene{}
 	for rows.Next() {
@@ -81,7 +231,7 @@ func SearchScenes(tx *sql.Tx, bbox geojson.BoundingBox, maxCloudCover float64, m
 		)
 
 		scene := LandsatLocalIndexScene{}
-		if err = rows.Scan(&scene.ProductID, &scene.AcquisitionDate, &scene.CloudCover, &scene.SceneURLString, &mtlBoundsBytes); err != nil {
+		if err = rows.Scan(&scene.ProductID, &scene.AcquisitionDate, &scene.CloudCover, &scene.SceneURLString, &mtlBoundsBytes, &scene.AOICoverage); err != nil {
 			return nil, err
 		}
 
@@ -100,3 +250,40 @@ func SearchScenes(tx *sql.Tx, bbox geojson.BoundingBox, maxCloudCover float64, m
 
 	return results, nil
 }
+
+// SearchWRS2Tiles finds the WRS-2 paths and rows whose footprints intersect an
+// area of interest, ordered by path and row
+func SearchWRS2Tiles(tx *sql.Tx, aoi SingleOrMultiPolygon) ([]WRS2Tile, error) {
+	rows, err := tx.Query(`
+		WITH aoi AS (SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326) AS geom)
+		SELECT path, "row", ST_AsGeoJSON(boundary), ST_X(center), ST_Y(center)
+		FROM public.wrs2paths, aoi
+		WHERE ST_Intersects(boundary, aoi.geom)
+		ORDER BY path, "row"`,
+		aoi.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []WRS2Tile{}
+	for rows.Next() {
+		var (
+			boundaryBytes      []byte
+			polyErr1, polyErr2 error
+			tile               WRS2Tile
+		)
+		if err = rows.Scan(&tile.Path, &tile.Row, &boundaryBytes, &tile.Center[0], &tile.Center[1]); err != nil {
+			return nil, err
+		}
+		if tile.Boundary, polyErr1 = geojson.PolygonFromBytes(boundaryBytes); polyErr1 != nil {
+			tile.Boundary, polyErr2 = geojson.MultiPolygonFromBytes(boundaryBytes)
+		}
+		if polyErr2 != nil {
+			return nil, fmt.Errorf("Could not extract either Polygon or MultiPolygon from WRS-2 boundary bytes: %v; %v", polyErr1, polyErr2)
+		}
+		results = append(results, tile)
+	}
+	return results, rows.Err()
+}
@@ -2,42 +2,117 @@ package landsatlocalindex
 
 import (
 	"database/sql"
-	"time"
+	"net/http"
 
 	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
 	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/bf-ia-broker/tides"
-	"github.com/venicegeo/geojson-go/geojson"
+	"github.com/venicegeo/bf-ia-broker/util"
 )
 
-func discoverScenes(tx *sql.Tx, ctx Context, bbox geojson.BoundingBox,
-	maxCloudCover float64, minAcquiredDate time.Time, maxAcquiredDate time.Time, withTides bool) (model.GeoJSONFeatureCollectionCreator, error) {
-	scenes, err := db.SearchScenes(tx, bbox, maxCloudCover, minAcquiredDate, maxAcquiredDate)
+// discoverScenes finds a page of up to limit scenes, following after if it is
+// not nil. It also returns the cursor of the next page, if there is one, and
+// how many scenes match the search in all.
+func discoverScenes(tx *sql.Tx, ctx Context, filter db.SceneFilter, withTides bool,
+	searchSort model.SearchSort, limit int, after *db.SceneKey) (*model.MultiBrokerResult, string, int, error) {
+	results, nextCursor, numberMatched, err := searchScenes(tx, ctx, filter, withTides, searchSort, limit, after, 0)
 	if err != nil {
-		return nil, err
+		return nil, "", 0, err
+	}
+
+	multiResult := model.MultiBrokerResult{
+		FeatureCreators: make([]model.GeoJSONFeatureCreator, len(results)),
+	}
+	for i, result := range results {
+		multiResult.FeatureCreators[i] = result
+	}
+	return &multiResult, nextCursor, numberMatched, nil
+}
+
+// searchScenes finds a page of scenes as discoverScenes does, returning them
+// as results; the page may also be found by skipping offset scenes
+func searchScenes(tx *sql.Tx, ctx Context, filter db.SceneFilter, withTides bool,
+	searchSort model.SearchSort, limit int, after *db.SceneKey, offset int) ([]*model.IndexedLandsatBrokerResult, string, int, error) {
+	numberMatched, err := db.CountScenes(tx, filter)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	// One scene more than the page holds shows whether another page follows
+	scenes, err := db.SearchScenes(tx, filter, searchSort, limit+1, after, offset)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	nextCursor := ""
+	// A tide sort, done below, is of this page alone, so it has no next page
+	if len(scenes) > limit {
+		scenes = scenes[:limit]
+		if searchSort.Field != model.SortByTide {
+			if nextCursor, err = encodeCursor(searchSort, scenes[limit-1].Key(searchSort.Field)); err != nil {
+				return nil, "", 0, err
+			}
+		}
 	}
 
 	searchResults := make([]model.BrokerSearchResult, len(scenes))
+	sceneURLs := make(map[string]string, len(scenes))
 	for i, scene := range scenes {
 		searchResults[i] = brokerSearchResultFromScene(scene)
+		if filter.AOI != nil {
+			coverage := scene.AOICoverage
+			searchResults[i].AOICoverage = &coverage
+		}
+		sceneURLs[scene.ProductID] = scene.SceneURLString
 	}
 
 	if withTides {
 		tidesContext := &tides.Context{TidesURL: ctx.BaseTidesURL}
 		if err = tides.AddTidesToSearchResults(tidesContext, searchResults); err != nil {
-			return nil, err
+			return nil, "", 0, err
 		}
 	}
 
-	multiResult := model.MultiBrokerResult{
-		FeatureCreators: make([]model.GeoJSONFeatureCreator, len(searchResults)),
+	// The database cannot sort by tide
+	if searchSort.Field == model.SortByTide {
+		model.SortSearchResults(searchResults, searchSort)
 	}
 
+	results := make([]*model.IndexedLandsatBrokerResult, len(searchResults))
 	for i, result := range searchResults {
-		if multiResult.FeatureCreators[i], err = indexedLandsatBrokerResultFromBrokerSearchResult(result, scenes[i].SceneURLString); err != nil {
-			return nil, err
+		if results[i], err = indexedLandsatBrokerResultFromBrokerSearchResult(result, sceneURLs[result.ID]); err != nil {
+			return nil, "", 0, err
 		}
 	}
 
-	return multiResult, nil
+	return results, nextCursor, numberMatched, nil
+}
+
+// nextPageLink links the page after the one a discovery request found, which
+// has the given cursor. A POSTed AOI is not in the request's URL, so the next
+// page is POSTed again with it.
+func nextPageLink(r *http.Request, filter db.SceneFilter, nextCursor string) model.Link {
+	link := model.Link{Href: util.NextPageURL(r, nextCursor), Rel: "next", Type: "application/geo+json"}
+	if r.Method == "POST" {
+		link.Method, link.Body = "POST", filter.AOI
+	}
+	return link
+}
+
+// atomFeed writes a page of discovered scenes as an Atom feed, with the
+// number of scenes matched in all; entries link to the scenes' metadata
+func atomFeed(result *model.MultiBrokerResult, numberMatched int, nextURL string, r *http.Request) ([]byte, error) {
+	baseURL := util.BaseURL(r)
+	entries, err := result.AtomEntries(func(id string) string {
+		return baseURL + "/localindex/landsat_pds/" + id
+	})
+	if err != nil {
+		return nil, err
+	}
+	feed := model.NewAtomFeed("Landsat 8 local index scenes", baseURL+util.LinkURI(*r.URL))
+	feed.SetEntries(entries)
+	feed.TotalResults = &numberMatched
+	if nextURL != "" {
+		feed.Links = append(feed.Links, model.AtomLink{Href: baseURL + nextURL, Rel: "next", Type: model.AtomContentType})
+	}
+	return feed.Write()
 }
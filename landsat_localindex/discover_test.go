@@ -0,0 +1,50 @@
+package landsatlocalindex
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testingAOI = `{"type":"Polygon","coordinates":[[[30,10],[40,40],[20,40],[10,20],[30,10]]]}`
+
+func TestNextPageLinkGet(t *testing.T) {
+	// Mock
+	r := httptest.NewRequest("GET", "/localindex/discover/landsat_pds?bbox=10,10,40,40&cloudCover=20", nil)
+	filter, err := parseSceneFilter(httptest.NewRecorder(), r)
+	assert.Nil(t, err)
+
+	// Tested code
+	link := nextPageLink(r, filter, "abc")
+
+	// Asserts
+	assert.Equal(t, "next", link.Rel)
+	assert.Equal(t, "", link.Method)
+	assert.Nil(t, link.Body)
+	assert.Contains(t, link.Href, "cursor=abc")
+	assert.Contains(t, link.Href, "bbox=10%2C10%2C40%2C40")
+}
+
+func TestNextPageLinkPostedAOI(t *testing.T) {
+	// Mock
+	r := httptest.NewRequest("POST", "/localindex/discover/landsat_pds?cloudCover=20", bytes.NewBufferString(testingAOI))
+	filter, err := parseSceneFilter(httptest.NewRecorder(), r)
+	assert.Nil(t, err)
+
+	// Tested code
+	link := nextPageLink(r, filter, "abc")
+	body, err := json.Marshal(link.Body)
+	assert.Nil(t, err)
+	next := httptest.NewRequest(link.Method, link.Href, bytes.NewReader(body))
+	nextFilter, nextErr := parseSceneFilter(httptest.NewRecorder(), next)
+
+	// Asserts
+	assert.Equal(t, "POST", link.Method)
+	assert.Nil(t, nextErr)
+	assert.Equal(t, "abc", next.FormValue("cursor"))
+	assert.Equal(t, filter.AOI.WKT(), nextFilter.AOI.WKT())
+	assert.Equal(t, filter.MaxCloudCover, nextFilter.MaxCloudCover)
+}
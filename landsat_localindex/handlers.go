@@ -2,29 +2,60 @@ package landsatlocalindex
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
+	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/bf-ia-broker/util"
 	"github.com/venicegeo/geojson-go/geojson"
 )
 
+// maxGeometryBodySize caps the size of an AOI geometry POSTed to discovery
+const maxGeometryBodySize = 4 << 20
+
+// defaultSearchLimit is how many scenes discovery returns unless asked for a
+// different limit; maxSearchLimit is the most it may be asked for
+const defaultSearchLimit = 100
+const maxSearchLimit = 2500
+
+// maxWRS2Path and maxWRS2Row are the highest WRS-2 path and row numbers
+const maxWRS2Path = 233
+const maxWRS2Row = 248
+
+// unpagedTideSort refuses a cursor for a sort by tide, which the database
+// cannot do, so only the scenes of one page are sorted
+const unpagedTideSort = "The tide sort orders only the scenes of one page, so it cannot be paged with a cursor"
+
+// defaultSearchSort puts the most recent scenes first
+var defaultSearchSort = model.SearchSort{Field: model.SortByAcquiredDate, Descending: true}
+
 // DiscoverHandler is a handler for /localindex/discover/landsat
 // @Title localIndexDiscoverHandler
-// @Description discovers scenes from Planet Labs
-// @Accept  plain
+// @Description discovers scenes from Planet Labs; POST a GeoJSON Polygon or MultiPolygon to search an arbitrary AOI
+// @Accept  plain,json
 // @Param   bbox            query   string  false        "The bounding box, as a GeoJSON Bounding box (x1,y1,x2,y2)"
+// @Param   geometry        body    string  false        "POST only: the AOI, as a GeoJSON Polygon or MultiPolygon (overrides bbox)"
+// @Param   path            query   int     false        "The WRS-2 path (1-233); with a path or row, a GET needs no bbox"
+// @Param   row             query   int     false        "The WRS-2 row (1-248)"
 // @Param   cloudCover      query   string  false        "The maximum cloud cover, as a percentage (0-100)"
 // @Param   acquiredDate    query   string  false        "The minimum (earliest) acquired date, as RFC 3339"
 // @Param   maxAcquiredDate query   string  false        "The maximum acquired date, as RFC 3339"
 // @Param   tides           query   bool    false        "True: incorporate tide prediction in the output"
-// @Success 200 {object}  geojson.FeatureCollection
+// @Param   sort            query   string  false        "Sort by acquiredDate, cloudCover, aoiCoverage or tide (with tides=true); prefix with - for descending; defaults to -acquiredDate. tide sorts only the scenes of one page, found in acquiredDate order, which has no next page"
+// @Param   limit           query   int     false        "The number of scenes per page (1-2500); defaults to 100"
+// @Param   cursor          query   string  false        "The opaque cursor from a previous response's next link"
+// @Param   Accept          header  string  false        "application/geo+json; profile=stac: return a STAC ItemCollection instead"
+// @Param   format          query   string  false        "atom: return an Atom feed with GeoRSS footprints, as does an Accept of application/atom+xml"
+// @Success 200 {object}  model.PagedFeatureCollection
 // @Failure 400 {object}  string
-// @Router /localindex/discover/{itemType} [get]
+// @Router /localindex/discover/{itemType} [get,post]
 type DiscoverHandler struct {
 	Context Context
 }
@@ -61,68 +92,415 @@ func (h DiscoverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer tx.Commit()
 
 	tides, _ := strconv.ParseBool(r.FormValue("tides"))
-	bbox, err := geojson.NewBoundingBox(r.FormValue("bbox"))
+	filter, err := parseSceneFilter(w, r)
 	if err != nil {
-		message := fmt.Sprintf("The bbox value of %v is invalid", r.FormValue("bbox"))
-		util.LogSimpleErr(&h.Context, message, err)
-		util.HTTPError(r, w, &h.Context, message, http.StatusBadRequest)
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
 		tx.Rollback()
 		return
 	}
-	maxCloudCover := float64(1)
-	if r.FormValue("cloudCover") != "" {
-		if maxCloudCover, err = strconv.ParseFloat(r.FormValue("cloudCover"), 64); err != nil {
-			message := fmt.Sprintf("Cloud Cover value of %v is invalid.", r.FormValue("cloudCover"))
+
+	limit, err := model.ParseSearchLimit(r.FormValue("limit"), maxSearchLimit)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+		tx.Rollback()
+		return
+	}
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	searchSort := defaultSearchSort
+	if requestedSort, err := model.ParseSearchSort(r.FormValue("sort"), tides); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+		tx.Rollback()
+		return
+	} else if requestedSort != nil {
+		searchSort = *requestedSort
+	}
+
+	var after *db.SceneKey
+	if cursor := r.FormValue("cursor"); cursor != "" {
+		if searchSort.Field == model.SortByTide {
+			util.LogSimpleErr(&h.Context, unpagedTideSort, nil)
+			util.HTTPError(r, w, &h.Context, unpagedTideSort, http.StatusBadRequest)
+			tx.Rollback()
+			return
+		}
+		if after, err = decodeCursor(cursor, searchSort); err != nil {
+			message := fmt.Sprintf("The cursor value of %v is invalid", cursor)
 			util.LogSimpleErr(&h.Context, message, err)
 			util.HTTPError(r, w, &h.Context, message, http.StatusBadRequest)
 			tx.Rollback()
 			return
 		}
-		maxCloudCover = maxCloudCover / 100.0
 	}
-	minAcquiredDate := time.Unix(0, 0)
-	if r.FormValue("acquiredDate") != "" {
-		if minAcquiredDate, err = time.Parse(time.RFC3339, r.FormValue("acquiredDate")); err != nil {
-			message := fmt.Sprintf("Acquired date value of %v is invalid.", r.FormValue("acquiredDate"))
+
+	multiResult, nextCursor, numberMatched, err := discoverScenes(tx, h.Context, filter, tides, searchSort, limit, after)
+
+	if err != nil {
+		message := fmt.Sprintf("Error searching for scenes: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		tx.Rollback()
+		return
+	}
+
+	var nextLink *model.Link
+	if nextCursor != "" {
+		link := nextPageLink(r, filter, nextCursor)
+		nextLink = &link
+	}
+
+	if util.AcceptsSTAC(r) {
+		collection, err := multiResult.STACItemCollection()
+		if err != nil {
+			message := fmt.Sprintf("Error converting to STAC items: %v", err)
 			util.LogSimpleErr(&h.Context, message, err)
-			util.HTTPError(r, w, &h.Context, message, http.StatusBadRequest)
-			tx.Rollback()
+			util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
 			return
 		}
+		baseURL := util.BaseURL(r)
+		for _, item := range collection.Features {
+			linkSTACItem(item, baseURL)
+		}
+		collection.NumberMatched = &numberMatched
+		if nextLink != nil {
+			collection.Links = append(collection.Links, model.STACLink{Href: baseURL + nextLink.Href, Rel: "next", Type: util.STACContentType, Method: nextLink.Method, Body: nextLink.Body})
+		}
+		writeJSON(w, r, &h.Context, util.STACContentType, collection)
+		util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/discover response", Severity: util.INFO})
+		return
 	}
-	maxAcquiredDate := time.Now()
-	if r.FormValue("maxAcquiredDate") != "" {
-		if maxAcquiredDate, err = time.Parse(time.RFC3339, r.FormValue("maxAcquiredDate")); err != nil {
-			message := fmt.Sprintf("Acquired date value of %v is invalid.", r.FormValue("maxAcquiredDate"))
+
+	if util.AcceptsAtom(r) {
+		// Atom links cannot be POSTed, so a POSTed AOI's feed has no next link
+		nextURL := ""
+		if nextLink != nil && nextLink.Method == "" {
+			nextURL = nextLink.Href
+		}
+		bytes, err := atomFeed(multiResult, numberMatched, nextURL, r)
+		if err != nil {
+			message := fmt.Sprintf("Error writing Atom feed: %v", err)
 			util.LogSimpleErr(&h.Context, message, err)
-			util.HTTPError(r, w, &h.Context, message, http.StatusBadRequest)
-			tx.Rollback()
+			util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
 			return
 		}
+		w.Header().Set("Content-Type", model.AtomContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(bytes)
+		util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/discover response", Severity: util.INFO})
+		return
+	}
+
+	featureCollection, err := multiResult.GeoJSONFeatureCollection()
+	if err != nil {
+		message := fmt.Sprintf("Error converting to feature collection: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+
+	paged := model.NewPagedFeatureCollection(featureCollection, "")
+	if nextLink != nil {
+		paged.Links = append(paged.Links, *nextLink)
+	}
+	paged.NumberMatched = &numberMatched
+	bytes, err := geojson.Write(paged)
+	if err != nil {
+		message := fmt.Sprintf("Error writing feature collection: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
 	}
 
-	multiResult, err := discoverScenes(tx, h.Context, bbox, maxCloudCover, minAcquiredDate, maxAcquiredDate, tides)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/discover response", Severity: util.INFO})
+}
+
+// FederatedSearch searches the local index for federated discovery. The
+// request takes the filters of /localindex/discover, a limit and a sort,
+// which defaults to -acquiredDate; the scenes found are the first in that
+// order. Failures the request is at fault for are util.HTTPErr.
+func (h DiscoverHandler) FederatedSearch(w http.ResponseWriter, r *http.Request) (*model.MultiBrokerResult, error) {
+	filter, err := parseSceneFilter(w, r)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	limit, err := model.ParseSearchLimit(r.FormValue("limit"), maxSearchLimit)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	searchSort := defaultSearchSort
+	if requestedSort, err := model.ParseSearchSort(r.FormValue("sort"), false); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: err.Error()}
+	} else if requestedSort != nil {
+		searchSort = *requestedSort
+	}
 
+	tx, err := h.Context.DB.Begin()
+	if err != nil {
+		message := fmt.Sprintf("Could not begin DB transaction: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		return nil, errors.New(message)
+	}
+	defer tx.Commit()
+
+	multiResult, _, _, err := discoverScenes(tx, h.Context, filter, false, searchSort, limit, nil)
 	if err != nil {
 		message := fmt.Sprintf("Error searching for scenes: %v", err)
 		util.LogSimpleErr(&h.Context, message, err)
+		tx.Rollback()
+		return nil, errors.New(message)
+	}
+	return multiResult, nil
+}
+
+// StatsHandler is a handler for /localindex/stats/landsat
+// @Title localIndexStatsHandler
+// @Description counts the indexed scenes matching a search by acquisition date and by cloud cover; takes the filters of /localindex/discover
+// @Accept  plain,json
+// @Param   interval        query   string  false        "The length of each bucket: day (default), month or year"
+// @Param   byPathRow       query   bool    false        "True: also count each WRS-2 path and row separately"
+// @Param   bbox            query   string  false        "The bounding box, as a GeoJSON Bounding box (x1,y1,x2,y2)"
+// @Param   geometry        body    string  false        "POST only: the AOI, as a GeoJSON Polygon or MultiPolygon (overrides bbox)"
+// @Param   path            query   int     false        "The WRS-2 path (1-233); with a path or row, a GET needs no bbox"
+// @Param   row             query   int     false        "The WRS-2 row (1-248)"
+// @Param   cloudCover      query   string  false        "The maximum cloud cover, as a percentage (0-100)"
+// @Param   acquiredDate    query   string  false        "The minimum (earliest) acquired date, as RFC 3339"
+// @Param   maxAcquiredDate query   string  false        "The maximum acquired date, as RFC 3339"
+// @Success 200 {object}  landsatlocalindex.SceneStats
+// @Failure 400 {object}  string
+// @Router /localindex/stats/{itemType} [get,post]
+type StatsHandler struct {
+	Context Context
+}
+
+// NewStatsHandler creates a new handler using the environment and given DB
+func NewStatsHandler(connectionProvider db.ConnectionProvider) (*StatsHandler, error) {
+	db, err := connectionProvider(&util.BasicLogContext{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsHandler{
+		Context: Context{
+			DB: db,
+		},
+	}, nil
+}
+
+func (h StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /localindex/stats request", Severity: util.INFO})
+
+	interval := r.FormValue("interval")
+	switch interval {
+	case "":
+		interval = db.CountByDay
+	case db.CountByDay, db.CountByMonth, db.CountByYear:
+	default:
+		message := fmt.Sprintf("The interval value of %v is invalid; it must be day, month or year.", interval)
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(r, w, &h.Context, message, http.StatusBadRequest)
+		return
+	}
+	byPathRow, _ := strconv.ParseBool(r.FormValue("byPathRow"))
+
+	filter, err := parseSceneFilter(w, r)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Context.DB.Begin()
+	if err != nil {
+		message := fmt.Sprintf("Could not begin DB transaction: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Commit()
+
+	stats, err := getSceneStats(tx, filter, interval, byPathRow)
+	if err != nil {
+		message := fmt.Sprintf("Error counting scenes: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
 		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
 		tx.Rollback()
 		return
 	}
 
-	featureCollection, err := multiResult.GeoJSONFeatureCollection()
+	bytes, err := json.Marshal(stats)
 	if err != nil {
-		message := fmt.Sprintf("Error converting to feature collection: %v", err)
+		message := fmt.Sprintf("Error writing stats: %v", err)
 		util.LogSimpleErr(&h.Context, message, err)
 		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(featureCollection.String()))
+	w.Write(bytes)
 
-	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/discover response", Severity: util.INFO})
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/stats response", Severity: util.INFO})
+}
+
+// parseSceneFilter reads the filters of a discover or stats request: a bbox
+// or a POSTed AOI, the WRS-2 path and row, the maximum cloud cover and the
+// acquired dates. A GET needs no bbox if it gives a path or row.
+func parseSceneFilter(w http.ResponseWriter, r *http.Request) (db.SceneFilter, error) {
+	var err error
+	filter := db.SceneFilter{MaxCloudCover: 1, MinAcquiredDate: time.Unix(0, 0), MaxAcquiredDate: time.Now()}
+
+	if filter.Path, err = parseWRS2Parameter(r, "path", maxWRS2Path); err != nil {
+		return filter, err
+	}
+	if filter.Row, err = parseWRS2Parameter(r, "row", maxWRS2Row); err != nil {
+		return filter, err
+	}
+
+	if r.Method == "POST" || r.FormValue("bbox") != "" || (filter.Path == nil && filter.Row == nil) {
+		if filter.AOI, err = parseAOI(w, r); err != nil {
+			return filter, err
+		}
+	}
+	if r.FormValue("cloudCover") != "" {
+		if filter.MaxCloudCover, err = strconv.ParseFloat(r.FormValue("cloudCover"), 64); err != nil {
+			return filter, fmt.Errorf("Cloud Cover value of %v is invalid.", r.FormValue("cloudCover"))
+		}
+		filter.MaxCloudCover = filter.MaxCloudCover / 100.0
+	}
+	if r.FormValue("acquiredDate") != "" {
+		if filter.MinAcquiredDate, err = time.Parse(time.RFC3339, r.FormValue("acquiredDate")); err != nil {
+			return filter, fmt.Errorf("Acquired date value of %v is invalid.", r.FormValue("acquiredDate"))
+		}
+	}
+	if r.FormValue("maxAcquiredDate") != "" {
+		if filter.MaxAcquiredDate, err = time.Parse(time.RFC3339, r.FormValue("maxAcquiredDate")); err != nil {
+			return filter, fmt.Errorf("Acquired date value of %v is invalid.", r.FormValue("maxAcquiredDate"))
+		}
+	}
+	return filter, nil
+}
+
+// parseAOI reads the AOI of a request: the geometry POSTed, or else the bbox
+func parseAOI(w http.ResponseWriter, r *http.Request) (model.AOI, error) {
+	if r.Method == "POST" {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxGeometryBodySize))
+		if err != nil {
+			return nil, fmt.Errorf("The request body is not a valid GeoJSON Polygon or MultiPolygon: %v", err)
+		}
+		aoi, err := model.NewAOI(body)
+		if err != nil {
+			return nil, fmt.Errorf("The request body is not a valid GeoJSON Polygon or MultiPolygon: %v", err)
+		}
+		return aoi, nil
+	}
+	bbox, err := geojson.NewBoundingBox(r.FormValue("bbox"))
+	if err != nil {
+		return nil, fmt.Errorf("The bbox value of %v is invalid", r.FormValue("bbox"))
+	}
+	aoi, err := model.NewAOIFromBoundingBox(bbox)
+	if err != nil {
+		return nil, fmt.Errorf("The bbox value of %v is invalid", r.FormValue("bbox"))
+	}
+	return aoi, nil
+}
+
+// parseWRS2Parameter parses a WRS-2 path or row parameter, which must lie
+// between 1 and max; it is nil if not given
+func parseWRS2Parameter(r *http.Request, name string, max int) (*int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 || number > max {
+		return nil, fmt.Errorf("The %v value of %v is invalid; it must be between 1 and %d.", name, value, max)
+	}
+	return &number, nil
+}
+
+// WRS2Handler is a handler for /localindex/wrs2
+// @Title localIndexWRS2Handler
+// @Description finds the WRS-2 paths and rows intersecting an AOI, as a GeoJSON feature collection of their footprints
+// @Accept  plain,json
+// @Param   bbox            query   string  false        "The bounding box, as a GeoJSON Bounding box (x1,y1,x2,y2)"
+// @Param   geometry        body    string  false        "POST only: the AOI, as a GeoJSON Polygon or MultiPolygon (overrides bbox)"
+// @Success 200 {object}  geojson.FeatureCollection
+// @Failure 400 {object}  string
+// @Router /localindex/wrs2 [get,post]
+type WRS2Handler struct {
+	Context Context
+}
+
+// NewWRS2Handler creates a new handler using the environment and given DB
+func NewWRS2Handler(connectionProvider db.ConnectionProvider) (*WRS2Handler, error) {
+	db, err := connectionProvider(&util.BasicLogContext{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WRS2Handler{
+		Context: Context{
+			DB: db,
+		},
+	}, nil
+}
+
+func (h WRS2Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /localindex/wrs2 request", Severity: util.INFO})
+
+	aoi, err := parseAOI(w, r)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Context.DB.Begin()
+	if err != nil {
+		message := fmt.Sprintf("Could not begin DB transaction: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Commit()
+
+	tiles, err := db.SearchWRS2Tiles(tx, aoi)
+	if err != nil {
+		message := fmt.Sprintf("Error searching for WRS-2 paths and rows: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		tx.Rollback()
+		return
+	}
+
+	bytes, err := geojson.Write(wrs2FeatureCollection(tiles))
+	if err != nil {
+		message := fmt.Sprintf("Error writing feature collection: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/wrs2 response", Severity: util.INFO})
 }
 
 // MetadataHandler is a handler for /localindex/landsat/{id}
@@ -131,6 +509,7 @@ func (h DiscoverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // @Accept  plain
 // @Param   id            path   string  false        "The ID of the requested scene"
 // @Param   tides           query   bool    false        "True: incorporate tide prediction in the output"
+// @Param   Accept          header  string  false        "application/geo+json; profile=stac: return a STAC Item instead"
 // @Success 200 {object}  geojson.Feature
 // @Failure 400 {object}  string
 // @Router /localindex/landsat/{id} [get]
@@ -193,6 +572,20 @@ func (h MetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if util.AcceptsSTAC(r) {
+		item, err := stacItem(metadata, util.BaseURL(r))
+		if err != nil {
+			message := fmt.Sprintf("Error converting metadata to a STAC item: %v", err)
+			util.LogSimpleErr(&h.Context, message, err)
+			util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+			tx.Rollback()
+			return
+		}
+		writeJSON(w, r, &h.Context, util.STACContentType, item)
+		util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/landsat_pds/{id} response", Severity: util.INFO})
+		return
+	}
+
 	feature, err := metadata.GeoJSONFeature()
 	if err != nil {
 		message := fmt.Sprintf("Error converting metadata to geojson: %v", err)
@@ -274,3 +667,491 @@ func (h PreviewImageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/preview/landsat_pds/{id} response", Severity: util.INFO})
 }
+
+// OpenSearchHandler is a handler for /localindex/opensearch/landsat_pds
+// @Title localIndexOpenSearchHandler
+// @Description describes discovery in the local index as OpenSearch, with the Geo, Time and EO extensions
+// @Accept  plain
+// @Success 200 {object}  string
+// @Router /localindex/opensearch/landsat_pds [get]
+type OpenSearchHandler struct {
+	Context Context
+}
+
+// NewOpenSearchHandler creates a new handler
+func NewOpenSearchHandler() OpenSearchHandler {
+	return OpenSearchHandler{}
+}
+
+func (h OpenSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /localindex/opensearch/landsat_pds request", Severity: util.INFO})
+
+	baseURL := util.BaseURL(r)
+	description := model.NewOpenSearchDescription(
+		"Landsat 8 local index",
+		"Landsat 8 scenes indexed by the Beachfront imagery broker",
+		baseURL+"/localindex/discover/"+stacCollectionID,
+		baseURL+util.LinkURI(*r.URL),
+		true,
+	)
+	bytes, err := description.Write()
+	if err != nil {
+		message := fmt.Sprintf("Error writing OpenSearch description: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", model.OpenSearchDescriptionContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /localindex/opensearch/landsat_pds response", Severity: util.INFO})
+}
+
+// STACCatalogHandler is a handler for /stac
+// @Title localIndexSTACCatalogHandler
+// @Description the landing page of the STAC API over the local index
+// @Accept  plain
+// @Success 200 {object}  string
+// @Router /stac [get]
+type STACCatalogHandler struct {
+	Context Context
+}
+
+// NewSTACCatalogHandler creates a new handler
+func NewSTACCatalogHandler() STACCatalogHandler {
+	return STACCatalogHandler{}
+}
+
+func (h STACCatalogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /stac request", Severity: util.INFO})
+
+	writeJSON(w, r, &h.Context, "application/json", stacCatalog(util.BaseURL(r)))
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /stac response", Severity: util.INFO})
+}
+
+// STACCollectionsHandler is a handler for /stac/collections
+// @Title localIndexSTACCollectionsHandler
+// @Description the STAC collections of the local index, or the one named
+// @Accept  plain
+// @Param   collectionId    path    string  false        "The ID of the requested collection, landsat_pds"
+// @Success 200 {object}  string
+// @Failure 404 {object}  string
+// @Router /stac/collections/{collectionId} [get]
+// @Router /stac/collections [get]
+type STACCollectionsHandler struct {
+	Context Context
+}
+
+// NewSTACCollectionsHandler creates a new handler
+func NewSTACCollectionsHandler() STACCollectionsHandler {
+	return STACCollectionsHandler{}
+}
+
+func (h STACCollectionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /stac/collections request", Severity: util.INFO})
+
+	baseURL := util.BaseURL(r)
+	collectionID, ok := mux.Vars(r)["collectionId"]
+	switch {
+	case !ok:
+		writeJSON(w, r, &h.Context, "application/json", map[string]interface{}{
+			"collections": []interface{}{stacCollection(baseURL)},
+			"links": []model.STACLink{
+				{Href: baseURL + "/stac/collections", Rel: "self", Type: "application/json"},
+				{Href: baseURL + "/stac", Rel: "root", Type: "application/json"},
+			},
+		})
+	case collectionID == stacCollectionID:
+		writeJSON(w, r, &h.Context, "application/json", stacCollection(baseURL))
+	default:
+		message := fmt.Sprintf("Collection not found: %s", collectionID)
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(r, w, &h.Context, message, http.StatusNotFound)
+		return
+	}
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /stac/collections response", Severity: util.INFO})
+}
+
+// STACItemsHandler is a handler for /stac/collections/{collectionId}/items
+// @Title localIndexSTACItemsHandler
+// @Description the scenes of a STAC collection as STAC Items, or the one named
+// @Accept  plain
+// @Param   collectionId    path    string  true         "The ID of the collection, landsat_pds"
+// @Param   itemId          path    string  false        "The ID of the requested scene"
+// @Param   bbox            query   string  false        "The bounding box (x1,y1,x2,y2)"
+// @Param   datetime        query   string  false        "An RFC 3339 date, or an interval of two, either of which may be open (..)"
+// @Param   limit           query   int     false        "The number of items per page (1-2500); defaults to 100"
+// @Param   cursor          query   string  false        "The opaque cursor from a previous response's next link"
+// @Success 200 {object}  model.STACItemCollection
+// @Failure 400 {object}  string
+// @Failure 404 {object}  string
+// @Router /stac/collections/{collectionId}/items/{itemId} [get]
+// @Router /stac/collections/{collectionId}/items [get]
+type STACItemsHandler struct {
+	Context Context
+}
+
+// NewSTACItemsHandler creates a new handler using the environment and given DB
+func NewSTACItemsHandler(connectionProvider db.ConnectionProvider) (*STACItemsHandler, error) {
+	db, err := connectionProvider(&util.BasicLogContext{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &STACItemsHandler{
+		Context: Context{
+			DB: db,
+		},
+	}, nil
+}
+
+func (h STACItemsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /stac/collections/{collectionId}/items request", Severity: util.INFO})
+
+	if collectionID := mux.Vars(r)["collectionId"]; collectionID != stacCollectionID {
+		message := fmt.Sprintf("Collection not found: %s", collectionID)
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(r, w, &h.Context, message, http.StatusNotFound)
+		return
+	}
+
+	itemID, single := mux.Vars(r)["itemId"]
+	var (
+		search stacSearch
+		err    error
+	)
+	if single {
+		search.IDs = []string{itemID}
+	} else if search, err = parseSTACSearch(w, r); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Context.DB.Begin()
+	if err != nil {
+		message := fmt.Sprintf("Could not begin DB transaction: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Commit()
+
+	baseURL := util.BaseURL(r)
+	items, nextCursor, numberMatched, err := searchSTACItems(tx, h.Context, search, baseURL)
+	if err != nil {
+		message := fmt.Sprintf("Error searching for scenes: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		tx.Rollback()
+		return
+	}
+
+	if single {
+		if len(items) == 0 {
+			message := fmt.Sprintf("Scene not found: %s", itemID)
+			util.LogSimpleErr(&h.Context, message, nil)
+			util.HTTPError(r, w, &h.Context, message, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, r, &h.Context, "application/geo+json", items[0])
+	} else {
+		collection := model.NewSTACItemCollection(items)
+		collection.NumberMatched = &numberMatched
+		collectionURL := baseURL + "/stac/collections/" + stacCollectionID
+		collection.Links = append(collection.Links,
+			model.STACLink{Href: baseURL + util.LinkURI(*r.URL), Rel: "self", Type: "application/geo+json"},
+			model.STACLink{Href: collectionURL, Rel: "collection", Type: "application/json"},
+			model.STACLink{Href: baseURL + "/stac", Rel: "root", Type: "application/json"},
+		)
+		if nextCursor != "" {
+			collection.Links = append(collection.Links, model.STACLink{Href: baseURL + util.NextPageURL(r, nextCursor), Rel: "next", Type: "application/geo+json"})
+		}
+		writeJSON(w, r, &h.Context, "application/geo+json", collection)
+	}
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /stac/collections/{collectionId}/items response", Severity: util.INFO})
+}
+
+// STACSearchHandler is a handler for /stac/search
+// @Title localIndexSTACSearchHandler
+// @Description searches the local index, returning STAC Items; POST the search as JSON, or give it as query parameters
+// @Accept  plain,json
+// @Param   bbox            query   string  false        "The bounding box (x1,y1,x2,y2)"
+// @Param   intersects      query   string  false        "A GeoJSON Polygon or MultiPolygon the scenes must intersect (overrides bbox)"
+// @Param   datetime        query   string  false        "An RFC 3339 date, or an interval of two, either of which may be open (..)"
+// @Param   collections     query   string  false        "Comma-separated collection IDs; only landsat_pds is served"
+// @Param   ids             query   string  false        "Comma-separated scene IDs; the other filters are then ignored"
+// @Param   limit           query   int     false        "The number of items per page (1-2500); defaults to 100"
+// @Param   cursor          query   string  false        "The opaque cursor from a previous response's next link"
+// @Param   search          body    string  false        "POST only: the search, as a JSON object with the fields above"
+// @Success 200 {object}  model.STACItemCollection
+// @Failure 400 {object}  string
+// @Router /stac/search [get,post]
+type STACSearchHandler struct {
+	Context Context
+}
+
+// NewSTACSearchHandler creates a new handler using the environment and given DB
+func NewSTACSearchHandler(connectionProvider db.ConnectionProvider) (*STACSearchHandler, error) {
+	db, err := connectionProvider(&util.BasicLogContext{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &STACSearchHandler{
+		Context: Context{
+			DB: db,
+		},
+	}, nil
+}
+
+func (h STACSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /stac/search request", Severity: util.INFO})
+
+	search, err := parseSTACSearch(w, r)
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Context.DB.Begin()
+	if err != nil {
+		message := fmt.Sprintf("Could not begin DB transaction: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Commit()
+
+	baseURL := util.BaseURL(r)
+	items, nextCursor, numberMatched, err := searchSTACItems(tx, h.Context, search, baseURL)
+	if err != nil {
+		message := fmt.Sprintf("Error searching for scenes: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		tx.Rollback()
+		return
+	}
+
+	collection := model.NewSTACItemCollection(items)
+	collection.NumberMatched = &numberMatched
+	collection.Links = append(collection.Links, model.STACLink{Href: baseURL + "/stac", Rel: "root", Type: "application/json"})
+	switch {
+	case nextCursor == "":
+	case r.Method == "POST":
+		// The next page is the same search, POSTed with the cursor
+		search.Cursor = nextCursor
+		collection.Links = append(collection.Links, model.STACLink{Href: baseURL + "/stac/search", Rel: "next", Type: "application/geo+json", Method: "POST", Body: search})
+	default:
+		collection.Links = append(collection.Links, model.STACLink{Href: baseURL + util.NextPageURL(r, nextCursor), Rel: "next", Type: "application/geo+json", Method: "GET"})
+	}
+	writeJSON(w, r, &h.Context, "application/geo+json", collection)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /stac/search response", Severity: util.INFO})
+}
+
+// OGCLandingPageHandler is a handler for /ogcapi
+// @Title localIndexOGCLandingPageHandler
+// @Description the landing page of OGC API - Features over the local index
+// @Accept  plain
+// @Success 200 {object}  string
+// @Router /ogcapi [get]
+type OGCLandingPageHandler struct {
+	Context Context
+}
+
+// NewOGCLandingPageHandler creates a new handler
+func NewOGCLandingPageHandler() OGCLandingPageHandler {
+	return OGCLandingPageHandler{}
+}
+
+func (h OGCLandingPageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /ogcapi request", Severity: util.INFO})
+
+	writeJSON(w, r, &h.Context, "application/json", ogcLandingPage(util.BaseURL(r)))
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /ogcapi response", Severity: util.INFO})
+}
+
+// OGCConformanceHandler is a handler for /ogcapi/conformance
+// @Title localIndexOGCConformanceHandler
+// @Description the OGC API - Features conformance classes served
+// @Accept  plain
+// @Success 200 {object}  string
+// @Router /ogcapi/conformance [get]
+type OGCConformanceHandler struct {
+	Context Context
+}
+
+// NewOGCConformanceHandler creates a new handler
+func NewOGCConformanceHandler() OGCConformanceHandler {
+	return OGCConformanceHandler{}
+}
+
+func (h OGCConformanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /ogcapi/conformance request", Severity: util.INFO})
+
+	writeJSON(w, r, &h.Context, "application/json", map[string]interface{}{"conformsTo": ogcConformance})
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /ogcapi/conformance response", Severity: util.INFO})
+}
+
+// OGCCollectionsHandler is a handler for /ogcapi/collections
+// @Title localIndexOGCCollectionsHandler
+// @Description the OGC API collections of the local index, or the one named
+// @Accept  plain
+// @Param   collectionId    path    string  false        "The ID of the requested collection, landsat_pds"
+// @Success 200 {object}  string
+// @Failure 404 {object}  string
+// @Router /ogcapi/collections/{collectionId} [get]
+// @Router /ogcapi/collections [get]
+type OGCCollectionsHandler struct {
+	Context Context
+}
+
+// NewOGCCollectionsHandler creates a new handler
+func NewOGCCollectionsHandler() OGCCollectionsHandler {
+	return OGCCollectionsHandler{}
+}
+
+func (h OGCCollectionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /ogcapi/collections request", Severity: util.INFO})
+
+	baseURL := util.BaseURL(r)
+	collectionID, ok := mux.Vars(r)["collectionId"]
+	switch {
+	case !ok:
+		writeJSON(w, r, &h.Context, "application/json", map[string]interface{}{
+			"collections": []interface{}{ogcCollection(baseURL)},
+			"links": []model.Link{
+				{Href: baseURL + "/ogcapi/collections", Rel: "self", Type: "application/json"},
+			},
+		})
+	case collectionID == stacCollectionID:
+		writeJSON(w, r, &h.Context, "application/json", ogcCollection(baseURL))
+	default:
+		message := fmt.Sprintf("Collection not found: %s", collectionID)
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(r, w, &h.Context, message, http.StatusNotFound)
+		return
+	}
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /ogcapi/collections response", Severity: util.INFO})
+}
+
+// OGCItemsHandler is a handler for /ogcapi/collections/{collectionId}/items
+// @Title localIndexOGCItemsHandler
+// @Description the scenes of a collection as GeoJSON features, or the one named
+// @Accept  plain
+// @Param   collectionId    path    string  true         "The ID of the collection, landsat_pds"
+// @Param   featureId       path    string  false        "The ID of the requested scene"
+// @Param   bbox            query   string  false        "The bounding box (x1,y1,x2,y2)"
+// @Param   datetime        query   string  false        "An RFC 3339 date, or an interval of two, either of which may be open (..)"
+// @Param   limit           query   int     false        "The number of features per page (1-2500); defaults to 100"
+// @Param   offset          query   int     false        "The number of features to skip; defaults to 0"
+// @Success 200 {object}  model.PagedFeatureCollection
+// @Failure 400 {object}  string
+// @Failure 404 {object}  string
+// @Router /ogcapi/collections/{collectionId}/items/{featureId} [get]
+// @Router /ogcapi/collections/{collectionId}/items [get]
+type OGCItemsHandler struct {
+	Context Context
+}
+
+// NewOGCItemsHandler creates a new handler using the environment and given DB
+func NewOGCItemsHandler(connectionProvider db.ConnectionProvider) (*OGCItemsHandler, error) {
+	db, err := connectionProvider(&util.BasicLogContext{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OGCItemsHandler{
+		Context: Context{
+			DB: db,
+		},
+	}, nil
+}
+
+func (h OGCItemsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method, Actee: r.URL.String(), Message: "Receiving /ogcapi/collections/{collectionId}/items request", Severity: util.INFO})
+
+	if collectionID := mux.Vars(r)["collectionId"]; collectionID != stacCollectionID {
+		message := fmt.Sprintf("Collection not found: %s", collectionID)
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(r, w, &h.Context, message, http.StatusNotFound)
+		return
+	}
+
+	featureID, single := mux.Vars(r)["featureId"]
+	var (
+		query ogcItemsQuery
+		err   error
+	)
+	if !single {
+		if query, err = parseOGCItemsQuery(r); err != nil {
+			util.LogSimpleErr(&h.Context, err.Error(), nil)
+			util.HTTPError(r, w, &h.Context, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := h.Context.DB.Begin()
+	if err != nil {
+		message := fmt.Sprintf("Could not begin DB transaction: %v", err)
+		util.LogSimpleErr(&h.Context, message, err)
+		util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Commit()
+
+	baseURL := util.BaseURL(r)
+	if single {
+		feature, err := getOGCFeature(tx, h.Context, featureID, baseURL)
+		if err == sql.ErrNoRows {
+			message := fmt.Sprintf("Scene not found: %s", featureID)
+			util.LogSimpleErr(&h.Context, message, nil)
+			util.HTTPError(r, w, &h.Context, message, http.StatusNotFound)
+			tx.Rollback()
+			return
+		}
+		if err != nil {
+			message := fmt.Sprintf("Server error searching for scene: %v", err)
+			util.LogSimpleErr(&h.Context, message, err)
+			util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+			tx.Rollback()
+			return
+		}
+		writeJSON(w, r, &h.Context, "application/geo+json", feature)
+	} else {
+		collection, err := searchOGCFeatures(tx, h.Context, query, r, baseURL)
+		if err != nil {
+			message := fmt.Sprintf("Error searching for scenes: %v", err)
+			util.LogSimpleErr(&h.Context, message, err)
+			util.HTTPError(r, w, &h.Context, message, http.StatusInternalServerError)
+			tx.Rollback()
+			return
+		}
+		writeJSON(w, r, &h.Context, "application/geo+json", collection)
+	}
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: r.Method + " response", Actee: r.URL.String(), Message: "Sending /ogcapi/collections/{collectionId}/items response", Severity: util.INFO})
+}
+
+// writeJSON writes a successful response of the given content type
+func writeJSON(w http.ResponseWriter, r *http.Request, ctx *Context, contentType string, output interface{}) {
+	bytes, err := json.Marshal(output)
+	if err != nil {
+		message := fmt.Sprintf("Error writing response: %v", err)
+		util.LogSimpleErr(ctx, message, err)
+		util.HTTPError(r, w, ctx, message, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/venicegeo/bf-ia-broker/tides"
 )
 
-func getMetadata(tx *sql.Tx, ctx Context, sceneID string, withTides bool) (model.GeoJSONFeatureCreator, error) {
+func getMetadata(tx *sql.Tx, ctx Context, sceneID string, withTides bool) (*model.IndexedLandsatBrokerResult, error) {
 	scene, err := db.GetSceneByID(tx, sceneID)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,172 @@
+package landsatlocalindex
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// ogcConformance are the OGC API - Features conformance classes served
+var ogcConformance = []string{
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
+}
+
+// ogcCRS84 is the one coordinate reference system features are served in
+const ogcCRS84 = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+
+// ogcItemsQuery is a query of the features of a collection
+type ogcItemsQuery struct {
+	search stacSearch
+	limit  int
+	offset int
+}
+
+// ogcFeatureCollection is a page of features, as OGC API - Features has it
+type ogcFeatureCollection struct {
+	model.PagedFeatureCollection
+	NumberReturned int    `json:"numberReturned"`
+	TimeStamp      string `json:"timeStamp"`
+}
+
+// ogcFeature is a single feature with links to itself and its collection
+type ogcFeature struct {
+	*geojson.Feature
+	Links []model.Link `json:"links"`
+}
+
+// parseOGCItemsQuery reads bbox, datetime, limit and offset from the query
+// parameters; the filters are those of a STAC search
+func parseOGCItemsQuery(r *http.Request) (ogcItemsQuery, error) {
+	var (
+		query ogcItemsQuery
+		err   error
+	)
+	if query.search.Bbox, err = parseBbox(r.FormValue("bbox")); err != nil {
+		return query, err
+	}
+	query.search.Datetime = r.FormValue("datetime")
+	if err = query.search.prepare(); err != nil {
+		return query, err
+	}
+	if query.limit, err = model.ParseSearchLimit(r.FormValue("limit"), maxSearchLimit); err != nil {
+		return query, err
+	}
+	if query.limit == 0 {
+		query.limit = defaultSearchLimit
+	}
+	if offset := r.FormValue("offset"); offset != "" {
+		if query.offset, err = strconv.Atoi(offset); err != nil || query.offset < 0 {
+			return query, fmt.Errorf("The offset value of %v is invalid; it must be 0 or more", offset)
+		}
+	}
+	return query, nil
+}
+
+// searchOGCFeatures finds a page of the scenes a query matches as features,
+// linked for paging by offset from the request
+func searchOGCFeatures(tx *sql.Tx, ctx Context, query ogcItemsQuery, r *http.Request, baseURL string) (*ogcFeatureCollection, error) {
+	results, nextCursor, numberMatched, err := searchScenes(tx, ctx, query.search.sceneFilter, false, defaultSearchSort, query.limit, nil, query.offset)
+	if err != nil {
+		return nil, err
+	}
+	features := make([]*geojson.Feature, len(results))
+	for i, result := range results {
+		if features[i], err = result.GeoJSONFeature(); err != nil {
+			return nil, err
+		}
+	}
+	fc := geojson.NewFeatureCollection(features)
+
+	collectionURL := baseURL + "/ogcapi/collections/" + stacCollectionID
+	collection := ogcFeatureCollection{
+		PagedFeatureCollection: model.PagedFeatureCollection{FeatureCollection: fc, NumberMatched: &numberMatched},
+		NumberReturned:         len(fc.Features),
+		TimeStamp:              time.Now().UTC().Format(time.RFC3339),
+	}
+	collection.Links = []model.Link{
+		{Href: baseURL + util.LinkURI(*r.URL), Rel: "self", Type: "application/geo+json"},
+		{Href: collectionURL, Rel: "collection", Type: "application/json"},
+	}
+	// A cursor means more scenes follow this page
+	if nextCursor != "" {
+		collection.Links = append(collection.Links, model.Link{Href: baseURL + offsetURL(r, query.offset+query.limit), Rel: "next", Type: "application/geo+json"})
+	}
+	if query.offset > 0 {
+		previous := query.offset - query.limit
+		if previous < 0 {
+			previous = 0
+		}
+		collection.Links = append(collection.Links, model.Link{Href: baseURL + offsetURL(r, previous), Rel: "prev", Type: "application/geo+json"})
+	}
+	return &collection, nil
+}
+
+// offsetURL returns the URL of the request with its offset replaced and its
+// credential parameters dropped
+func offsetURL(r *http.Request, offset int) string {
+	pageURL := *r.URL
+	query := pageURL.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	pageURL.RawQuery = query.Encode()
+	return util.LinkURI(pageURL)
+}
+
+// getOGCFeature finds a single scene as a feature
+func getOGCFeature(tx *sql.Tx, ctx Context, id string, baseURL string) (*ogcFeature, error) {
+	result, err := getMetadata(tx, ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	feature, err := result.GeoJSONFeature()
+	if err != nil {
+		return nil, err
+	}
+	collectionURL := baseURL + "/ogcapi/collections/" + stacCollectionID
+	return &ogcFeature{
+		Feature: feature,
+		Links: []model.Link{
+			{Href: collectionURL + "/items/" + id, Rel: "self", Type: "application/geo+json"},
+			{Href: collectionURL, Rel: "collection", Type: "application/json"},
+		},
+	}, nil
+}
+
+// ogcLandingPage is the landing page of the OGC API
+func ogcLandingPage(baseURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       "Beachfront imagery broker",
+		"description": "Landsat 8 scenes indexed by the Beachfront imagery broker, as OGC API - Features",
+		"links": []model.Link{
+			{Href: baseURL + "/ogcapi", Rel: "self", Type: "application/json"},
+			{Href: baseURL + "/ogcapi/conformance", Rel: "conformance", Type: "application/json"},
+			{Href: baseURL + "/ogcapi/collections", Rel: "data", Type: "application/json"},
+		},
+	}
+}
+
+// ogcCollection describes the local index as an OGC API collection
+func ogcCollection(baseURL string) map[string]interface{} {
+	collectionURL := baseURL + "/ogcapi/collections/" + stacCollectionID
+	return map[string]interface{}{
+		"id":          stacCollectionID,
+		"title":       "Landsat 8 on AWS",
+		"description": "Landsat 8 Collection 1 scene footprints, as indexed by the broker",
+		"itemType":    "feature",
+		"crs":         []string{ogcCRS84},
+		"extent": map[string]interface{}{
+			"spatial":  map[string]interface{}{"bbox": [][]float64{{-180, -90, 180, 90}}, "crs": ogcCRS84},
+			"temporal": map[string]interface{}{"interval": [][]interface{}{{landsat8Start.Format(time.RFC3339), nil}}},
+		},
+		"links": []model.Link{
+			{Href: collectionURL, Rel: "self", Type: "application/json"},
+			{Href: collectionURL + "/items", Rel: "items", Type: "application/geo+json"},
+		},
+	}
+}
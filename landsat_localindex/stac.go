@@ -0,0 +1,312 @@
+package landsatlocalindex
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// stacCollectionID is the one collection of the local index
+const stacCollectionID = "landsat_pds"
+
+// stacConformance are the STAC API conformance classes served
+var stacConformance = []string{
+	"https://api.stacspec.org/v1.0.0/core",
+	"https://api.stacspec.org/v1.0.0/collections",
+	"https://api.stacspec.org/v1.0.0/item-search",
+}
+
+// landsat8Start is when Landsat 8 began acquiring scenes
+var landsat8Start = time.Date(2013, time.March, 18, 0, 0, 0, 0, time.UTC)
+
+// stacSearch is a STAC item search, as given in a GET query or a POSTed body
+type stacSearch struct {
+	Bbox        []float64       `json:"bbox,omitempty"`
+	Intersects  json.RawMessage `json:"intersects,omitempty"`
+	Datetime    string          `json:"datetime,omitempty"`
+	Limit       *int            `json:"limit,omitempty"`
+	Collections []string        `json:"collections,omitempty"`
+	IDs         []string        `json:"ids,omitempty"`
+	Cursor      string          `json:"cursor,omitempty"`
+
+	sceneFilter db.SceneFilter
+	after       *db.SceneKey
+}
+
+// parseSTACSearch reads a search from a POSTed JSON body, or else from the
+// query parameters, in which bbox, collections and ids are comma-separated
+func parseSTACSearch(w http.ResponseWriter, r *http.Request) (stacSearch, error) {
+	var search stacSearch
+	if r.Method == "POST" {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxGeometryBodySize))
+		if err == nil {
+			err = json.Unmarshal(body, &search)
+		}
+		if err != nil {
+			return search, fmt.Errorf("The request body is not a valid STAC search: %v", err)
+		}
+		if search.Limit != nil {
+			if _, err = model.ParseSearchLimit(strconv.Itoa(*search.Limit), maxSearchLimit); err != nil {
+				return search, err
+			}
+		}
+		return search, search.prepare()
+	}
+
+	var err error
+	if search.Bbox, err = parseBbox(r.FormValue("bbox")); err != nil {
+		return search, err
+	}
+	if intersects := r.FormValue("intersects"); intersects != "" {
+		search.Intersects = json.RawMessage(intersects)
+	}
+	search.Datetime = r.FormValue("datetime")
+	if limit, err := model.ParseSearchLimit(r.FormValue("limit"), maxSearchLimit); err != nil {
+		return search, err
+	} else if limit > 0 {
+		search.Limit = &limit
+	}
+	search.Collections = splitList(r.FormValue("collections"))
+	search.IDs = splitList(r.FormValue("ids"))
+	search.Cursor = r.FormValue("cursor")
+	return search, search.prepare()
+}
+
+// parseBbox parses a comma-separated bbox parameter; it is checked once the
+// search is prepared
+func parseBbox(value string) ([]float64, error) {
+	var bbox []float64
+	if value == "" {
+		return bbox, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		coordinate, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("The bbox value of %v is invalid", value)
+		}
+		bbox = append(bbox, coordinate)
+	}
+	return bbox, nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// prepare builds the scene filter of a search, and decodes its cursor;
+// without a bbox or intersects, the whole index is searched
+func (search *stacSearch) prepare() error {
+	var err error
+	filter := db.SceneFilter{MaxCloudCover: 1}
+	if filter.MinAcquiredDate, filter.MaxAcquiredDate, err = parseDatetime(search.Datetime); err != nil {
+		return err
+	}
+	switch {
+	case len(search.Intersects) > 0:
+		if filter.AOI, err = model.NewAOI(search.Intersects); err != nil {
+			return fmt.Errorf("The intersects value is not a valid GeoJSON Polygon or MultiPolygon: %v", err)
+		}
+	case len(search.Bbox) > 0:
+		bbox := geojson.BoundingBox(search.Bbox)
+		err := bbox.Valid()
+		if err == nil {
+			filter.AOI, err = model.NewAOIFromBoundingBox(bbox)
+		}
+		if err != nil {
+			return fmt.Errorf("The bbox value of %v is invalid", search.Bbox)
+		}
+	}
+	search.sceneFilter = filter
+
+	if search.Cursor != "" {
+		if search.after, err = decodeCursor(search.Cursor, defaultSearchSort); err != nil {
+			return fmt.Errorf("The cursor value of %v is invalid", search.Cursor)
+		}
+	}
+	return nil
+}
+
+// parseDatetime parses a datetime parameter, an RFC 3339 instant or an
+// interval of two, either of which may be open (.. or empty)
+func parseDatetime(value string) (time.Time, time.Time, error) {
+	min, max := time.Unix(0, 0), time.Now()
+	if value == "" {
+		return min, max, nil
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) > 2 || (len(parts) == 2 && isOpenEnded(parts[0]) && isOpenEnded(parts[1])) {
+		return min, max, fmt.Errorf("The datetime value of %v is invalid", value)
+	}
+	if len(parts) == 1 {
+		instant, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return min, max, fmt.Errorf("The datetime value of %v is invalid", value)
+		}
+		// Dates are compared exclusively, so the bounds straddle the instant
+		return instant.Add(-time.Microsecond), instant.Add(time.Microsecond), nil
+	}
+	var err error
+	if !isOpenEnded(parts[0]) {
+		if min, err = time.Parse(time.RFC3339, parts[0]); err != nil {
+			return min, max, fmt.Errorf("The datetime value of %v is invalid", value)
+		}
+	}
+	if !isOpenEnded(parts[1]) {
+		if max, err = time.Parse(time.RFC3339, parts[1]); err != nil {
+			return min, max, fmt.Errorf("The datetime value of %v is invalid", value)
+		}
+	}
+	return min, max, nil
+}
+
+func isOpenEnded(value string) bool {
+	return value == "" || value == ".."
+}
+
+// searchSTACItems finds a page of the scenes a search matches as STAC Items,
+// with the cursor of the next page, if there is one, and how many scenes
+// match in all. Searches for ids ignore the other filters and are not paged.
+func searchSTACItems(tx *sql.Tx, ctx Context, search stacSearch, baseURL string) ([]*model.STACItem, string, int, error) {
+	if len(search.Collections) > 0 && !containsString(search.Collections, stacCollectionID) {
+		return []*model.STACItem{}, "", 0, nil
+	}
+
+	var (
+		results    []*model.IndexedLandsatBrokerResult
+		nextCursor string
+		matched    int
+		err        error
+	)
+	if len(search.IDs) > 0 {
+		for _, id := range search.IDs {
+			result, err := getMetadata(tx, ctx, id, false)
+			if err == sql.ErrNoRows {
+				continue
+			} else if err != nil {
+				return nil, "", 0, err
+			}
+			results = append(results, result)
+		}
+		matched = len(results)
+	} else {
+		limit := defaultSearchLimit
+		if search.Limit != nil {
+			limit = *search.Limit
+		}
+		if results, nextCursor, matched, err = searchScenes(tx, ctx, search.sceneFilter, false, defaultSearchSort, limit, search.after, 0); err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	items := make([]*model.STACItem, len(results))
+	for i, result := range results {
+		if items[i], err = stacItem(result, baseURL); err != nil {
+			return nil, "", 0, err
+		}
+	}
+	return items, nextCursor, matched, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// stacItem creates the STAC Item of a scene, linked into the STAC API at
+// baseURL and with its preview as a thumbnail
+func stacItem(result *model.IndexedLandsatBrokerResult, baseURL string) (*model.STACItem, error) {
+	item, err := result.STACItem()
+	if err != nil {
+		return nil, err
+	}
+	linkSTACItem(item, baseURL)
+	return item, nil
+}
+
+// linkSTACItem links the STAC Item of a scene into the STAC API at baseURL
+// and adds its preview as a thumbnail
+func linkSTACItem(item *model.STACItem, baseURL string) {
+	item.Collection = stacCollectionID
+	collectionURL := baseURL + "/stac/collections/" + stacCollectionID
+	item.Links = append(item.Links,
+		model.STACLink{Href: collectionURL + "/items/" + item.ID, Rel: "self", Type: "application/geo+json"},
+		model.STACLink{Href: collectionURL, Rel: "parent", Type: "application/json"},
+		model.STACLink{Href: collectionURL, Rel: "collection", Type: "application/json"},
+		model.STACLink{Href: baseURL + "/stac", Rel: "root", Type: "application/json"},
+	)
+	item.Assets["thumbnail"] = model.STACAsset{
+		Href:  baseURL + "/localindex/preview/landsat_pds/" + item.ID + ".jpg",
+		Type:  "image/jpeg",
+		Title: "Thumbnail",
+		Roles: []string{"thumbnail"},
+	}
+}
+
+// stacCatalog is the landing page of the STAC API
+func stacCatalog(baseURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":         "Catalog",
+		"stac_version": model.STACVersion,
+		"id":           "bf-ia-broker",
+		"title":        "Beachfront imagery broker",
+		"description":  "Landsat 8 scenes indexed by the Beachfront imagery broker",
+		"conformsTo":   stacConformance,
+		"links": []model.STACLink{
+			{Href: baseURL + "/stac", Rel: "self", Type: "application/json"},
+			{Href: baseURL + "/stac", Rel: "root", Type: "application/json"},
+			{Href: baseURL + "/stac/collections", Rel: "data", Type: "application/json"},
+			{Href: baseURL + "/stac/collections/" + stacCollectionID, Rel: "child", Type: "application/json"},
+			{Href: baseURL + "/stac/search", Rel: "search", Type: "application/geo+json", Method: "GET"},
+			{Href: baseURL + "/stac/search", Rel: "search", Type: "application/geo+json", Method: "POST"},
+		},
+	}
+}
+
+// stacCollection describes the local index as a STAC Collection
+func stacCollection(baseURL string) map[string]interface{} {
+	collectionURL := baseURL + "/stac/collections/" + stacCollectionID
+	return map[string]interface{}{
+		"type":            "Collection",
+		"stac_version":    model.STACVersion,
+		"stac_extensions": []string{model.STACEOExtension, model.STACViewExtension},
+		"id":              stacCollectionID,
+		"title":           "Landsat 8 on AWS",
+		"description":     "Landsat 8 Collection 1 scenes on AWS, as indexed by the broker",
+		"license":         "PDDL-1.0",
+		"extent": map[string]interface{}{
+			"spatial":  map[string]interface{}{"bbox": [][]float64{{-180, -90, 180, 90}}},
+			"temporal": map[string]interface{}{"interval": [][]interface{}{{landsat8Start.Format(time.RFC3339), nil}}},
+		},
+		"summaries": map[string]interface{}{
+			"platform":    []string{"landsat-8"},
+			"instruments": []string{"oli", "tirs"},
+			"gsd":         []float64{15, 30, 100},
+			"eo:bands":    model.LandsatBands(),
+		},
+		"links": []model.STACLink{
+			{Href: collectionURL, Rel: "self", Type: "application/json"},
+			{Href: baseURL + "/stac", Rel: "parent", Type: "application/json"},
+			{Href: baseURL + "/stac", Rel: "root", Type: "application/json"},
+			{Href: collectionURL + "/items", Rel: "items", Type: "application/geo+json"},
+		},
+	}
+}
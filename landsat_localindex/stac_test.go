@@ -0,0 +1,135 @@
+package landsatlocalindex
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+const (
+	testingProductID = "LC08_L1TP_123045_20180102_20180104_01_T1"
+	testingSceneURL  = "https://landsat-pds.s3.amazonaws.com/c1/L8/123/045/LC08_L1TP_123045_20180102_20180104_01_T1/index.html"
+	testingBaseURL   = "https://broker.localdomain"
+)
+
+func makeTestingIndexedResult(t *testing.T) *model.IndexedLandsatBrokerResult {
+	scene := model.BrokerSearchResult{BasicBrokerResult: model.BasicBrokerResult{
+		ID:           testingProductID,
+		AcquiredDate: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		CloudCover:   12.5,
+		FileFormat:   model.GeoTIFF,
+		Geometry:     geojson.NewPolygon([][][]float64{{{30, 10}, {40, 40}, {20, 40}, {10, 20}, {30, 10}}}),
+		SensorName:   "Landsat8L1TP",
+	}}
+	result, err := indexedLandsatBrokerResultFromBrokerSearchResult(scene, testingSceneURL)
+	assert.Nil(t, err)
+	return result
+}
+
+func TestSTACItemBandAssets(t *testing.T) {
+	// Mock
+	result := makeTestingIndexedResult(t)
+
+	// Tested code
+	item, err := stacItem(result, testingBaseURL)
+
+	// Asserts
+	assert.Nil(t, err)
+	bands := model.LandsatBands()
+	for i, band := range bands {
+		asset, ok := item.Assets[band.Name]
+		if !assert.True(t, ok, "Expected an asset for band %v", band.Name) {
+			continue
+		}
+		assert.Equal(t, "https://landsat-pds.s3.amazonaws.com/c1/L8/123/045/"+testingProductID+"/"+testingProductID+"_B"+band.Name[1:]+".TIF", asset.Href)
+		assert.Equal(t, model.STACGeoTIFF, asset.Type)
+		assert.Equal(t, []string{"data"}, asset.Roles)
+		assert.Equal(t, []model.STACBand{bands[i]}, asset.EOBands)
+	}
+	assert.Equal(t, 15.0, item.Assets["B8"].GSD)
+	assert.Equal(t, 30.0, item.Assets["B4"].GSD)
+	assert.Equal(t, 100.0, item.Assets["B10"].GSD)
+	assert.Equal(t, "blue", item.Assets["B2"].EOBands[0].CommonName)
+	assert.Equal(t, "Band 2 (blue)", item.Assets["B2"].Title)
+	assert.Equal(t, len(bands)+1, len(item.Assets), "Expected the bands and a thumbnail")
+	assert.Equal(t, "landsat-8", item.Properties["platform"])
+	assert.Equal(t, 12.5, item.Properties["eo:cloud_cover"])
+}
+
+func TestSTACItemLinks(t *testing.T) {
+	// Mock
+	result := makeTestingIndexedResult(t)
+
+	// Tested code
+	item, err := stacItem(result, testingBaseURL)
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, stacCollectionID, item.Collection)
+	thumbnail := item.Assets["thumbnail"]
+	assert.Equal(t, testingBaseURL+"/localindex/preview/landsat_pds/"+testingProductID+".jpg", thumbnail.Href)
+	assert.Equal(t, "image/jpeg", thumbnail.Type)
+	assert.Equal(t, []string{"thumbnail"}, thumbnail.Roles)
+
+	links := map[string]string{}
+	for _, link := range item.Links {
+		links[link.Rel] = link.Href
+	}
+	collectionURL := testingBaseURL + "/stac/collections/" + stacCollectionID
+	assert.Equal(t, collectionURL+"/items/"+testingProductID, links["self"])
+	assert.Equal(t, collectionURL, links["collection"])
+	assert.Equal(t, collectionURL, links["parent"])
+	assert.Equal(t, testingBaseURL+"/stac", links["root"])
+}
+
+func TestSTACCollectionBands(t *testing.T) {
+	// Tested code
+	collection := stacCollection(testingBaseURL)
+
+	// Asserts
+	summaries := collection["summaries"].(map[string]interface{})
+	assert.Equal(t, model.LandsatBands(), summaries["eo:bands"])
+	assert.Equal(t, []float64{15, 30, 100}, summaries["gsd"])
+	assert.Equal(t, stacCollectionID, collection["id"])
+}
+
+func TestParseDatetime(t *testing.T) {
+	// Mock
+	instant := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Tested code
+	instantMin, instantMax, instantErr := parseDatetime("2018-01-02T03:04:05Z")
+	intervalMin, intervalMax, intervalErr := parseDatetime("2018-01-01T00:00:00Z/2018-02-01T00:00:00Z")
+	openMin, _, openErr := parseDatetime("2018-01-01T00:00:00Z/..")
+	_, _, bothOpenErr := parseDatetime("../..")
+	_, _, invalidErr := parseDatetime("yesterday")
+
+	// Asserts
+	assert.Nil(t, instantErr)
+	assert.True(t, instantMin.Before(instant) && instantMax.After(instant))
+	assert.Nil(t, intervalErr)
+	assert.Equal(t, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), intervalMin)
+	assert.Equal(t, time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), intervalMax)
+	assert.Nil(t, openErr)
+	assert.Equal(t, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), openMin)
+	assert.NotNil(t, bothOpenErr)
+	assert.NotNil(t, invalidErr)
+}
+
+func TestParseSTACSearchGet(t *testing.T) {
+	// Tested code
+	search, err := parseSTACSearch(httptest.NewRecorder(), httptest.NewRequest("GET", "/stac/search?bbox=10,10,40,40&collections=landsat_pds&ids=a,+b", nil))
+	_, invalidErr := parseSTACSearch(httptest.NewRecorder(), httptest.NewRequest("GET", "/stac/search?bbox=10,10,40", nil))
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{10, 10, 40, 40}, search.Bbox)
+	assert.NotNil(t, search.sceneFilter.AOI)
+	assert.Equal(t, []string{stacCollectionID}, search.Collections)
+	assert.Equal(t, []string{"a", "b"}, search.IDs)
+	assert.NotNil(t, invalidErr)
+}
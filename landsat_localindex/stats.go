@@ -0,0 +1,61 @@
+package landsatlocalindex
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
+)
+
+// cloudCoverBucketWidth is the width, as a percentage, of the cloud cover
+// buckets scenes are counted in
+const cloudCoverBucketWidth = 10
+
+// SceneStats is the number of scenes matching a search by acquisition date
+// and by cloud cover
+type SceneStats struct {
+	Interval   string            `json:"interval"`
+	Buckets    []dateBucket      `json:"buckets"`
+	CloudCover []cloudCoverCount `json:"cloudCover"`
+	Total      int               `json:"total"`
+}
+
+type dateBucket struct {
+	Start string `json:"start"`
+	Path  *int   `json:"path,omitempty"`
+	Row   *int   `json:"row,omitempty"`
+	Count int    `json:"count"`
+}
+
+type cloudCoverCount struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+func getSceneStats(tx *sql.Tx, filter db.SceneFilter, interval string, byPathRow bool) (*SceneStats, error) {
+	dateCounts, err := db.CountScenesByDate(tx, filter, interval, byPathRow)
+	if err != nil {
+		return nil, err
+	}
+	cloudCoverCounts, err := db.CountScenesByCloudCover(tx, filter, cloudCoverBucketWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := SceneStats{
+		Interval:   interval,
+		Buckets:    make([]dateBucket, len(dateCounts)),
+		CloudCover: make([]cloudCoverCount, len(cloudCoverCounts)),
+	}
+	for i, count := range dateCounts {
+		// Acquisition dates are stored without a time zone, in UTC
+		start := time.Date(count.Start.Year(), count.Start.Month(), count.Start.Day(), 0, 0, 0, 0, time.UTC)
+		stats.Buckets[i] = dateBucket{Start: start.Format(time.RFC3339), Path: count.Path, Row: count.Row, Count: count.Count}
+		stats.Total += count.Count
+	}
+	for i, count := range cloudCoverCounts {
+		stats.CloudCover[i] = cloudCoverCount{Min: count.Min, Max: count.Max, Count: count.Count}
+	}
+	return &stats, nil
+}
@@ -0,0 +1,22 @@
+package landsatlocalindex
+
+import (
+	"fmt"
+
+	"github.com/venicegeo/bf-ia-broker/landsat_localindex/db"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// wrs2FeatureCollection describes WRS-2 tiles as features identified by
+// their path and row, e.g. 044034 for path 44, row 34
+func wrs2FeatureCollection(tiles []db.WRS2Tile) *geojson.FeatureCollection {
+	features := make([]*geojson.Feature, len(tiles))
+	for i, tile := range tiles {
+		features[i] = geojson.NewFeature(tile.Boundary, fmt.Sprintf("%03d%03d", tile.Path, tile.Row), map[string]interface{}{
+			"path":   tile.Path,
+			"row":    tile.Row,
+			"center": []float64{tile.Center[0], tile.Center[1]},
+		})
+	}
+	return geojson.NewFeatureCollection(features)
+}
@@ -17,7 +17,9 @@ package landsat
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/venicegeo/bf-ia-broker/util"
 )
@@ -26,6 +28,30 @@ import (
 
 var landSatSceneIDPattern = regexp.MustCompile("LC8([0-9]{3})([0-9]{3}).*")
 
+// Collection 1 product IDs come in the form LC08_L1TP_006052_20170417_20170501_01_T1
+var collection1ProductIDPattern = regexp.MustCompile("^LC08_[A-Z0-9]{4}_([0-9]{3})([0-9]{3})_([0-9]{8})_")
+
+var preCollectionSceneIDPattern = regexp.MustCompile("^LC8([0-9]{3})([0-9]{3})([0-9]{4})([0-9]{3})")
+
+// SceneKey identifies the acquisition a Landsat 8 ID names, by its WRS-2 path
+// and row and its date, so an old scene ID and a Collection 1 product ID of
+// the same scene have the same key
+func SceneKey(id string) (string, bool) {
+	if m := collection1ProductIDPattern.FindStringSubmatch(id); m != nil {
+		return fmt.Sprintf("%s%s-%s", m[1], m[2], m[3]), true
+	}
+	if m := preCollectionSceneIDPattern.FindStringSubmatch(id); m != nil {
+		year, _ := strconv.Atoi(m[3])
+		day, _ := strconv.Atoi(m[4])
+		if day < 1 || day > 366 {
+			return "", false
+		}
+		date := time.Date(year, time.January, day, 0, 0, 0, 0, time.UTC)
+		return fmt.Sprintf("%s%s-%s", m[1], m[2], date.Format("20060102")), true
+	}
+	return "", false
+}
+
 // IsValidLandSatID returns whether an ID is a valid LandSat ID
 func IsValidLandSatID(sceneID string) bool {
 	return landSatSceneIDPattern.MatchString(sceneID)
@@ -0,0 +1,38 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landsat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSceneKey(t *testing.T) {
+	collection1Key, collection1OK := SceneKey("LC08_L1TP_123045_20180101_20180104_01_T1")
+	preCollectionKey, preCollectionOK := SceneKey("LC81230452018001LGN00")
+	leapYearKey, leapYearOK := SceneKey("LC81230452016366LGN00")
+	_, badDayOK := SceneKey("LC81230452018000LGN00")
+	_, sentinelOK := SceneKey("S2A_MSIL1C_20180101T000000_N0206_R001_T01ABC_20180101T000000")
+
+	assert.True(t, collection1OK)
+	assert.Equal(t, "123045-20180101", collection1Key)
+	assert.True(t, preCollectionOK)
+	assert.Equal(t, collection1Key, preCollectionKey)
+	assert.True(t, leapYearOK)
+	assert.Equal(t, "123045-20161231", leapYearKey)
+	assert.False(t, badDayOK)
+	assert.False(t, sentinelOK)
+}
@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00004, Down00004)
+}
+
+//Up00004 adds the table tracking Planet asset activations.
+func Up00004(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		CREATE TABLE public.planet_activations (
+			item_type text NOT NULL,
+			item_id text NOT NULL,
+			status text NOT NULL,
+			location text NOT NULL DEFAULT '',
+			expires_at timestamp with time zone,
+			attempts integer NOT NULL DEFAULT 0,
+			last_error text NOT NULL DEFAULT '',
+			updated_at timestamp with time zone NOT NULL DEFAULT now(),
+			CONSTRAINT planet_activations_primary PRIMARY KEY (item_type, item_id)
+		);
+		`)
+	return err
+}
+
+//Down00004 removes the table.
+func Down00004(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DROP TABLE IF EXISTS public.planet_activations;
+		`)
+	return err
+}
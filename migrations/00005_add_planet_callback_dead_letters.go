@@ -0,0 +1,42 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00005, Down00005)
+}
+
+//Up00005 adds the table recording activation callbacks that could not be delivered.
+func Up00005(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		CREATE TABLE public.planet_callback_dead_letters (
+			id serial PRIMARY KEY,
+			item_type text NOT NULL,
+			item_id text NOT NULL,
+			asset_type text NOT NULL DEFAULT 'analytic',
+			callback_url text NOT NULL,
+			payload text NOT NULL,
+			attempts integer NOT NULL,
+			last_error text NOT NULL DEFAULT '',
+			failed_at timestamp with time zone NOT NULL DEFAULT now()
+		);
+
+		CREATE INDEX idx_planet_callback_dead_letters_item
+		ON public.planet_callback_dead_letters (item_type, item_id, asset_type);
+		`)
+	return err
+}
+
+//Down00005 removes the table.
+func Down00005(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DROP TABLE IF EXISTS public.planet_callback_dead_letters;
+		`)
+	return err
+}
@@ -0,0 +1,36 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00006, Down00006)
+}
+
+//Up00006 tracks Planet activations per asset type.
+func Up00006(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		ALTER TABLE public.planet_activations
+			ADD COLUMN asset_type text NOT NULL DEFAULT 'analytic',
+			DROP CONSTRAINT planet_activations_primary,
+			ADD CONSTRAINT planet_activations_primary PRIMARY KEY (item_type, item_id, asset_type);
+		`)
+	return err
+}
+
+//Down00006 keeps only the analytic activations and drops the asset type.
+func Down00006(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DELETE FROM public.planet_activations WHERE asset_type <> 'analytic';
+		ALTER TABLE public.planet_activations
+			DROP CONSTRAINT planet_activations_primary,
+			DROP COLUMN asset_type,
+			ADD CONSTRAINT planet_activations_primary PRIMARY KEY (item_type, item_id);
+		`)
+	return err
+}
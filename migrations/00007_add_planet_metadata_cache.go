@@ -0,0 +1,36 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00007, Down00007)
+}
+
+//Up00007 adds the table caching Planet item and asset lookups.
+func Up00007(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		CREATE TABLE public.planet_metadata_cache (
+			cache_key text PRIMARY KEY,
+			value bytea NOT NULL,
+			expires_at timestamp with time zone NOT NULL
+		);
+
+		CREATE INDEX idx_planet_metadata_cache_expires_at
+		ON public.planet_metadata_cache (expires_at);
+		`)
+	return err
+}
+
+//Down00007 removes the table.
+func Down00007(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DROP TABLE IF EXISTS public.planet_metadata_cache;
+		`)
+	return err
+}
@@ -0,0 +1,34 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00008, Down00008)
+}
+
+//Up00008 indexes the orders local discovery pages through scenes in.
+func Up00008(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		CREATE INDEX idx_scenes_acquisition_date_product_id
+		ON public.scenes (acquisition_date, product_id);
+
+		CREATE INDEX idx_scenes_cloud_cover_product_id
+		ON public.scenes (cloud_cover, product_id);
+		`)
+	return err
+}
+
+//Down00008 removes the indexes.
+func Down00008(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DROP INDEX IF EXISTS public.idx_scenes_acquisition_date_product_id;
+		DROP INDEX IF EXISTS public.idx_scenes_cloud_cover_product_id;
+		`)
+	return err
+}
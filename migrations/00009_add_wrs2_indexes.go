@@ -0,0 +1,34 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00009, Down00009)
+}
+
+//Up00009 indexes the WRS-2 footprints and the scenes by path and row.
+func Up00009(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		CREATE INDEX idx_wrs2paths_boundary
+		ON public.wrs2paths USING GIST (boundary);
+
+		CREATE INDEX idx_scenes_wrs_path_row
+		ON public.scenes (wrs_path, wrs_row);
+		`)
+	return err
+}
+
+//Down00009 removes the indexes.
+func Down00009(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DROP INDEX IF EXISTS public.idx_wrs2paths_boundary;
+		DROP INDEX IF EXISTS public.idx_scenes_wrs_path_row;
+		`)
+	return err
+}
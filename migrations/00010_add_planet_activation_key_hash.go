@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00010, Down00010)
+}
+
+//Up00010 tracks Planet activations per Planet key. Activations tracked so far
+// cannot be told apart by key, so they are dropped; Planet is asked for them
+// again when they are next requested.
+func Up00010(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		DELETE FROM public.planet_activations;
+		ALTER TABLE public.planet_activations
+			ADD COLUMN key_hash text NOT NULL,
+			DROP CONSTRAINT planet_activations_primary,
+			ADD CONSTRAINT planet_activations_primary PRIMARY KEY (key_hash, item_type, item_id, asset_type);
+		`)
+	return err
+}
+
+//Down00010 drops the key hash, and with it the activations it kept apart.
+func Down00010(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DELETE FROM public.planet_activations;
+		ALTER TABLE public.planet_activations
+			DROP CONSTRAINT planet_activations_primary,
+			DROP COLUMN key_hash,
+			ADD CONSTRAINT planet_activations_primary PRIMARY KEY (item_type, item_id, asset_type);
+		`)
+	return err
+}
@@ -0,0 +1,37 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(Up00011, Down00011)
+}
+
+//Up00011 adds the table keeping activation callbacks until they are delivered.
+func Up00011(tx *sql.Tx) error {
+	// This code is executed when the migration is applied.
+	_, err := tx.Exec(`
+		CREATE TABLE public.planet_activation_callbacks (
+			key_hash text NOT NULL,
+			item_type text NOT NULL,
+			item_id text NOT NULL,
+			asset_type text NOT NULL,
+			callback_url text NOT NULL,
+			registered_at timestamp with time zone NOT NULL DEFAULT now(),
+			CONSTRAINT planet_activation_callbacks_primary PRIMARY KEY (key_hash, item_type, item_id, asset_type, callback_url)
+		);
+		`)
+	return err
+}
+
+//Down00011 removes the table.
+func Down00011(tx *sql.Tx) error {
+	// This code is executed when the migration is rolled back.
+	_, err := tx.Exec(`
+		DROP TABLE IF EXISTS public.planet_activation_callbacks;
+		`)
+	return err
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// The XML namespaces of Atom responses and OpenSearch descriptions
+const (
+	AtomNamespace           = "http://www.w3.org/2005/Atom"
+	GeoRSSNamespace         = "http://www.georss.org/georss"
+	OpenSearchNamespace     = "http://a9.com/-/spec/opensearch/1.1/"
+	OpenSearchGeoNamespace  = "http://a9.com/-/opensearch/extensions/geo/1.0/"
+	OpenSearchTimeNamespace = "http://a9.com/-/opensearch/extensions/time/1.0/"
+	OpenSearchEONamespace   = "http://a9.com/-/opensearch/extensions/eo/1.0/"
+)
+
+// AtomContentType is the media type of Atom feeds
+const AtomContentType = "application/atom+xml"
+
+// AtomFeed is a page of results as an Atom feed, with GeoRSS footprints and
+// OpenSearch response elements
+type AtomFeed struct {
+	XMLName      xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	GeoRSS       string      `xml:"xmlns:georss,attr"`
+	OpenSearch   string      `xml:"xmlns:opensearch,attr"`
+	EO           string      `xml:"xmlns:eo,attr"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	Links        []AtomLink  `xml:"link"`
+	TotalResults *int        `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int         `xml:"opensearch:itemsPerPage"`
+	Entries      []AtomEntry `xml:"entry"`
+}
+
+// AtomLink is a link of an Atom feed or entry
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// AtomEntry is a single result of an Atom feed
+type AtomEntry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    string     `xml:"updated"`
+	Summary    string     `xml:"summary,omitempty"`
+	Links      []AtomLink `xml:"link"`
+	Polygons   []string   `xml:"georss:polygon"`
+	Box        string     `xml:"georss:box,omitempty"`
+	CloudCover *float64   `xml:"eo:cloudCover,omitempty"`
+}
+
+// NewAtomFeed creates an empty feed found at selfURL
+func NewAtomFeed(title string, selfURL string) AtomFeed {
+	return AtomFeed{
+		GeoRSS:     GeoRSSNamespace,
+		OpenSearch: OpenSearchNamespace,
+		EO:         OpenSearchEONamespace,
+		ID:         selfURL,
+		Title:      title,
+		Updated:    time.Now().UTC().Format(time.RFC3339),
+		Links:      []AtomLink{{Href: selfURL, Rel: "self", Type: AtomContentType}},
+		Entries:    []AtomEntry{},
+	}
+}
+
+// SetEntries sets the entries of a feed, and how many there are on the page
+func (feed *AtomFeed) SetEntries(entries []AtomEntry) {
+	feed.Entries = entries
+	feed.ItemsPerPage = len(entries)
+}
+
+// Write writes the feed as an XML document
+func (feed AtomFeed) Write() ([]byte, error) {
+	bytes, err := xml.Marshal(feed)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bytes...), nil
+}
+
+// AtomEntries writes the results as Atom entries. Each is made from the same
+// GeoJSON feature the GeoJSON endpoints return; entryURL, if not nil, gives
+// the URL of a result's GeoJSON by its ID.
+func (result MultiBrokerResult) AtomEntries(entryURL func(id string) string) ([]AtomEntry, error) {
+	entries := make([]AtomEntry, len(result.FeatureCreators))
+	for i, creator := range result.FeatureCreators {
+		feature, err := creator.GeoJSONFeature()
+		if err != nil {
+			return nil, err
+		}
+		if entries[i], err = atomEntry(feature, entryURL); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func atomEntry(feature *geojson.Feature, entryURL func(id string) string) (AtomEntry, error) {
+	id := feature.IDStr()
+	entry := AtomEntry{
+		ID:      "urn:bf-ia-broker:" + id,
+		Title:   id,
+		Updated: feature.PropertyString("acquiredDate"),
+		Summary: fmt.Sprintf("%v scene acquired %v", feature.PropertyString("sensorName"), feature.PropertyString("acquiredDate")),
+		Links:   []AtomLink{},
+	}
+	if entryURL != nil {
+		entry.ID = entryURL(id)
+		entry.Links = append(entry.Links, AtomLink{Href: entry.ID, Rel: "alternate", Type: "application/geo+json"})
+	}
+	// Negative cloud cover means it is unknown
+	if cloudCover := feature.PropertyFloat("cloudCover"); cloudCover >= 0 {
+		entry.CloudCover = &cloudCover
+	}
+
+	var polygons [][][][]float64
+	switch geometry := feature.Geometry.(type) {
+	case *geojson.Polygon:
+		polygons = [][][][]float64{geometry.Coordinates}
+	case geojson.Polygon:
+		polygons = [][][][]float64{geometry.Coordinates}
+	case *geojson.MultiPolygon:
+		polygons = geometry.Coordinates
+	case geojson.MultiPolygon:
+		polygons = geometry.Coordinates
+	default:
+		return entry, fmt.Errorf("Result %v has a %T footprint, not a Polygon or MultiPolygon", id, feature.Geometry)
+	}
+	for _, polygon := range polygons {
+		if len(polygon) > 0 {
+			entry.Polygons = append(entry.Polygons, georssPoints(polygon[0]))
+		}
+	}
+	if bbox := feature.ForceBbox(); len(bbox) == 4 {
+		entry.Box = georssPoints([][]float64{{bbox[0], bbox[1]}, {bbox[2], bbox[3]}})
+	}
+	return entry, nil
+}
+
+// georssPoints writes positions as GeoRSS does, latitude first
+func georssPoints(positions [][]float64) string {
+	values := make([]string, 0, 2*len(positions))
+	for _, position := range positions {
+		values = append(values, strconv.FormatFloat(position[1], 'f', -1, 64), strconv.FormatFloat(position[0], 'f', -1, 64))
+	}
+	return strings.Join(values, " ")
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiBrokerResult_AtomEntries(t *testing.T) {
+	// Mock
+	unknownCloudCover := mockBasicBrokerResult
+	unknownCloudCover.ID = "test-id-456"
+	unknownCloudCover.CloudCover = -1
+	result := MultiBrokerResult{FeatureCreators: []GeoJSONFeatureCreator{
+		BrokerSearchResult{BasicBrokerResult: mockBasicBrokerResult},
+		BrokerSearchResult{BasicBrokerResult: unknownCloudCover},
+	}}
+
+	// Tested code
+	entries, err := result.AtomEntries(func(id string) string { return "https://broker.localhost/scenes/" + id })
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "https://broker.localhost/scenes/test-id-123", entries[0].ID)
+	assert.Equal(t, "test-id-123", entries[0].Title)
+	assert.Equal(t, "1970-01-01T00:02:03Z", entries[0].Updated)
+	assert.Equal(t, []AtomLink{{Href: "https://broker.localhost/scenes/test-id-123", Rel: "alternate", Type: "application/geo+json"}}, entries[0].Links)
+	assert.Equal(t, []string{"10 30 40 40 40 20 20 10 10 30"}, entries[0].Polygons)
+	assert.Equal(t, "10 10 40 40", entries[0].Box)
+	assert.Equal(t, mockBasicBrokerResult.CloudCover, *entries[0].CloudCover)
+	assert.Nil(t, entries[1].CloudCover)
+}
+
+func TestAtomFeed_Write(t *testing.T) {
+	// Mock
+	entries, err := MultiBrokerResult{FeatureCreators: []GeoJSONFeatureCreator{
+		BrokerSearchResult{BasicBrokerResult: mockBasicBrokerResult},
+	}}.AtomEntries(nil)
+	assert.Nil(t, err)
+	total := 10
+	feed := NewAtomFeed("Test scenes", "https://broker.localhost/discover")
+	feed.SetEntries(entries)
+	feed.TotalResults = &total
+
+	// Tested code
+	bytes, err := feed.Write()
+
+	// Asserts
+	assert.Nil(t, err)
+	document := string(bytes)
+	assert.Contains(t, document, `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:georss="http://www.georss.org/georss"`)
+	assert.Contains(t, document, `<opensearch:totalResults>10</opensearch:totalResults><opensearch:itemsPerPage>1</opensearch:itemsPerPage>`)
+	assert.Contains(t, document, `<id>urn:bf-ia-broker:test-id-123</id>`)
+	assert.Contains(t, document, `<georss:polygon>10 30 40 40 40 20 20 10 10 30</georss:polygon>`)
+	assert.Contains(t, document, `<eo:cloudCover>50.123</eo:cloudCover>`)
+
+	var parsed struct {
+		Entries []struct {
+			Title string `xml:"title"`
+		} `xml:"entry"`
+	}
+	assert.Nil(t, xml.Unmarshal(bytes, &parsed))
+	assert.Equal(t, "test-id-123", parsed.Entries[0].Title)
+}
@@ -0,0 +1,224 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// AOI is an area of interest used to search for scenes; it is always either a
+// *geojson.Polygon or a *geojson.MultiPolygon
+type AOI interface {
+	ForceBbox() geojson.BoundingBox
+	Map() map[string]interface{}
+	String() string
+	WKT() string
+}
+
+// NewAOI parses an area of interest from GeoJSON bytes containing a Polygon
+// or MultiPolygon, or a Feature whose geometry is one of those
+func NewAOI(bytes []byte) (AOI, error) {
+	parsed, err := geojson.Parse(bytes)
+	if err != nil {
+		return nil, err
+	}
+	if feature, ok := parsed.(*geojson.Feature); ok {
+		parsed = feature.Geometry
+	}
+	switch geometry := parsed.(type) {
+	case *geojson.Polygon:
+		if err = validatePolygonRings(geometry.Coordinates); err != nil {
+			return nil, err
+		}
+		return geometry, nil
+	case *geojson.MultiPolygon:
+		if len(geometry.Coordinates) == 0 {
+			return nil, errors.New("MultiPolygon has no polygons")
+		}
+		for _, polygon := range geometry.Coordinates {
+			if err = validatePolygonRings(polygon); err != nil {
+				return nil, err
+			}
+		}
+		return geometry, nil
+	default:
+		return nil, fmt.Errorf("Expected a GeoJSON Polygon or MultiPolygon and got %T", parsed)
+	}
+}
+
+// NewAOIFromBoundingBox creates a rectangular area of interest from a bounding box
+func NewAOIFromBoundingBox(bbox geojson.BoundingBox) (AOI, error) {
+	if polygon, ok := bbox.Geometry().(*geojson.Polygon); ok {
+		return polygon, nil
+	}
+	return nil, fmt.Errorf("Bounding box %v does not describe an area", bbox)
+}
+
+func validatePolygonRings(rings [][][]float64) error {
+	if len(rings) == 0 {
+		return errors.New("Polygon has no rings")
+	}
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			return errors.New("Polygon rings must have at least four positions")
+		}
+		for _, position := range ring {
+			if len(position) < 2 {
+				return errors.New("Polygon positions must have at least two coordinates")
+			}
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return errors.New("Polygon rings must be closed")
+		}
+	}
+	return nil
+}
+
+// AOICoverage returns the percentage (0-100) of the area of interest that is
+// covered by a scene footprint (a Polygon or MultiPolygon). Areas are planar,
+// in degrees, and each footprint polygon is treated as its convex hull, which
+// holds for the quadrilateral footprints imagery providers return.
+func AOICoverage(aoi AOI, footprint interface{}) float64 {
+	aoiPolygons := polygonsOf(aoi)
+	footprintPolygons := polygonsOf(footprint)
+
+	aoiArea := 0.0
+	coveredArea := 0.0
+	for _, polygon := range aoiPolygons {
+		aoiArea += polygonArea(polygon)
+		for _, footprintPolygon := range footprintPolygons {
+			hull := convexHull(footprintPolygon[0])
+			if len(hull) < 3 {
+				continue
+			}
+			// Holes in the AOI are subtracted from whatever part of it is covered
+			coveredArea += ringArea(clipRing(polygon[0], hull))
+			for _, hole := range polygon[1:] {
+				coveredArea -= ringArea(clipRing(hole, hull))
+			}
+		}
+	}
+
+	if aoiArea <= 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(100, coveredArea/aoiArea*100))
+}
+
+func polygonsOf(geometry interface{}) [][][][]float64 {
+	switch it := geometry.(type) {
+	case *geojson.Polygon:
+		return [][][][]float64{it.Coordinates}
+	case geojson.Polygon:
+		return [][][][]float64{it.Coordinates}
+	case *geojson.MultiPolygon:
+		return it.Coordinates
+	case geojson.MultiPolygon:
+		return it.Coordinates
+	default:
+		return nil
+	}
+}
+
+func polygonArea(rings [][][]float64) float64 {
+	if len(rings) == 0 {
+		return 0
+	}
+	area := ringArea(rings[0])
+	for _, hole := range rings[1:] {
+		area -= ringArea(hole)
+	}
+	return area
+}
+
+// ringArea is the absolute area of a ring, using the shoelace formula
+func ringArea(ring [][]float64) float64 {
+	return math.Abs(signedRingArea(ring))
+}
+
+func signedRingArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+// convexHull returns the counter-clockwise convex hull of a ring, open (the
+// first position is not repeated at the end)
+func convexHull(ring [][]float64) [][]float64 {
+	points := make([][]float64, len(ring))
+	copy(points, ring)
+	sort.Slice(points, func(i, j int) bool {
+		if points[i][0] == points[j][0] {
+			return points[i][1] < points[j][1]
+		}
+		return points[i][0] < points[j][0]
+	})
+
+	hull := make([][]float64, 0, 2*len(points))
+	// Lower hull, then upper hull (Andrew's monotone chain)
+	for _, point := range points {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], point) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, point)
+	}
+	lowerSize := len(hull) + 1
+	for i := len(points) - 2; i >= 0; i-- {
+		for len(hull) >= lowerSize && cross(hull[len(hull)-2], hull[len(hull)-1], points[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, points[i])
+	}
+	if len(hull) > 0 {
+		hull = hull[:len(hull)-1]
+	}
+	return hull
+}
+
+func cross(o, a, b []float64) float64 {
+	return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+}
+
+// clipRing clips a (possibly concave) ring against a counter-clockwise convex
+// ring, using the Sutherland-Hodgman algorithm
+func clipRing(ring [][]float64, convex [][]float64) [][]float64 {
+	output := ring
+	for i := range convex {
+		if len(output) == 0 {
+			break
+		}
+		edgeStart, edgeEnd := convex[i], convex[(i+1)%len(convex)]
+		input := output
+		output = make([][]float64, 0, len(input))
+		previous := input[len(input)-1]
+		for _, current := range input {
+			currentInside := cross(edgeStart, edgeEnd, current) >= 0
+			previousInside := cross(edgeStart, edgeEnd, previous) >= 0
+			if currentInside {
+				if !previousInside {
+					output = append(output, intersection(previous, current, edgeStart, edgeEnd))
+				}
+				output = append(output, current)
+			} else if previousInside {
+				output = append(output, intersection(previous, current, edgeStart, edgeEnd))
+			}
+			previous = current
+		}
+	}
+	return output
+}
+
+// intersection returns where the segment p1-p2 crosses the line through e1-e2
+func intersection(p1, p2, e1, e2 []float64) []float64 {
+	d1 := cross(e1, e2, p1)
+	d2 := cross(e1, e2, p2)
+	t := d1 / (d1 - d2)
+	return []float64{p1[0] + t*(p2[0]-p1[0]), p1[1] + t*(p2[1]-p1[1])}
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+func TestNewAOI_Polygon(t *testing.T) {
+	// Tested code
+	aoi, err := NewAOI([]byte(`{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,1],[0,1],[0,0]]]}`))
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.IsType(t, &geojson.Polygon{}, aoi)
+}
+
+func TestNewAOI_FeatureWithMultiPolygon(t *testing.T) {
+	// Tested code
+	aoi, err := NewAOI([]byte(`{"type":"Feature","properties":{},"geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}}`))
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.IsType(t, &geojson.MultiPolygon{}, aoi)
+}
+
+func TestNewAOI_Error(t *testing.T) {
+	for _, body := range []string{
+		`not json`,
+		`{"type":"Point","coordinates":[1,2]}`,
+		`{"type":"Polygon","coordinates":[]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1]]]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`,
+	} {
+		// Tested code
+		_, err := NewAOI([]byte(body))
+
+		// Asserts
+		assert.NotNil(t, err, "Expected %v to be rejected", body)
+	}
+}
+
+func TestNewAOIFromBoundingBox(t *testing.T) {
+	// Mock
+	bbox, _ := geojson.NewBoundingBox("0,0,2,2")
+
+	// Tested code
+	aoi, err := NewAOIFromBoundingBox(bbox)
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, bbox, aoi.ForceBbox())
+}
+
+func TestAOICoverage(t *testing.T) {
+	// Mock
+	footprint := geojson.NewPolygon([][][]float64{{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}})
+	inside := geojson.NewPolygon([][][]float64{{{1, 1}, {2, 1}, {2, 2}, {1, 2}, {1, 1}}})
+	half := geojson.NewPolygon([][][]float64{{{8, 0}, {12, 0}, {12, 1}, {8, 1}, {8, 0}}})
+	outside := geojson.NewPolygon([][][]float64{{{20, 20}, {21, 20}, {21, 21}, {20, 21}, {20, 20}}})
+	// A concave "L" shaped AOI, a third of which is inside the footprint
+	concave := geojson.NewPolygon([][][]float64{{{8, 8}, {12, 8}, {12, 10}, {10, 10}, {10, 12}, {8, 12}, {8, 8}}})
+	withHole := geojson.NewPolygon([][][]float64{
+		{{1, 1}, {5, 1}, {5, 5}, {1, 5}, {1, 1}},
+		{{2, 2}, {3, 2}, {3, 3}, {2, 3}, {2, 2}},
+	})
+
+	// Asserts
+	assert.InDelta(t, 100.0, AOICoverage(inside, footprint), 1e-9)
+	assert.InDelta(t, 50.0, AOICoverage(half, footprint), 1e-9)
+	assert.InDelta(t, 0.0, AOICoverage(outside, footprint), 1e-9)
+	assert.InDelta(t, 100.0/3, AOICoverage(concave, footprint), 1e-9)
+	assert.InDelta(t, 100.0, AOICoverage(withHole, footprint), 1e-9)
+	assert.InDelta(t, 2.0, AOICoverage(footprint, half), 1e-9)
+	assert.InDelta(t, 0.0, AOICoverage(inside, nil), 1e-9)
+}
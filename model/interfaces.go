@@ -28,3 +28,27 @@ type GeoJSONFeatureCollectionCreator interface {
 type GeoJSONFeatureMixin interface {
 	Apply(*geojson.Feature) error
 }
+
+// STACItemCreator is an interface for data that can convert itself to a STAC Item
+type STACItemCreator interface {
+	STACItem() (*STACItem, error)
+}
+
+// GeoJSONFeatureSTACItemCreator is an interface for results that can be
+// written either as GeoJSON or as STAC
+type GeoJSONFeatureSTACItemCreator interface {
+	GeoJSONFeatureCreator
+	STACItemCreator
+}
+
+// STACMixin is an interface for data that can be used to augment an existing STAC Item
+type STACMixin interface {
+	ApplySTAC(*STACItem) error
+}
+
+// BasicResultCreator is an interface for results that can be normalized to
+// the fields common to all results; every result embedding a
+// BasicBrokerResult is one
+type BasicResultCreator interface {
+	BasicResult() BasicBrokerResult
+}
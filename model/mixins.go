@@ -20,6 +20,16 @@ type PlanetAssetMetadata struct {
 	Permissions   []string
 	Status        string
 	Type          string
+	// Assets lists every asset available for the scene, by asset type
+	Assets map[string]PlanetAssetSummary
+}
+
+// PlanetAssetSummary describes one of the assets available for a scene
+type PlanetAssetSummary struct {
+	Status        string `json:"status"`
+	ActivationURL string `json:"activate,omitempty"`
+	Location      string `json:"location,omitempty"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
 }
 
 // Apply implements the GeoJSONFeatureMixin interface
@@ -30,6 +40,9 @@ func (pam PlanetAssetMetadata) Apply(feature *geojson.Feature) error {
 	feature.Properties["status"] = pam.Status
 	feature.Properties["type"] = pam.Type
 	feature.Properties["srcHorizontalAccuracy"] = "<10m RMSE"
+	if len(pam.Assets) > 0 {
+		feature.Properties["assets"] = pam.Assets
+	}
 	return nil
 }
 
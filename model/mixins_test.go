@@ -36,6 +36,28 @@ func TestPlanetAssetMetadata_Apply(t *testing.T) {
 	assert.Equal(t, "test", feature.PropertyString("type"))
 }
 
+func TestPlanetAssetMetadata_Apply_Assets(t *testing.T) {
+	// Mock
+	feature := geojson.NewFeature(nil, "test-id", nil)
+	withAssets := PlanetAssetMetadata{
+		Status: "inactive",
+		Type:   "udm2",
+		Assets: map[string]PlanetAssetSummary{
+			"udm2": {Status: "inactive", ActivationURL: "https://example.localdomain/udm2/activate"},
+		},
+	}
+	withoutAssets := geojson.NewFeature(nil, "test-id", nil)
+
+	// Tested code
+	err := withAssets.Apply(feature)
+	PlanetAssetMetadata{}.Apply(withoutAssets)
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, withAssets.Assets, feature.Properties["assets"])
+	assert.NotContains(t, withoutAssets.Properties, "assets")
+}
+
 func TestTidesData_Apply(t *testing.T) {
 	// Mock
 	feature := geojson.NewFeature(nil, "test-id", nil)
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/xml"
+)
+
+// OpenSearchDescriptionContentType is the media type of OpenSearch
+// description documents
+const OpenSearchDescriptionContentType = "application/opensearchdescription+xml"
+
+// OpenSearchDescription is an OpenSearch description document, with the Geo,
+// Time and EO extensions
+type OpenSearchDescription struct {
+	XMLName     xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	Geo         string          `xml:"xmlns:geo,attr"`
+	Time        string          `xml:"xmlns:time,attr"`
+	EO          string          `xml:"xmlns:eo,attr"`
+	ShortName   string          `xml:"ShortName"`
+	Description string          `xml:"Description"`
+	URLs        []OpenSearchURL `xml:"Url"`
+}
+
+// OpenSearchURL is a URL template of an OpenSearch description
+type OpenSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Rel      string `xml:"rel,attr,omitempty"`
+	Template string `xml:"template,attr"`
+}
+
+// NewOpenSearchDescription describes searches of discoverURL, which takes the
+// broker's discovery parameters: bbox, acquiredDate, maxAcquiredDate,
+// cloudCover (a maximum percentage) and limit. Results are GeoJSON, or Atom
+// with format=atom.
+func NewOpenSearchDescription(shortName string, description string, discoverURL string, selfURL string, bboxRequired bool) OpenSearchDescription {
+	box := "{geo:box}"
+	if !bboxRequired {
+		box = "{geo:box?}"
+	}
+	template := discoverURL + "?bbox=" + box +
+		"&acquiredDate={time:start?}&maxAcquiredDate={time:end?}&cloudCover={eo:cloudCover?}&limit={count?}"
+	return OpenSearchDescription{
+		Geo:         OpenSearchGeoNamespace,
+		Time:        OpenSearchTimeNamespace,
+		EO:          OpenSearchEONamespace,
+		ShortName:   shortName,
+		Description: description,
+		URLs: []OpenSearchURL{
+			{Type: AtomContentType, Rel: "results", Template: template + "&format=atom"},
+			{Type: "application/geo+json", Rel: "results", Template: template},
+			{Type: OpenSearchDescriptionContentType, Rel: "self", Template: selfURL},
+		},
+	}
+}
+
+// Write writes the description as an XML document
+func (description OpenSearchDescription) Write() ([]byte, error) {
+	bytes, err := xml.Marshal(description)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bytes...), nil
+}
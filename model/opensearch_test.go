@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOpenSearchDescription(t *testing.T) {
+	// Tested code
+	required := NewOpenSearchDescription("Test", "Test scenes", "https://broker.localhost/discover", "https://broker.localhost/opensearch", true)
+	optional := NewOpenSearchDescription("Test", "Test scenes", "https://broker.localhost/discover", "https://broker.localhost/opensearch", false)
+	bytes, err := required.Write()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Len(t, required.URLs, 3)
+	assert.Equal(t, AtomContentType, required.URLs[0].Type)
+	assert.Equal(t, "https://broker.localhost/discover?bbox={geo:box}&acquiredDate={time:start?}&maxAcquiredDate={time:end?}&cloudCover={eo:cloudCover?}&limit={count?}&format=atom", required.URLs[0].Template)
+	assert.Contains(t, optional.URLs[1].Template, "bbox={geo:box?}&")
+	assert.Equal(t, "https://broker.localhost/opensearch", required.URLs[2].Template)
+	assert.Contains(t, string(bytes), `<OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/" xmlns:geo="http://a9.com/-/opensearch/extensions/geo/1.0/"`)
+	assert.Contains(t, string(bytes), `<ShortName>Test</ShortName>`)
+}
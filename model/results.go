@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/venicegeo/geojson-go/geojson"
@@ -17,6 +18,10 @@ type BasicBrokerResult struct {
 	FileFormat   BrokerFileFormat
 	DataType     string
 	BoundingBox  geojson.BoundingBox
+	AOICoverage  *float64
+	ItemType     string
+	// Downloadable is nil unless the provider's permissions were checked
+	Downloadable *bool
 }
 
 // GeoJSONFeature implements the GeoJSONFeatureCreator interface
@@ -27,6 +32,15 @@ func (br BasicBrokerResult) GeoJSONFeature() (*geojson.Feature, error) {
 		"acquiredDate": br.AcquiredDate.Format(StandardTimeLayout),
 		"sensorName":   br.SensorName,
 	})
+	if br.AOICoverage != nil {
+		f.Properties["aoiCoverage"] = *br.AOICoverage
+	}
+	if br.ItemType != "" {
+		f.Properties["itemType"] = br.ItemType
+	}
+	if br.Downloadable != nil {
+		f.Properties["downloadable"] = *br.Downloadable
+	}
 	if br.BoundingBox != nil {
 		f.Bbox = br.BoundingBox
 	} else {
@@ -178,6 +192,11 @@ func (result IndexedLandsatBrokerResult) GeoJSONFeature() (*geojson.Feature, err
 	return feature, nil
 }
 
+// BasicResult implements the BasicResultCreator interface
+func (br BasicBrokerResult) BasicResult() BasicBrokerResult {
+	return br
+}
+
 // MultiBrokerResult is a container type for bundling multiple results together,
 // e.g. as results from a search endpoint
 type MultiBrokerResult struct {
@@ -197,3 +216,47 @@ func (result MultiBrokerResult) GeoJSONFeatureCollection() (*geojson.FeatureColl
 
 	return geojson.NewFeatureCollection(features), nil
 }
+
+// BasicResults normalizes the results to the fields common to all results;
+// each must be a BasicResultCreator
+func (result MultiBrokerResult) BasicResults() ([]BasicBrokerResult, error) {
+	results := make([]BasicBrokerResult, len(result.FeatureCreators))
+	for i, creator := range result.FeatureCreators {
+		basicCreator, ok := creator.(BasicResultCreator)
+		if !ok {
+			return nil, fmt.Errorf("Result %T cannot be normalized", creator)
+		}
+		results[i] = basicCreator.BasicResult()
+	}
+	return results, nil
+}
+
+// Link is a hypermedia link to a related resource, such as the next page of
+// results; a link that must be POSTed gives its Method and the Body to send
+type Link struct {
+	Href   string      `json:"href"`
+	Rel    string      `json:"rel"`
+	Type   string      `json:"type,omitempty"`
+	Method string      `json:"method,omitempty"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// PagedFeatureCollection is a GeoJSON feature collection carrying links to
+// further pages of results as a foreign member
+type PagedFeatureCollection struct {
+	*geojson.FeatureCollection
+	Links []Link `json:"links,omitempty"`
+	// NumberMatched is how many results the search matched over all pages,
+	// when that is known
+	NumberMatched *int `json:"numberMatched,omitempty"`
+}
+
+// NewPagedFeatureCollection wraps a feature collection, adding a "next" link
+// if nextURL is not empty
+func NewPagedFeatureCollection(fc *geojson.FeatureCollection, nextURL string) PagedFeatureCollection {
+	result := PagedFeatureCollection{FeatureCollection: fc}
+	if nextURL != "" {
+		result.Links = append(result.Links, Link{Href: nextURL, Rel: "next", Type: "application/geo+json"})
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"net/url"
 	"testing"
 	"time"
@@ -109,6 +110,39 @@ func TestBasicBrokerResult_GeoJSONFeature(t *testing.T) {
 	assert.Nil(t, feature.Bbox.Valid())
 }
 
+func TestBasicBrokerResult_GeoJSONFeature_ItemType(t *testing.T) {
+	// Mock
+	result := mockBasicBrokerResult
+	result.ItemType = "PSOrthoTile"
+
+	// Tested code
+	feature, err := result.GeoJSONFeature()
+	untaggedFeature, _ := mockBasicBrokerResult.GeoJSONFeature()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, "PSOrthoTile", feature.PropertyString("itemType"))
+	_, ok := untaggedFeature.Properties["itemType"]
+	assert.False(t, ok, "Expected no itemType on an untagged result")
+}
+
+func TestBasicBrokerResult_GeoJSONFeature_Downloadable(t *testing.T) {
+	// Mock
+	result := mockBasicBrokerResult
+	downloadable := false
+	result.Downloadable = &downloadable
+
+	// Tested code
+	feature, err := result.GeoJSONFeature()
+	uncheckedFeature, _ := mockBasicBrokerResult.GeoJSONFeature()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, false, feature.Properties["downloadable"])
+	_, ok := uncheckedFeature.Properties["downloadable"]
+	assert.False(t, ok, "Expected no downloadable flag when permissions were not checked")
+}
+
 func TestSearchBrokerResult_GeoJSONFeature_WithTides(t *testing.T) {
 	// Mock
 	result := BrokerSearchResult{
@@ -286,3 +320,90 @@ func TestMultiBrokerResult_GeoJSONFeatureCollection(t *testing.T) {
 		assertFeatureContainsBasicBrokerResult(t, feature, mockBasicBrokerResult)
 	}
 }
+
+// featureOnlyCreator is a GeoJSONFeatureCreator with no BasicBrokerResult
+type featureOnlyCreator struct{}
+
+func (featureOnlyCreator) GeoJSONFeature() (*geojson.Feature, error) {
+	return geojson.NewFeature(mockPolygon, "feature-only", nil), nil
+}
+
+func TestMultiBrokerResult_BasicResults(t *testing.T) {
+	// Mock
+	result := MultiBrokerResult{
+		FeatureCreators: []GeoJSONFeatureCreator{
+			mockBasicBrokerResult,
+			PlanetLandsatBrokerResult{BasicBrokerResult: mockBasicBrokerResult, LandsatS3Bands: mockLandsatS3Bands, TidesData: &mockTidesData},
+			IndexedLandsatBrokerResult{BasicBrokerResult: mockBasicBrokerResult, LandsatS3Bands: mockLandsatS3Bands},
+		},
+	}
+
+	// Tested code
+	results, err := result.BasicResults()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, []BasicBrokerResult{mockBasicBrokerResult, mockBasicBrokerResult, mockBasicBrokerResult}, results)
+}
+
+func TestMultiBrokerResult_BasicResults_NotNormalizable(t *testing.T) {
+	// Mock
+	result := MultiBrokerResult{
+		FeatureCreators: []GeoJSONFeatureCreator{mockBasicBrokerResult, featureOnlyCreator{}},
+	}
+
+	// Tested code
+	results, err := result.BasicResults()
+
+	// Asserts
+	assert.NotNil(t, err)
+	assert.Nil(t, results)
+}
+
+func TestNewPagedFeatureCollection_WithNext(t *testing.T) {
+	// Mock
+	fc, _ := MultiBrokerResult{FeatureCreators: []GeoJSONFeatureCreator{mockBasicBrokerResult}}.GeoJSONFeatureCollection()
+
+	// Tested code
+	paged := NewPagedFeatureCollection(fc, "/discover?cursor=abc")
+	data, err := json.Marshal(paged)
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Len(t, paged.Links, 1)
+	assert.Equal(t, "next", paged.Links[0].Rel)
+	assert.Equal(t, "/discover?cursor=abc", paged.Links[0].Href)
+	assert.Contains(t, string(data), `"features":[`)
+	assert.Contains(t, string(data), `"links":[{"href":"/discover?cursor=abc","rel":"next"`)
+}
+
+func TestNewPagedFeatureCollection_NoNext(t *testing.T) {
+	// Mock
+	fc, _ := MultiBrokerResult{FeatureCreators: []GeoJSONFeatureCreator{mockBasicBrokerResult}}.GeoJSONFeatureCollection()
+
+	// Tested code
+	paged := NewPagedFeatureCollection(fc, "")
+	data, err := json.Marshal(paged)
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Empty(t, paged.Links)
+	assert.NotContains(t, string(data), `"links"`)
+}
+
+func TestNewPagedFeatureCollection_NumberMatched(t *testing.T) {
+	// Mock
+	fc, _ := MultiBrokerResult{FeatureCreators: []GeoJSONFeatureCreator{mockBasicBrokerResult}}.GeoJSONFeatureCollection()
+	numberMatched := 250
+
+	// Tested code
+	paged := NewPagedFeatureCollection(fc, "/discover?cursor=abc")
+	paged.NumberMatched = &numberMatched
+	data, err := json.Marshal(paged)
+	unmatched, _ := json.Marshal(NewPagedFeatureCollection(fc, ""))
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"numberMatched":250`)
+	assert.NotContains(t, string(unmatched), `"numberMatched"`)
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortField is a property search results may be sorted by
+type SortField string
+
+// The properties search results may be sorted by
+const (
+	SortByAcquiredDate SortField = "acquiredDate"
+	SortByCloudCover   SortField = "cloudCover"
+	SortByAOICoverage  SortField = "aoiCoverage"
+	SortByTide         SortField = "tide"
+)
+
+var sortFields = []SortField{SortByAcquiredDate, SortByCloudCover, SortByAOICoverage, SortByTide}
+
+const invalidSort = "The sort value of %v is invalid; it must be one of acquiredDate, cloudCover, aoiCoverage or tide, prefixed with - to sort descending."
+const invalidTideSort = "Sorting by tide requires tides=true."
+const invalidLimit = "The limit value of %v is invalid; it must be between 1 and %d."
+
+// SearchSort is how search results are ordered
+type SearchSort struct {
+	Field      SortField
+	Descending bool
+}
+
+// String returns the sort as it is given in a sort parameter
+func (s SearchSort) String() string {
+	if s.Descending {
+		return "-" + string(s.Field)
+	}
+	return string(s.Field)
+}
+
+// ParseSearchSort parses a sort parameter, a sort field optionally prefixed
+// with - (descending) or + (ascending, the default). An empty value is nil.
+// Sorting by tide is only possible when tides are requested.
+func ParseSearchSort(value string, withTides bool) (*SearchSort, error) {
+	if value == "" {
+		return nil, nil
+	}
+	searchSort := SearchSort{Field: SortField(strings.TrimLeft(value, "+-"))}
+	searchSort.Descending = strings.HasPrefix(value, "-")
+	if len(value)-len(searchSort.Field) > 1 {
+		return nil, fmt.Errorf(invalidSort, value)
+	}
+	valid := false
+	for _, field := range sortFields {
+		valid = valid || field == searchSort.Field
+	}
+	if !valid {
+		return nil, fmt.Errorf(invalidSort, value)
+	}
+	if searchSort.Field == SortByTide && !withTides {
+		return nil, errors.New(invalidTideSort)
+	}
+	return &searchSort, nil
+}
+
+// ParseSearchLimit parses a limit parameter, which must lie between 1 and
+// max. An empty value is 0, meaning the search's own default.
+func ParseSearchLimit(value string, max int) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > max {
+		return 0, fmt.Errorf(invalidLimit, value, max)
+	}
+	return limit, nil
+}
+
+// SortSearchResults sorts search results in place. Results missing the sort
+// field (no AOI coverage, no tide data) come last in either direction.
+func SortSearchResults(results []BrokerSearchResult, searchSort SearchSort) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return LessSearchResult(results[i], results[j], searchSort)
+	})
+}
+
+// LessSearchResult reports whether one result sorts before another, as
+// SortSearchResults sorts them
+func LessSearchResult(resultA BrokerSearchResult, resultB BrokerSearchResult, searchSort SearchSort) bool {
+	a, aOK := sortValue(resultA, searchSort.Field)
+	b, bOK := sortValue(resultB, searchSort.Field)
+	switch {
+	case !aOK || !bOK:
+		return aOK && !bOK
+	case searchSort.Descending:
+		return a > b
+	default:
+		return a < b
+	}
+}
+
+func sortValue(result BrokerSearchResult, field SortField) (float64, bool) {
+	switch field {
+	case SortByAcquiredDate:
+		return float64(result.AcquiredDate.UnixNano()), true
+	case SortByCloudCover:
+		// Negative cloud cover means it is unknown
+		return result.CloudCover, result.CloudCover >= 0
+	case SortByAOICoverage:
+		if result.AOICoverage == nil {
+			return 0, false
+		}
+		return *result.AOICoverage, true
+	case SortByTide:
+		if result.TidesData == nil {
+			return 0, false
+		}
+		return result.Current, true
+	}
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearchSort(t *testing.T) {
+	// Tested code
+	empty, emptyErr := ParseSearchSort("", false)
+	descending, descendingErr := ParseSearchSort("-cloudCover", false)
+	ascending, ascendingErr := ParseSearchSort("+aoiCoverage", false)
+	tide, tideErr := ParseSearchSort("tide", true)
+	_, tideWithoutTidesErr := ParseSearchSort("tide", false)
+	_, unknownErr := ParseSearchSort("resolution", false)
+	_, doubledErr := ParseSearchSort("--acquiredDate", false)
+
+	// Asserts
+	assert.Nil(t, emptyErr)
+	assert.Nil(t, empty)
+	assert.Nil(t, descendingErr)
+	assert.Equal(t, SearchSort{Field: SortByCloudCover, Descending: true}, *descending)
+	assert.Equal(t, "-cloudCover", descending.String())
+	assert.Nil(t, ascendingErr)
+	assert.Equal(t, SearchSort{Field: SortByAOICoverage}, *ascending)
+	assert.Nil(t, tideErr)
+	assert.Equal(t, SortByTide, tide.Field)
+	assert.NotNil(t, tideWithoutTidesErr)
+	assert.NotNil(t, unknownErr)
+	assert.NotNil(t, doubledErr)
+}
+
+func TestParseSearchLimit(t *testing.T) {
+	// Tested code
+	empty, emptyErr := ParseSearchLimit("", 100)
+	limit, limitErr := ParseSearchLimit("100", 100)
+	_, tooLargeErr := ParseSearchLimit("101", 100)
+	_, zeroErr := ParseSearchLimit("0", 100)
+	_, invalidErr := ParseSearchLimit("ten", 100)
+
+	// Asserts
+	assert.Nil(t, emptyErr)
+	assert.Equal(t, 0, empty)
+	assert.Nil(t, limitErr)
+	assert.Equal(t, 100, limit)
+	assert.NotNil(t, tooLargeErr)
+	assert.NotNil(t, zeroErr)
+	assert.NotNil(t, invalidErr)
+}
+
+func TestSortSearchResults(t *testing.T) {
+	// Mock
+	coverage := func(value float64) *float64 { return &value }
+	results := []BrokerSearchResult{
+		{BasicBrokerResult: BasicBrokerResult{ID: "a", CloudCover: 20, AcquiredDate: time.Unix(300, 0), AOICoverage: coverage(50)}, TidesData: &TidesData{Current: 0.5}},
+		{BasicBrokerResult: BasicBrokerResult{ID: "b", CloudCover: -1, AcquiredDate: time.Unix(100, 0)}},
+		{BasicBrokerResult: BasicBrokerResult{ID: "c", CloudCover: 5, AcquiredDate: time.Unix(200, 0), AOICoverage: coverage(90)}, TidesData: &TidesData{Current: -0.5}},
+	}
+	ids := func() []string {
+		sorted := make([]string, len(results))
+		for i, result := range results {
+			sorted[i] = result.ID
+		}
+		return sorted
+	}
+
+	// Tested code and asserts
+	SortSearchResults(results, SearchSort{Field: SortByCloudCover})
+	assert.Equal(t, []string{"c", "a", "b"}, ids(), "Unknown cloud cover should come last")
+	SortSearchResults(results, SearchSort{Field: SortByAcquiredDate, Descending: true})
+	assert.Equal(t, []string{"a", "c", "b"}, ids())
+	SortSearchResults(results, SearchSort{Field: SortByAOICoverage, Descending: true})
+	assert.Equal(t, []string{"c", "a", "b"}, ids())
+	SortSearchResults(results, SearchSort{Field: SortByTide})
+	assert.Equal(t, []string{"c", "a", "b"}, ids())
+}
@@ -0,0 +1,322 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// STACVersion is the version of the SpatioTemporal Asset Catalog spec items
+// are written to
+const STACVersion = "1.0.0"
+
+// The STAC extensions items may use
+const (
+	STACEOExtension   = "https://stac-extensions.github.io/eo/v1.0.0/schema.json"
+	STACViewExtension = "https://stac-extensions.github.io/view/v1.0.0/schema.json"
+)
+
+// STACItem is a scene as a STAC Item
+type STACItem struct {
+	Type           string                 `json:"type"`
+	STACVersion    string                 `json:"stac_version"`
+	STACExtensions []string               `json:"stac_extensions"`
+	ID             string                 `json:"id"`
+	Geometry       interface{}            `json:"geometry"`
+	Bbox           geojson.BoundingBox    `json:"bbox,omitempty"`
+	Properties     map[string]interface{} `json:"properties"`
+	Links          []STACLink             `json:"links"`
+	Assets         map[string]STACAsset   `json:"assets"`
+	Collection     string                 `json:"collection,omitempty"`
+}
+
+// STACLink is a link of a STAC object. Links that must be followed with a
+// POST, such as the next page of a POSTed search, carry its method and body.
+type STACLink struct {
+	Href   string      `json:"href"`
+	Rel    string      `json:"rel"`
+	Type   string      `json:"type,omitempty"`
+	Title  string      `json:"title,omitempty"`
+	Method string      `json:"method,omitempty"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// STACAsset is a file of a STAC Item
+type STACAsset struct {
+	Href    string     `json:"href"`
+	Type    string     `json:"type,omitempty"`
+	Title   string     `json:"title,omitempty"`
+	Roles   []string   `json:"roles,omitempty"`
+	GSD     float64    `json:"gsd,omitempty"`
+	EOBands []STACBand `json:"eo:bands,omitempty"`
+	// PlanetStatus is the activation status of an asset hosted by Planet
+	PlanetStatus string `json:"planet:status,omitempty"`
+}
+
+// STACBand describes a spectral band, as in the eo extension; wavelengths
+// are in micrometers
+type STACBand struct {
+	Name             string  `json:"name"`
+	CommonName       string  `json:"common_name,omitempty"`
+	CenterWavelength float64 `json:"center_wavelength,omitempty"`
+	FullWidthHalfMax float64 `json:"full_width_half_max,omitempty"`
+}
+
+// STACItemCollection is a page of STAC Items, e.g. from a search
+type STACItemCollection struct {
+	Type           string      `json:"type"`
+	Features       []*STACItem `json:"features"`
+	Links          []STACLink  `json:"links"`
+	NumberMatched  *int        `json:"numberMatched,omitempty"`
+	NumberReturned int         `json:"numberReturned"`
+}
+
+// NewSTACItemCollection collects STAC Items into a page of results
+func NewSTACItemCollection(items []*STACItem) STACItemCollection {
+	return STACItemCollection{Type: "FeatureCollection", Features: items, Links: []STACLink{}, NumberReturned: len(items)}
+}
+
+// The media types of assets
+const (
+	STACGeoTIFF  = "image/tiff; application=geotiff"
+	STACJPEG2000 = "image/jp2"
+)
+
+// STACItem creates a STAC Item holding the fields common to all results
+func (br BasicBrokerResult) STACItem() (*STACItem, error) {
+	item := STACItem{
+		Type:           "Feature",
+		STACVersion:    STACVersion,
+		STACExtensions: []string{STACEOExtension},
+		ID:             br.ID,
+		Geometry:       br.Geometry,
+		Bbox:           br.BoundingBox,
+		Properties: map[string]interface{}{
+			"datetime":       br.AcquiredDate.UTC().Format(time.RFC3339Nano),
+			"eo:cloud_cover": br.CloudCover,
+		},
+		Links:  []STACLink{},
+		Assets: map[string]STACAsset{},
+	}
+	if item.Bbox == nil {
+		if geometry, ok := br.Geometry.(geojson.BoundingBoxIfc); ok {
+			item.Bbox = geometry.ForceBbox()
+		}
+	}
+	if br.Resolution > 0 {
+		item.Properties["gsd"] = br.Resolution
+	}
+	if br.AOICoverage != nil {
+		item.Properties["aoiCoverage"] = *br.AOICoverage
+	}
+	if br.ItemType != "" {
+		item.Properties["itemType"] = br.ItemType
+	}
+	if br.Downloadable != nil {
+		item.Properties["downloadable"] = *br.Downloadable
+	}
+	return &item, nil
+}
+
+// bandAsset is the STAC asset of a single band
+func bandAsset(band STACBand, gsd float64, href url.URL, mediaType string) STACAsset {
+	return STACAsset{
+		Href:    href.String(),
+		Type:    mediaType,
+		Title:   fmt.Sprintf("Band %v (%v)", strings.TrimLeft(band.Name[1:], "0"), band.CommonName),
+		Roles:   []string{"data"},
+		GSD:     gsd,
+		EOBands: []STACBand{band},
+	}
+}
+
+// landsatBand is a Landsat 8 band and the field of LandsatS3Bands holding it
+type landsatBand struct {
+	STACBand
+	GSD float64
+	URL func(LandsatS3Bands) url.URL
+}
+
+var landsatBands = []landsatBand{
+	{STACBand{"B1", "coastal", 0.44, 0.02}, 30, func(b LandsatS3Bands) url.URL { return b.Coastal }},
+	{STACBand{"B2", "blue", 0.48, 0.06}, 30, func(b LandsatS3Bands) url.URL { return b.Blue }},
+	{STACBand{"B3", "green", 0.56, 0.06}, 30, func(b LandsatS3Bands) url.URL { return b.Green }},
+	{STACBand{"B4", "red", 0.65, 0.04}, 30, func(b LandsatS3Bands) url.URL { return b.Red }},
+	{STACBand{"B5", "nir", 0.86, 0.03}, 30, func(b LandsatS3Bands) url.URL { return b.NIR }},
+	{STACBand{"B6", "swir16", 1.6, 0.08}, 30, func(b LandsatS3Bands) url.URL { return b.SWIR1 }},
+	{STACBand{"B7", "swir22", 2.2, 0.2}, 30, func(b LandsatS3Bands) url.URL { return b.SWIR2 }},
+	{STACBand{"B8", "pan", 0.59, 0.18}, 15, func(b LandsatS3Bands) url.URL { return b.Panchromatic }},
+	{STACBand{"B9", "cirrus", 1.37, 0.02}, 30, func(b LandsatS3Bands) url.URL { return b.Cirrus }},
+	{STACBand{"B10", "lwir11", 10.9, 0.8}, 100, func(b LandsatS3Bands) url.URL { return b.TIRS1 }},
+	{STACBand{"B11", "lwir12", 12.0, 1.0}, 100, func(b LandsatS3Bands) url.URL { return b.TIRS2 }},
+}
+
+// LandsatBands lists the bands of Landsat 8 scenes
+func LandsatBands() []STACBand {
+	bands := make([]STACBand, len(landsatBands))
+	for i, band := range landsatBands {
+		bands[i] = band.STACBand
+	}
+	return bands
+}
+
+// ApplySTAC adds the bands to a STAC Item as assets, with their eo:bands, and
+// describes the platform. Landsat 8 only looks straight down.
+func (lsb LandsatS3Bands) ApplySTAC(item *STACItem) error {
+	for _, band := range landsatBands {
+		item.Assets[band.Name] = bandAsset(band.STACBand, band.GSD, band.URL(lsb), STACGeoTIFF)
+	}
+	item.Properties["platform"] = "landsat-8"
+	item.Properties["constellation"] = "landsat"
+	item.Properties["instruments"] = []string{"oli", "tirs"}
+	item.Properties["gsd"] = 30.0
+	item.Properties["view:off_nadir"] = 0.0
+	item.STACExtensions = appendExtension(item.STACExtensions, STACViewExtension)
+	return nil
+}
+
+func appendExtension(extensions []string, extension string) []string {
+	for _, existing := range extensions {
+		if existing == extension {
+			return extensions
+		}
+	}
+	return append(extensions, extension)
+}
+
+// sentinelBand is a Sentinel-2 band and the field of SentinelS3Bands holding it
+type sentinelBand struct {
+	STACBand
+	GSD float64
+	URL func(SentinelS3Bands) url.URL
+}
+
+var sentinelBands = []sentinelBand{
+	{STACBand{"B01", "coastal", 0.443, 0.027}, 60, func(b SentinelS3Bands) url.URL { return b.Coastal }},
+	{STACBand{"B02", "blue", 0.49, 0.098}, 10, func(b SentinelS3Bands) url.URL { return b.Blue }},
+	{STACBand{"B03", "green", 0.56, 0.045}, 10, func(b SentinelS3Bands) url.URL { return b.Green }},
+	{STACBand{"B04", "red", 0.665, 0.038}, 10, func(b SentinelS3Bands) url.URL { return b.Red }},
+	{STACBand{"B05", "rededge", 0.704, 0.019}, 20, func(b SentinelS3Bands) url.URL { return b.RedEdge1 }},
+	{STACBand{"B06", "rededge", 0.74, 0.018}, 20, func(b SentinelS3Bands) url.URL { return b.RedEdge2 }},
+	{STACBand{"B07", "rededge", 0.783, 0.028}, 20, func(b SentinelS3Bands) url.URL { return b.RedEdge3 }},
+	{STACBand{"B08", "nir", 0.842, 0.145}, 10, func(b SentinelS3Bands) url.URL { return b.NIR }},
+	{STACBand{"B09", "nir09", 0.945, 0.026}, 60, func(b SentinelS3Bands) url.URL { return b.WaterVapor }},
+	{STACBand{"B10", "cirrus", 1.375, 0.075}, 60, func(b SentinelS3Bands) url.URL { return b.Cirrus }},
+	{STACBand{"B11", "swir16", 1.61, 0.143}, 20, func(b SentinelS3Bands) url.URL { return b.SWIR1 }},
+	{STACBand{"B12", "swir22", 2.19, 0.242}, 20, func(b SentinelS3Bands) url.URL { return b.SWIR2 }},
+}
+
+// ApplySTAC adds the bands to a STAC Item as assets, with their eo:bands, and
+// describes the platform
+func (ssb SentinelS3Bands) ApplySTAC(item *STACItem) error {
+	for _, band := range sentinelBands {
+		item.Assets[band.Name] = bandAsset(band.STACBand, band.GSD, band.URL(ssb), STACJPEG2000)
+	}
+	item.Properties["constellation"] = "sentinel-2"
+	item.Properties["instruments"] = []string{"msi"}
+	item.Properties["gsd"] = 10.0
+	return nil
+}
+
+// ApplySTAC adds the assets to a STAC Item. An asset that is not active
+// links to its activation instead of its location.
+func (pam PlanetAssetMetadata) ApplySTAC(item *STACItem) error {
+	assets := pam.Assets
+	if len(assets) == 0 {
+		assets = map[string]PlanetAssetSummary{pam.Type: {
+			Status:        pam.Status,
+			ActivationURL: pam.ActivationURL.String(),
+			Location:      pam.AssetURL.String(),
+		}}
+	}
+	for assetType, asset := range assets {
+		href := asset.Location
+		if href == "" {
+			href = asset.ActivationURL
+		}
+		stacAsset := STACAsset{Href: href, Title: assetType, Roles: []string{"data"}, PlanetStatus: asset.Status}
+		if !strings.HasPrefix(assetType, "udm") {
+			stacAsset.Type = STACGeoTIFF
+		}
+		item.Assets[assetType] = stacAsset
+	}
+	return nil
+}
+
+// ApplySTAC adds the tides to a STAC Item's properties. Tides are a custom
+// extension without a published schema, so none is listed for them.
+func (td TidesData) ApplySTAC(item *STACItem) error {
+	item.Properties["tides:current"] = td.Current
+	item.Properties["tides:min_24h"] = td.Min24h
+	item.Properties["tides:max_24h"] = td.Max24h
+	return nil
+}
+
+// stacItem creates a STAC Item from a result's basic fields, applying mixins
+// that are not nil in turn
+func stacItem(basic BasicBrokerResult, mixins ...STACMixin) (*STACItem, error) {
+	item, err := basic.STACItem()
+	if err != nil {
+		return nil, err
+	}
+	for _, mixin := range mixins {
+		if err = mixin.ApplySTAC(item); err != nil {
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+// STACItem implements the STACItemCreator interface
+func (result BrokerSearchResult) STACItem() (*STACItem, error) {
+	return stacItem(result.BasicBrokerResult, tidesMixins(result.TidesData)...)
+}
+
+// STACItem implements the STACItemCreator interface
+func (result PlanetActivateableBrokerResult) STACItem() (*STACItem, error) {
+	return stacItem(result.BasicBrokerResult, append([]STACMixin{result.PlanetAssetMetadata}, tidesMixins(result.TidesData)...)...)
+}
+
+// STACItem implements the STACItemCreator interface
+func (result PlanetLandsatBrokerResult) STACItem() (*STACItem, error) {
+	return stacItem(result.BasicBrokerResult, append([]STACMixin{result.LandsatS3Bands}, tidesMixins(result.TidesData)...)...)
+}
+
+// STACItem implements the STACItemCreator interface
+func (result PlanetSentinelBrokerResult) STACItem() (*STACItem, error) {
+	return stacItem(result.BasicBrokerResult, append([]STACMixin{result.SentinelS3Bands}, tidesMixins(result.TidesData)...)...)
+}
+
+// STACItem implements the STACItemCreator interface
+func (result IndexedLandsatBrokerResult) STACItem() (*STACItem, error) {
+	return stacItem(result.BasicBrokerResult, append([]STACMixin{result.LandsatS3Bands}, tidesMixins(result.TidesData)...)...)
+}
+
+// tidesMixins is the tides mixin, if there are tides
+func tidesMixins(tides *TidesData) []STACMixin {
+	if tides == nil {
+		return nil
+	}
+	return []STACMixin{*tides}
+}
+
+// STACItemCollection writes the results as STAC Items; each must be a
+// STACItemCreator
+func (result MultiBrokerResult) STACItemCollection() (*STACItemCollection, error) {
+	var err error
+	items := make([]*STACItem, len(result.FeatureCreators))
+	for i, creator := range result.FeatureCreators {
+		stacCreator, ok := creator.(STACItemCreator)
+		if !ok {
+			return nil, fmt.Errorf("Result %T cannot be written as a STAC Item", creator)
+		}
+		if items[i], err = stacCreator.STACItem(); err != nil {
+			return nil, err
+		}
+	}
+	collection := NewSTACItemCollection(items)
+	return &collection, nil
+}
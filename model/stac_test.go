@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicBrokerResult_STACItem(t *testing.T) {
+	// Tested code
+	item, err := mockBasicBrokerResult.STACItem()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, "Feature", item.Type)
+	assert.Equal(t, STACVersion, item.STACVersion)
+	assert.Equal(t, []string{STACEOExtension}, item.STACExtensions)
+	assert.Equal(t, mockBasicBrokerResult.ID, item.ID)
+	assert.Equal(t, "1970-01-01T00:02:03Z", item.Properties["datetime"])
+	assert.Equal(t, mockBasicBrokerResult.CloudCover, item.Properties["eo:cloud_cover"])
+	assert.Equal(t, mockBasicBrokerResult.Resolution, item.Properties["gsd"])
+	assert.Nil(t, item.Bbox.Valid())
+	assert.Len(t, item.Bbox, 4)
+	assert.Empty(t, item.Assets)
+}
+
+func TestIndexedLandsatBrokerResult_STACItem(t *testing.T) {
+	// Mock
+	result := IndexedLandsatBrokerResult{
+		BasicBrokerResult: mockBasicBrokerResult,
+		LandsatS3Bands:    mockLandsatS3Bands,
+	}
+
+	// Tested code
+	item, err := result.STACItem()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, []string{STACEOExtension, STACViewExtension}, item.STACExtensions)
+	assert.Equal(t, "landsat-8", item.Properties["platform"])
+	assert.Equal(t, 0.0, item.Properties["view:off_nadir"])
+	assert.Len(t, item.Assets, 11)
+	assert.Equal(t, mockLandsatS3Bands.Coastal.String(), item.Assets["B1"].Href)
+	assert.Equal(t, []string{"data"}, item.Assets["B1"].Roles)
+	assert.Equal(t, "coastal", item.Assets["B1"].EOBands[0].CommonName)
+	assert.Equal(t, mockLandsatS3Bands.Panchromatic.String(), item.Assets["B8"].Href)
+	assert.Equal(t, 15.0, item.Assets["B8"].GSD)
+	assert.Equal(t, mockLandsatS3Bands.TIRS2.String(), item.Assets["B11"].Href)
+
+	bytes, err := json.Marshal(item)
+	assert.Nil(t, err)
+	assert.Contains(t, string(bytes), `"eo:bands":[{"name":"B4","common_name":"red","center_wavelength":0.65,"full_width_half_max":0.04}]`)
+}
+
+func TestPlanetActivateableBrokerResult_STACItem(t *testing.T) {
+	// Mock
+	result := PlanetActivateableBrokerResult{
+		BasicBrokerResult:   mockBasicBrokerResult,
+		PlanetAssetMetadata: mockPlanetAssetMetadata,
+		TidesData:           &mockTidesData,
+	}
+
+	// Tested code
+	item, err := result.STACItem()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Len(t, item.Assets, 1)
+	assert.Equal(t, mockPlanetAssetMetadata.AssetURL.String(), item.Assets["test"].Href)
+	assert.Equal(t, "active", item.Assets["test"].PlanetStatus)
+	assert.Equal(t, mockTidesData.Current, item.Properties["tides:current"])
+	assert.Equal(t, mockTidesData.Min24h, item.Properties["tides:min_24h"])
+	assert.Equal(t, mockTidesData.Max24h, item.Properties["tides:max_24h"])
+}
+
+func TestPlanetSentinelBrokerResult_STACItem(t *testing.T) {
+	// Mock
+	bands, err := NewSentinelS3Bands("https://example.localhost", "S2A_MSIL1C_20160513T183921_N0204_R070_T11SKD_20160513T185132")
+	assert.Nil(t, err)
+	result := PlanetSentinelBrokerResult{
+		BasicBrokerResult: mockBasicBrokerResult,
+		SentinelS3Bands:   *bands,
+	}
+
+	// Tested code
+	item, err := result.STACItem()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, "sentinel-2", item.Properties["constellation"])
+	assert.NotContains(t, item.Properties, "tides:current")
+	assert.Len(t, item.Assets, 12)
+	assert.Equal(t, bands.Red.String(), item.Assets["B04"].Href)
+	assert.Equal(t, STACJPEG2000, item.Assets["B04"].Type)
+	assert.Equal(t, "Band 4 (red)", item.Assets["B04"].Title)
+	assert.Equal(t, 60.0, item.Assets["B10"].GSD)
+}
+
+func TestMultiBrokerResult_STACItemCollection(t *testing.T) {
+	// Mock
+	result := MultiBrokerResult{FeatureCreators: []GeoJSONFeatureCreator{
+		BrokerSearchResult{BasicBrokerResult: mockBasicBrokerResult},
+		IndexedLandsatBrokerResult{BasicBrokerResult: mockBasicBrokerResult, LandsatS3Bands: mockLandsatS3Bands},
+	}}
+
+	// Tested code
+	collection, err := result.STACItemCollection()
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.Equal(t, 2, collection.NumberReturned)
+	assert.Empty(t, collection.Features[0].Assets)
+	assert.Len(t, collection.Features[1].Assets, 11)
+}
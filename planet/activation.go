@@ -0,0 +1,546 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// Activation statuses; all but failed are reported by Planet
+const (
+	activationStatusInactive   = "inactive"
+	activationStatusActivating = "activating"
+	activationStatusActive     = "active"
+	activationStatusFailed     = "failed"
+)
+
+const defaultActivationInitialBackoff = 5 * time.Second
+const defaultActivationMaxBackoff = 2 * time.Minute
+const defaultActivationMaxAttempts = 40
+const defaultActivationPollTick = time.Second
+
+// Activation is the tracked state of an asset activation. Activations are
+// tracked for each Planet key separately, as a download location is only
+// for the key that activated it.
+type Activation struct {
+	KeyHash   string     `json:"-"`
+	ItemType  string     `json:"itemType"`
+	ID        string     `json:"id"`
+	AssetType string     `json:"assetType"`
+	Status    string     `json:"status"`
+	Location  string     `json:"location,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Attempts  int        `json:"attempts"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+func (a Activation) isSettled() bool {
+	return a.Status == activationStatusActive || a.Status == activationStatusFailed
+}
+
+func (a Activation) isExpired(now time.Time) bool {
+	return a.Status == activationStatusActive && a.ExpiresAt != nil && a.ExpiresAt.Before(now)
+}
+
+// ActivationStore persists the state of tracked activations
+type ActivationStore interface {
+	// GetActivation returns nil if the activation is not tracked for the key
+	GetActivation(keyHash string, itemType string, id string, assetType string) (*Activation, error)
+	PutActivation(activation Activation) error
+	PutCallbackDeadLetter(letter CallbackDeadLetter) error
+	// PutCallback registers a callback until its activation settles
+	PutCallback(callback ActivationCallback) error
+	// GetCallbacks returns every callback still waiting on an activation
+	GetCallbacks() ([]ActivationCallback, error)
+	DeleteCallbacks(keyHash string, itemType string, id string, assetType string) error
+}
+
+type sqlActivationStore struct {
+	db *sql.DB
+}
+
+// NewSQLActivationStore creates an ActivationStore backed by the
+// planet_activations table
+func NewSQLActivationStore(db *sql.DB) ActivationStore {
+	return sqlActivationStore{db: db}
+}
+
+func (s sqlActivationStore) GetActivation(keyHash string, itemType string, id string, assetType string) (*Activation, error) {
+	activation := Activation{KeyHash: keyHash, ItemType: itemType, ID: id, AssetType: assetType}
+	err := s.db.QueryRow(`
+		SELECT status, location, expires_at, attempts, last_error, updated_at
+		FROM planet_activations
+		WHERE key_hash = $1 AND item_type = $2 AND item_id = $3 AND asset_type = $4`, keyHash, itemType, id, assetType,
+	).Scan(&activation.Status, &activation.Location, &activation.ExpiresAt, &activation.Attempts, &activation.Error, &activation.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &activation, nil
+}
+
+func (s sqlActivationStore) PutActivation(activation Activation) error {
+	_, err := s.db.Exec(`
+		INSERT INTO planet_activations (key_hash, item_type, item_id, asset_type, status, location, expires_at, attempts, last_error, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (key_hash, item_type, item_id, asset_type) DO UPDATE SET
+			status = EXCLUDED.status,
+			location = EXCLUDED.location,
+			expires_at = EXCLUDED.expires_at,
+			attempts = EXCLUDED.attempts,
+			last_error = EXCLUDED.last_error,
+			updated_at = EXCLUDED.updated_at`,
+		activation.KeyHash, activation.ItemType, activation.ID, activation.AssetType, activation.Status, activation.Location, activation.ExpiresAt,
+		activation.Attempts, activation.Error, activation.UpdatedAt)
+	return err
+}
+
+func (s sqlActivationStore) PutCallback(callback ActivationCallback) error {
+	_, err := s.db.Exec(`
+		INSERT INTO planet_activation_callbacks (key_hash, item_type, item_id, asset_type, callback_url)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`,
+		callback.KeyHash, callback.ItemType, callback.ID, callback.AssetType, callback.CallbackURL)
+	return err
+}
+
+func (s sqlActivationStore) GetCallbacks() ([]ActivationCallback, error) {
+	rows, err := s.db.Query(`
+		SELECT key_hash, item_type, item_id, asset_type, callback_url
+		FROM planet_activation_callbacks
+		ORDER BY registered_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	callbacks := []ActivationCallback{}
+	for rows.Next() {
+		var callback ActivationCallback
+		if err = rows.Scan(&callback.KeyHash, &callback.ItemType, &callback.ID, &callback.AssetType, &callback.CallbackURL); err != nil {
+			return nil, err
+		}
+		callbacks = append(callbacks, callback)
+	}
+	return callbacks, rows.Err()
+}
+
+func (s sqlActivationStore) DeleteCallbacks(keyHash string, itemType string, id string, assetType string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM planet_activation_callbacks
+		WHERE key_hash = $1 AND item_type = $2 AND item_id = $3 AND asset_type = $4`, keyHash, itemType, id, assetType)
+	return err
+}
+
+func (s sqlActivationStore) PutCallbackDeadLetter(letter CallbackDeadLetter) error {
+	_, err := s.db.Exec(`
+		INSERT INTO planet_callback_dead_letters (item_type, item_id, asset_type, callback_url, payload, attempts, last_error, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		letter.ItemType, letter.ID, letter.AssetType, letter.CallbackURL, string(letter.Payload), letter.Attempts, letter.Error, letter.FailedAt)
+	return err
+}
+
+// pendingActivation is an activation being polled. Planet keys are only ever
+// held in memory, so an activation left pending by a restart resumes the next
+// time its status is requested.
+type pendingActivation struct {
+	options   MetadataOptions
+	planetKey string
+	attempts  int
+	nextPoll  time.Time
+}
+
+// ActivationTracker follows asset activations in the background, polling
+// Planet with exponential backoff until each is active or has failed, and
+// then notifying any callbacks registered for it
+type ActivationTracker struct {
+	Context        Context
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxAttempts    int
+	PollTick       time.Duration
+
+	// CallbackSecret signs callback payloads; callbacks are refused without it
+	CallbackSecret         string
+	CallbackInitialBackoff time.Duration
+	CallbackMaxBackoff     time.Duration
+	CallbackMaxAttempts    int
+	// CallbackAllowedNetworks are the internal networks callbacks may be sent to
+	CallbackAllowedNetworks []*net.IPNet
+
+	store     ActivationStore
+	mutex     sync.Mutex
+	pending   map[string]*pendingActivation
+	waiters   map[string][]chan struct{}
+	callbacks map[string][]string
+}
+
+// NewActivationTracker creates a tracker using configuration
+// from environment variables
+func NewActivationTracker(store ActivationStore) *ActivationTracker {
+	return &ActivationTracker{
+		Context:        Context{BasePlanetURL: util.GetPlanetAPIURL()},
+		InitialBackoff: defaultActivationInitialBackoff,
+		MaxBackoff:     defaultActivationMaxBackoff,
+		MaxAttempts:    defaultActivationMaxAttempts,
+		PollTick:       defaultActivationPollTick,
+
+		CallbackSecret:          util.GetCallbackSecret(),
+		CallbackInitialBackoff:  defaultCallbackInitialBackoff,
+		CallbackMaxBackoff:      defaultCallbackMaxBackoff,
+		CallbackMaxAttempts:     defaultCallbackMaxAttempts,
+		CallbackAllowedNetworks: util.GetCallbackAllowedNetworks(),
+
+		store:     store,
+		pending:   map[string]*pendingActivation{},
+		waiters:   map[string][]chan struct{}{},
+		callbacks: map[string][]string{},
+	}
+}
+
+// activationKeyHash is the hash of a Planet key that its activations are
+// tracked under; the key itself is never stored
+func activationKeyHash(planetKey string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(planetKey)))
+}
+
+func activationKey(keyHash string, itemType string, id string, assetType string) string {
+	return keyHash + "/" + itemType + "/" + id + "/" + assetType
+}
+
+// AcceptsCallbacks reports whether the tracker is able to sign callbacks
+func (t *ActivationTracker) AcceptsCallbacks() bool {
+	return t.CallbackSecret != ""
+}
+
+// Track starts following an activation that has just been requested; if a
+// callback URL is given, it is notified once the activation settles
+func (t *ActivationTracker) Track(options MetadataOptions, planetKey string, callbackURL string) error {
+	if callbackURL != "" && !t.AcceptsCallbacks() {
+		return errors.New("Callbacks are not available without a signing secret")
+	}
+
+	activation := Activation{
+		KeyHash:   activationKeyHash(planetKey),
+		ItemType:  options.ItemType,
+		ID:        options.ID,
+		AssetType: options.assetType(),
+		Status:    activationStatusActivating,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := t.save(activation); err != nil {
+		return err
+	}
+	if callbackURL != "" {
+		callback := ActivationCallback{
+			KeyHash:     activation.KeyHash,
+			ItemType:    activation.ItemType,
+			ID:          activation.ID,
+			AssetType:   activation.AssetType,
+			CallbackURL: callbackURL,
+		}
+		if err := t.store.PutCallback(callback); err != nil {
+			return err
+		}
+		t.addCallback(callback)
+	}
+	t.schedule(options, planetKey, 0, time.Now())
+	return nil
+}
+
+// Status returns the tracked state of an activation for a Planet key, asking
+// Planet for it if the key has not tracked it yet or its download has expired
+func (t *ActivationTracker) Status(options MetadataOptions, planetKey string) (*Activation, error) {
+	keyHash := activationKeyHash(planetKey)
+	activation, err := t.store.GetActivation(keyHash, options.ItemType, options.ID, options.assetType())
+	if err != nil {
+		return nil, err
+	}
+
+	if activation == nil || activation.isExpired(time.Now()) {
+		context := t.planetContext(planetKey)
+		options.NoCache = true
+		assetMetadata, err := GetPlanetAssets(options, &context)
+		if err != nil {
+			return nil, err
+		}
+		if assetMetadata == nil {
+			return nil, util.HTTPErr{Status: http.StatusNotFound, Message: fmt.Sprintf("Found no asset to activate for scene %v.", options.ID)}
+		}
+		fetched := activationFromAssetMetadata(keyHash, options, *assetMetadata)
+		if err = t.save(fetched); err != nil {
+			return nil, err
+		}
+		activation = &fetched
+	}
+
+	if activation.Status == activationStatusActivating {
+		t.mutex.Lock()
+		_, ok := t.pending[activationKey(keyHash, options.ItemType, options.ID, options.assetType())]
+		t.mutex.Unlock()
+		if !ok {
+			t.schedule(options, planetKey, activation.Attempts, time.Now())
+		}
+	}
+	return activation, nil
+}
+
+// Wait returns the state of an activation once it is active or has failed,
+// or its current state when the timeout runs out
+func (t *ActivationTracker) Wait(options MetadataOptions, planetKey string, timeout time.Duration) (*Activation, error) {
+	key := activationKey(activationKeyHash(planetKey), options.ItemType, options.ID, options.assetType())
+	deadline := time.Now().Add(timeout)
+	for {
+		// Subscribe before reading the state so no update is missed
+		changed := t.subscribe(key)
+		activation, err := t.Status(options, planetKey)
+		remaining := time.Until(deadline)
+		if err != nil || activation.isSettled() || remaining <= 0 {
+			t.unsubscribe(key, changed)
+			return activation, err
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+			t.unsubscribe(key, changed)
+		}
+	}
+}
+
+// Run polls pending activations until stop is closed, after reloading the
+// callbacks registered before a restart. Those are delivered once their
+// activations settle, which they are only seen to do when their status is
+// next requested, as Planet keys are not stored.
+func (t *ActivationTracker) Run(stop <-chan struct{}) {
+	if err := t.loadCallbacks(); err != nil {
+		logContext := t.planetContext("")
+		util.LogSimpleErr(&logContext, "Failed to reload activation callbacks.", err)
+	}
+
+	ticker := time.NewTicker(t.PollTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			t.pollDue(now)
+		}
+	}
+}
+
+func (t *ActivationTracker) pollDue(now time.Time) {
+	due := []*pendingActivation{}
+	t.mutex.Lock()
+	for key, p := range t.pending {
+		if !p.nextPoll.After(now) {
+			due = append(due, p)
+			delete(t.pending, key)
+		}
+	}
+	t.mutex.Unlock()
+
+	for _, p := range due {
+		t.poll(p)
+	}
+}
+
+func (t *ActivationTracker) poll(p *pendingActivation) {
+	context := t.planetContext(p.planetKey)
+	keyHash := activationKeyHash(p.planetKey)
+	p.attempts++
+	activation := Activation{
+		KeyHash:   keyHash,
+		ItemType:  p.options.ItemType,
+		ID:        p.options.ID,
+		AssetType: p.options.assetType(),
+		Status:    activationStatusActivating,
+		Attempts:  p.attempts,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	options := p.options
+	options.NoCache = true
+	assetMetadata, err := GetPlanetAssets(options, &context)
+	switch {
+	case err != nil:
+		activation.Error = err.Error()
+		if herr, ok := err.(util.HTTPErr); ok && herr.Status >= 400 && herr.Status < 500 {
+			// Planet will not change its mind about a bad key or a missing scene
+			activation.Status = activationStatusFailed
+		}
+	case assetMetadata == nil:
+		activation.Status = activationStatusFailed
+		activation.Error = fmt.Sprintf("Found no asset to activate for scene %v.", p.options.ID)
+	default:
+		activation = activationFromAssetMetadata(keyHash, p.options, *assetMetadata)
+		activation.Attempts = p.attempts
+	}
+
+	if !activation.isSettled() && p.attempts >= t.MaxAttempts {
+		activation.Status = activationStatusFailed
+		activation.Error = fmt.Sprintf("Asset was still not active after %d attempts. %v", p.attempts, activation.Error)
+	}
+
+	if err = t.save(activation); err != nil {
+		util.LogSimpleErr(&context, fmt.Sprintf("Failed to save activation of scene %v.", p.options.ID), err)
+	}
+	if !activation.isSettled() {
+		t.schedule(p.options, p.planetKey, p.attempts, time.Now().Add(t.backoff(p.attempts)))
+	}
+}
+
+// backoff is the delay before polling again after the given number of attempts
+func (t *ActivationTracker) backoff(attempts int) time.Duration {
+	return exponentialBackoff(t.InitialBackoff, t.MaxBackoff, attempts)
+}
+
+// exponentialBackoff doubles the initial delay with each attempt, up to max
+func exponentialBackoff(initial time.Duration, max time.Duration, attempts int) time.Duration {
+	delay := initial
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+func (t *ActivationTracker) schedule(options MetadataOptions, planetKey string, attempts int, nextPoll time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pending[activationKey(activationKeyHash(planetKey), options.ItemType, options.ID, options.assetType())] = &pendingActivation{
+		options:   options,
+		planetKey: planetKey,
+		attempts:  attempts,
+		nextPoll:  nextPoll,
+	}
+}
+
+// save stores an activation and wakes anyone waiting on it; once it has
+// settled, its callbacks are delivered in the background
+func (t *ActivationTracker) save(activation Activation) error {
+	if err := t.store.PutActivation(activation); err != nil {
+		return err
+	}
+	key := activationKey(activation.KeyHash, activation.ItemType, activation.ID, activation.AssetType)
+	t.mutex.Lock()
+	for _, waiter := range t.waiters[key] {
+		close(waiter)
+	}
+	delete(t.waiters, key)
+
+	var callbackURLs []string
+	if activation.isSettled() {
+		callbackURLs = t.callbacks[key]
+		delete(t.callbacks, key)
+	}
+	t.mutex.Unlock()
+
+	if len(callbackURLs) > 0 {
+		if err := t.store.DeleteCallbacks(activation.KeyHash, activation.ItemType, activation.ID, activation.AssetType); err != nil {
+			logContext := t.planetContext("")
+			util.LogSimpleErr(&logContext, fmt.Sprintf("Failed to remove delivered callbacks for scene %v.", activation.ID), err)
+		}
+		for _, callbackURL := range callbackURLs {
+			go t.deliverCallback(callbackURL, activation)
+		}
+	}
+	return nil
+}
+
+// loadCallbacks adds the stored callbacks to those registered since the
+// tracker was created
+func (t *ActivationTracker) loadCallbacks() error {
+	callbacks, err := t.store.GetCallbacks()
+	if err != nil {
+		return err
+	}
+	for _, callback := range callbacks {
+		t.addCallback(callback)
+	}
+	return nil
+}
+
+func (t *ActivationTracker) addCallback(callback ActivationCallback) {
+	key := activationKey(callback.KeyHash, callback.ItemType, callback.ID, callback.AssetType)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, callbackURL := range t.callbacks[key] {
+		if callbackURL == callback.CallbackURL {
+			return
+		}
+	}
+	t.callbacks[key] = append(t.callbacks[key], callback.CallbackURL)
+}
+
+func (t *ActivationTracker) subscribe(key string) chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	changed := make(chan struct{})
+	t.waiters[key] = append(t.waiters[key], changed)
+	return changed
+}
+
+func (t *ActivationTracker) unsubscribe(key string, changed chan struct{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	waiters := t.waiters[key]
+	for i, waiter := range waiters {
+		if waiter == changed {
+			t.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(t.waiters[key]) == 0 {
+		delete(t.waiters, key)
+	}
+}
+
+func (t *ActivationTracker) planetContext(planetKey string) Context {
+	return Context{BasePlanetURL: t.Context.BasePlanetURL, PlanetKey: planetKey}
+}
+
+func activationFromAssetMetadata(keyHash string, options MetadataOptions, assetMetadata model.PlanetAssetMetadata) Activation {
+	activation := Activation{
+		KeyHash:   keyHash,
+		ItemType:  options.ItemType,
+		ID:        options.ID,
+		AssetType: options.assetType(),
+		Status:    assetMetadata.Status,
+		Location:  assetMetadata.AssetURL.String(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	if !assetMetadata.ExpiresAt.IsZero() {
+		expiresAt := assetMetadata.ExpiresAt
+		activation.ExpiresAt = &expiresAt
+	}
+	return activation
+}
@@ -0,0 +1,141 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testingActivatingOptions = MetadataOptions{ItemType: testingValidItemType, ID: testingActivatingItemID, ImagerySource: rapidEye}
+
+func TestActivationTrackerStatusUntracked(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+
+	options := MetadataOptions{ItemType: testingValidItemType, ID: testingValidItemID, ImagerySource: rapidEye}
+	activation, err := tracker.Status(options, testingValidKey)
+	assert.Nil(t, err, "Expected status request to succeed; received: %v", err)
+	assert.Equal(t, activationStatusActive, activation.Status)
+	assert.Contains(t, activation.Location, planetServer.URL+"/data/v1/download")
+	assert.NotNil(t, activation.ExpiresAt)
+
+	stored, _ := tracker.store.GetActivation(activationKeyHash(testingValidKey), testingValidItemType, testingValidItemID, defaultAssetType)
+	assert.Equal(t, activation, stored)
+}
+
+func TestActivationTrackerStatusScopedToKey(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+
+	options := MetadataOptions{ItemType: testingValidItemType, ID: testingValidItemID, ImagerySource: rapidEye}
+	activation, err := tracker.Status(options, testingValidKey)
+	assert.Nil(t, err, "Expected status request to succeed; received: %v", err)
+	assert.NotEmpty(t, activation.Location)
+
+	// Another key must ask Planet itself rather than see the stored download
+	activation, err = tracker.Status(options, testingInvalidKey)
+	assert.NotNil(t, err, "Expected another key to be refused by Planet")
+	assert.Nil(t, activation)
+	stored, _ := tracker.store.GetActivation(activationKeyHash(testingInvalidKey), testingValidItemType, testingValidItemID, defaultAssetType)
+	assert.Nil(t, stored)
+}
+
+func TestActivationTrackerPollsUntilActive(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 2)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingValidKey, ""))
+	activation, _ := tracker.store.GetActivation(activationKeyHash(testingValidKey), testingValidItemType, testingActivatingItemID, defaultAssetType)
+	assert.Equal(t, activationStatusActivating, activation.Status)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		tracker.pollDue(time.Now().Add(time.Hour))
+		activation, _ = tracker.store.GetActivation(activationKeyHash(testingValidKey), testingValidItemType, testingActivatingItemID, defaultAssetType)
+		assert.Equal(t, attempt, activation.Attempts)
+	}
+	assert.Equal(t, activationStatusActive, activation.Status)
+	assert.NotEmpty(t, activation.Location)
+	assert.Empty(t, tracker.pending, "Expected an active asset to no longer be polled")
+}
+
+func TestActivationTrackerFailsAfterMaxAttempts(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+	tracker.MaxAttempts = 2
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 10)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingValidKey, ""))
+	tracker.pollDue(time.Now().Add(time.Hour))
+	tracker.pollDue(time.Now().Add(time.Hour))
+
+	activation, _ := tracker.store.GetActivation(activationKeyHash(testingValidKey), testingValidItemType, testingActivatingItemID, defaultAssetType)
+	assert.Equal(t, activationStatusFailed, activation.Status)
+	assert.Contains(t, activation.Error, "2 attempts")
+	assert.Empty(t, tracker.pending)
+}
+
+func TestActivationTrackerFailsOnBadKey(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingInvalidKey, ""))
+	tracker.pollDue(time.Now().Add(time.Hour))
+
+	activation, _ := tracker.store.GetActivation(activationKeyHash(testingInvalidKey), testingValidItemType, testingActivatingItemID, defaultAssetType)
+	assert.Equal(t, activationStatusFailed, activation.Status)
+	assert.Equal(t, 1, activation.Attempts)
+}
+
+func TestActivationTrackerWait(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 2)
+	stop := make(chan struct{})
+	defer close(stop)
+	go tracker.Run(stop)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingValidKey, ""))
+	activation, err := tracker.Wait(testingActivatingOptions, testingValidKey, 5*time.Second)
+	assert.Nil(t, err, "Expected wait to succeed; received: %v", err)
+	assert.Equal(t, activationStatusActive, activation.Status)
+	assert.Empty(t, tracker.waiters, "Expected no waiters to be left behind")
+}
+
+func TestActivationTrackerWaitTimeout(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	tracker := newTestingActivationTracker(planetServer.URL)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 1)
+
+	// Nothing polls, so the activation cannot settle
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingValidKey, ""))
+	activation, err := tracker.Wait(testingActivatingOptions, testingValidKey, 20*time.Millisecond)
+	assert.Nil(t, err, "Expected wait to succeed; received: %v", err)
+	assert.Equal(t, activationStatusActivating, activation.Status)
+	assert.Empty(t, tracker.waiters, "Expected no waiters to be left behind")
+}
+
+func TestActivationTrackerBackoff(t *testing.T) {
+	tracker := NewActivationTracker(nil)
+	assert.Equal(t, 5*time.Second, tracker.backoff(1))
+	assert.Equal(t, 10*time.Second, tracker.backoff(2))
+	assert.Equal(t, 20*time.Second, tracker.backoff(3))
+	assert.Equal(t, 2*time.Minute, tracker.backoff(10))
+	assert.Equal(t, 2*time.Minute, tracker.backoff(100))
+}
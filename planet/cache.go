@@ -0,0 +1,235 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// Kinds of lookup kept in the metadata cache
+const (
+	cachedItem   = "item"
+	cachedAssets = "assets"
+)
+
+// MetadataCache caches the responses of Planet item and asset lookups
+type MetadataCache interface {
+	// Get returns nil if the key is not cached or has expired
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte, expiresAt time.Time) error
+	Delete(key string) error
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryMetadataCache is a least recently used cache whose entries also expire
+type memoryMetadataCache struct {
+	size    int
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	now     func() time.Time
+}
+
+// NewMemoryMetadataCache creates a MetadataCache in memory holding up to size
+// lookups
+func NewMemoryMetadataCache(size int) MetadataCache {
+	return &memoryMetadataCache{size: size, entries: map[string]*list.Element{}, order: list.New(), now: time.Now}
+}
+
+func (c *memoryMetadataCache) Get(key string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, nil
+	}
+	c.order.MoveToFront(element)
+	return entry.value, nil
+}
+
+func (c *memoryMetadataCache) Put(key string, value []byte, expiresAt time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value = &memoryCacheEntry{key: key, value: value, expiresAt: expiresAt}
+		c.order.MoveToFront(element)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&memoryCacheEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+func (c *memoryMetadataCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+type sqlMetadataCache struct {
+	db *sql.DB
+}
+
+// NewSQLMetadataCache creates a MetadataCache backed by the
+// planet_metadata_cache table, so it is shared by every broker instance
+func NewSQLMetadataCache(db *sql.DB) MetadataCache {
+	return sqlMetadataCache{db: db}
+}
+
+func (s sqlMetadataCache) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(`
+		SELECT value FROM planet_metadata_cache
+		WHERE cache_key = $1 AND expires_at > now()`, key,
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s sqlMetadataCache) Put(key string, value []byte, expiresAt time.Time) error {
+	if _, err := s.db.Exec(`DELETE FROM planet_metadata_cache WHERE expires_at <= now()`); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO planet_metadata_cache (cache_key, value, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (cache_key) DO UPDATE SET
+			value = EXCLUDED.value,
+			expires_at = EXCLUDED.expires_at`,
+		key, value, expiresAt)
+	return err
+}
+
+func (s sqlMetadataCache) Delete(key string) error {
+	_, err := s.db.Exec(`DELETE FROM planet_metadata_cache WHERE cache_key = $1`, key)
+	return err
+}
+
+var (
+	metadataCacheMutex sync.RWMutex
+	metadataCache      = NewMemoryMetadataCache(util.GetMetadataCacheSize())
+	metadataCacheTTL   = time.Duration(util.GetMetadataCacheTTL()) * time.Second
+)
+
+// SetMetadataCache sets the cache for Planet item and asset lookups and how
+// long lookups are kept; a TTL of zero disables caching
+func SetMetadataCache(cache MetadataCache, ttl time.Duration) {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	metadataCache = cache
+	metadataCacheTTL = ttl
+}
+
+func currentMetadataCache() (MetadataCache, time.Duration) {
+	metadataCacheMutex.RLock()
+	defer metadataCacheMutex.RUnlock()
+	return metadataCache, metadataCacheTTL
+}
+
+// metadataCacheKey identifies a lookup. What a Planet key may see differs
+// from key to key, so lookups are cached for each key (and Planet URL)
+// separately; only a hash of the key is kept.
+func metadataCacheKey(context *Context, kind string, options MetadataOptions) string {
+	scope := sha256.Sum256([]byte(context.BasePlanetURL + "\x00" + context.PlanetKey))
+	key := fmt.Sprintf("%x/%v/%v/%v", scope, kind, options.ItemType, options.ID)
+	if kind == cachedAssets {
+		key += "/" + options.assetType()
+	}
+	return key
+}
+
+// getCachedLookup returns a cached lookup, or nil if there is none or the
+// options skip the cache. Failures of the cache are logged and treated as
+// misses, since Planet can still be asked.
+func getCachedLookup(context *Context, kind string, options MetadataOptions) []byte {
+	cache, ttl := currentMetadataCache()
+	if options.NoCache || ttl <= 0 {
+		return nil
+	}
+	value, err := cache.Get(metadataCacheKey(context, kind, options))
+	if err != nil {
+		util.LogSimpleErr(context, "Failed to read the Planet Labs metadata cache. ", err)
+		return nil
+	}
+	return value
+}
+
+// putCachedLookup caches a lookup until the TTL runs out or, if sooner, until
+// expiresAt
+func putCachedLookup(context *Context, kind string, options MetadataOptions, value []byte, expiresAt time.Time) {
+	cache, ttl := currentMetadataCache()
+	if ttl <= 0 {
+		return
+	}
+	expires := time.Now().Add(ttl)
+	if !expiresAt.IsZero() && expiresAt.Before(expires) {
+		expires = expiresAt
+	}
+	if !expires.After(time.Now()) {
+		return
+	}
+	if err := cache.Put(metadataCacheKey(context, kind, options), value, expires); err != nil {
+		util.LogSimpleErr(context, "Failed to write the Planet Labs metadata cache. ", err)
+	}
+}
+
+// deleteCachedLookup drops a lookup that is known to be stale
+func deleteCachedLookup(context *Context, kind string, options MetadataOptions) {
+	cache, _ := currentMetadataCache()
+	if err := cache.Delete(metadataCacheKey(context, kind, options)); err != nil {
+		util.LogSimpleErr(context, "Failed to write the Planet Labs metadata cache. ", err)
+	}
+}
+
+// requestsNoCache reports whether a request asks not to be answered from a cache
+func requestsNoCache(request *http.Request) bool {
+	for _, directive := range strings.Split(request.Header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" || directive == "max-age=0" {
+			return true
+		}
+	}
+	return strings.EqualFold(strings.TrimSpace(request.Header.Get("Pragma")), "no-cache")
+}
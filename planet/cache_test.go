@@ -0,0 +1,114 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMetadataCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryMetadataCache(2)
+	expiresAt := time.Now().Add(time.Hour)
+
+	assert.Nil(t, cache.Put("a", []byte("A"), expiresAt))
+	assert.Nil(t, cache.Put("b", []byte("B"), expiresAt))
+	value, _ := cache.Get("a")
+	assert.Equal(t, []byte("A"), value)
+	assert.Nil(t, cache.Put("c", []byte("C"), expiresAt))
+
+	value, _ = cache.Get("b")
+	assert.Nil(t, value, "b was used least recently, so should have been evicted")
+	value, _ = cache.Get("a")
+	assert.Equal(t, []byte("A"), value)
+	value, _ = cache.Get("c")
+	assert.Equal(t, []byte("C"), value)
+
+	assert.Nil(t, cache.Delete("c"))
+	value, _ = cache.Get("c")
+	assert.Nil(t, value)
+}
+
+func TestMemoryMetadataCacheExpires(t *testing.T) {
+	cache := NewMemoryMetadataCache(10).(*memoryMetadataCache)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Put("a", []byte("A"), now.Add(time.Minute))
+	value, _ := cache.Get("a")
+	assert.Equal(t, []byte("A"), value)
+
+	now = now.Add(time.Minute)
+	value, _ = cache.Get("a")
+	assert.Nil(t, value)
+	assert.Empty(t, cache.entries)
+}
+
+func TestPutCachedLookupRespectsExpiresAt(t *testing.T) {
+	cache := NewMemoryMetadataCache(10).(*memoryMetadataCache)
+	SetMetadataCache(cache, time.Hour)
+	defer SetMetadataCache(NewMemoryMetadataCache(10), time.Hour)
+	context := Context{BasePlanetURL: "https://planet.example", PlanetKey: testingValidKey}
+	options := MetadataOptions{ItemType: testingValidItemType, ID: testingValidItemID}
+
+	expiresAt := time.Now().Add(time.Minute)
+	putCachedLookup(&context, cachedAssets, options, []byte("{}"), expiresAt)
+	entry := cache.entries[metadataCacheKey(&context, cachedAssets, options)].Value.(*memoryCacheEntry)
+	assert.Equal(t, expiresAt, entry.expiresAt)
+
+	putCachedLookup(&context, cachedItem, options, []byte("{}"), time.Now().Add(-time.Minute))
+	_, ok := cache.entries[metadataCacheKey(&context, cachedItem, options)]
+	assert.False(t, ok, "Lookups that have already expired should not be cached")
+
+	otherContext := Context{BasePlanetURL: "https://planet.example", PlanetKey: "OTHER_KEY"}
+	assert.NotEqual(t, metadataCacheKey(&context, cachedAssets, options), metadataCacheKey(&otherContext, cachedAssets, options))
+	assert.NotContains(t, metadataCacheKey(&context, cachedAssets, options), testingValidKey)
+}
+
+func TestRequestsNoCache(t *testing.T) {
+	request := httptest.NewRequest("GET", "/", nil)
+	assert.False(t, requestsNoCache(request))
+	request.Header.Set("Cache-Control", "max-age=60, No-Cache")
+	assert.True(t, requestsNoCache(request))
+	request.Header.Del("Cache-Control")
+	request.Header.Set("Pragma", "no-cache")
+	assert.True(t, requestsNoCache(request))
+}
+
+func TestMetadataHandlerCachesLookups(t *testing.T) {
+	SetMetadataCache(NewMemoryMetadataCache(10), time.Hour)
+	mockServer, _, router := createTestFixtures()
+	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID)
+	before := atomic.LoadInt32(&testingItemRequests)
+
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	assert.Equal(t, before+1, atomic.LoadInt32(&testingItemRequests), "Expected the item to be requested from Planet once")
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", url, nil)
+	request.Header.Set("Cache-Control", "no-cache")
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, before+2, atomic.LoadInt32(&testingItemRequests), "Expected no-cache to skip the cache")
+}
@@ -0,0 +1,210 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// callbackSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of a
+// callback's body, keyed with the broker's callback secret
+const callbackSignatureHeader = "X-Bf-Signature"
+
+const defaultCallbackInitialBackoff = 2 * time.Second
+const defaultCallbackMaxBackoff = 5 * time.Minute
+const defaultCallbackMaxAttempts = 8
+
+// callbackTimeout bounds each attempt to deliver a callback
+const callbackTimeout = 30 * time.Second
+
+// CallbackPayload is POSTed to a callback URL when an activation settles
+type CallbackPayload struct {
+	Event      string     `json:"event"`
+	Activation Activation `json:"activation"`
+	SentAt     time.Time  `json:"sentAt"`
+}
+
+// ActivationCallback is a callback waiting for its activation to settle
+type ActivationCallback struct {
+	KeyHash     string
+	ItemType    string
+	ID          string
+	AssetType   string
+	CallbackURL string
+}
+
+// CallbackDeadLetter records a callback that could not be delivered
+type CallbackDeadLetter struct {
+	ItemType    string
+	ID          string
+	AssetType   string
+	CallbackURL string
+	Payload     []byte
+	Attempts    int
+	Error       string
+	FailedAt    time.Time
+}
+
+// validateCallbackURL checks that a callback is an absolute http or https URL
+// whose host resolves only to addresses callbacks may be sent to
+func (t *ActivationTracker) validateCallbackURL(callbackURL string) error {
+	parsedURL, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Hostname() == "" {
+		return errors.New("the callback must be an absolute http or https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsedURL.Hostname())
+	if err != nil {
+		return fmt.Errorf("the callback host could not be resolved: %v", err)
+	}
+	for _, address := range addresses {
+		if err = t.checkCallbackAddress(address.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCallbackAddress refuses loopback, private, link-local and unspecified
+// addresses, unless they are in a network the operator has allowed
+func (t *ActivationTracker) checkCallbackAddress(ip net.IP) error {
+	for _, network := range t.CallbackAllowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("the callback may not be sent to the internal address %v", ip)
+	}
+	return nil
+}
+
+// callbackClient is a client that checks every address it connects to, so
+// that a callback host cannot resolve to an internal address once it has
+// been validated, nor redirect to one
+func (t *ActivationTracker) callbackClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: callbackTimeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("the callback address %v is not an IP address", host)
+			}
+			return t.checkCallbackAddress(ip)
+		},
+	}
+	return &http.Client{Transport: &http.Transport{
+		DialContext:       dialer.DialContext,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+}
+
+func signCallbackPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverCallback POSTs the settled activation to the callback URL, retrying
+// with exponential backoff and recording a dead letter if every attempt fails
+func (t *ActivationTracker) deliverCallback(callbackURL string, activation Activation) {
+	logContext := t.planetContext("")
+	payload := CallbackPayload{
+		Event:      "activation." + activation.Status,
+		Activation: activation,
+		SentAt:     time.Now().UTC(),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		util.LogSimpleErr(&logContext, fmt.Sprintf("Failed to marshal callback payload %#v.", payload), err)
+		return
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		if err = t.sendCallback(callbackURL, body); err == nil {
+			util.LogInfo(&logContext, fmt.Sprintf("Delivered %v callback for scene %v to %v", payload.Event, activation.ID, callbackURL))
+			return
+		}
+		if attempts >= t.CallbackMaxAttempts {
+			break
+		}
+		time.Sleep(exponentialBackoff(t.CallbackInitialBackoff, t.CallbackMaxBackoff, attempts))
+	}
+
+	util.LogSimpleErr(&logContext, fmt.Sprintf("Giving up on %v callback for scene %v to %v after %d attempts.", payload.Event, activation.ID, callbackURL, attempts), err)
+	letter := CallbackDeadLetter{
+		ItemType:    activation.ItemType,
+		ID:          activation.ID,
+		AssetType:   activation.AssetType,
+		CallbackURL: callbackURL,
+		Payload:     body,
+		Attempts:    attempts,
+		Error:       err.Error(),
+		FailedAt:    time.Now().UTC(),
+	}
+	if err = t.store.PutCallbackDeadLetter(letter); err != nil {
+		util.LogSimpleErr(&logContext, fmt.Sprintf("Failed to record undelivered callback to %v.", callbackURL), err)
+	}
+}
+
+func (t *ActivationTracker) sendCallback(callbackURL string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	request, err := http.NewRequest("POST", callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(callbackSignatureHeader, signCallbackPayload(t.CallbackSecret, body))
+
+	response, err := t.callbackClient().Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("callback returned %v", response.Status)
+	}
+	return nil
+}
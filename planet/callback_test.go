@@ -0,0 +1,200 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receiveCallback(t *testing.T, requests chan *http.Request, bodies chan []byte) (*http.Request, CallbackPayload) {
+	var payload CallbackPayload
+	select {
+	case request := <-requests:
+		body := <-bodies
+		assert.Nil(t, json.Unmarshal(body, &payload))
+
+		mac := hmac.New(sha256.New, []byte(testingCallbackSecret))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), request.Header.Get(callbackSignatureHeader))
+		return request, payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a callback")
+		return nil, payload
+	}
+}
+
+func TestActivationCallbackActive(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	callbackServer, requests, bodies := createMockCallbackServer(http.StatusOK)
+	tracker := newTestingActivationTracker(planetServer.URL)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 0)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingValidKey, callbackServer.URL))
+	tracker.pollDue(time.Now().Add(time.Hour))
+
+	request, payload := receiveCallback(t, requests, bodies)
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, "activation.active", payload.Event)
+	assert.Equal(t, testingActivatingItemID, payload.Activation.ID)
+	assert.NotEmpty(t, payload.Activation.Location)
+	assert.Empty(t, tracker.callbacks, "Expected the callback to be delivered only once")
+}
+
+func TestActivationCallbackStoredUntilDelivered(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	callbackServer, requests, bodies := createMockCallbackServer(http.StatusOK)
+	tracker := newTestingActivationTracker(planetServer.URL)
+	store := tracker.store.(*memoryActivationStore)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 0)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingValidKey, callbackServer.URL))
+	callbacks, _ := store.GetCallbacks()
+	assert.Equal(t, []ActivationCallback{{
+		KeyHash:     activationKeyHash(testingValidKey),
+		ItemType:    testingValidItemType,
+		ID:          testingActivatingItemID,
+		AssetType:   defaultAssetType,
+		CallbackURL: callbackServer.URL,
+	}}, callbacks)
+
+	tracker.pollDue(time.Now().Add(time.Hour))
+	receiveCallback(t, requests, bodies)
+	callbacks, _ = store.GetCallbacks()
+	assert.Empty(t, callbacks, "Expected a delivered callback to no longer be stored")
+}
+
+func TestActivationCallbackReloaded(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	callbackServer, requests, bodies := createMockCallbackServer(http.StatusOK)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 0)
+
+	// The callback is registered before a restart...
+	store := newMemoryActivationStore()
+	before := newTestingActivationTracker(planetServer.URL)
+	before.store = store
+	assert.Nil(t, before.Track(testingActivatingOptions, testingValidKey, callbackServer.URL))
+
+	// ...and delivered by the tracker after it once the activation settles
+	tracker := newTestingActivationTracker(planetServer.URL)
+	tracker.store = store
+	stop := make(chan struct{})
+	defer close(stop)
+	go tracker.Run(stop)
+
+	activation, err := tracker.Wait(testingActivatingOptions, testingValidKey, 5*time.Second)
+	assert.Nil(t, err, "Expected wait to succeed; received: %v", err)
+	assert.Equal(t, activationStatusActive, activation.Status)
+	_, payload := receiveCallback(t, requests, bodies)
+	assert.Equal(t, "activation.active", payload.Event)
+	assert.Len(t, requests, 0, "Expected the callback to be delivered only once")
+}
+
+func TestActivationCallbackFailed(t *testing.T) {
+	planetServer, _, _ := createTestFixtures()
+	callbackServer, requests, bodies := createMockCallbackServer(http.StatusOK)
+	tracker := newTestingActivationTracker(planetServer.URL)
+
+	assert.Nil(t, tracker.Track(testingActivatingOptions, testingInvalidKey, callbackServer.URL))
+	tracker.pollDue(time.Now().Add(time.Hour))
+
+	_, payload := receiveCallback(t, requests, bodies)
+	assert.Equal(t, "activation.failed", payload.Event)
+	assert.NotEmpty(t, payload.Activation.Error)
+}
+
+func TestActivationCallbackDeadLetter(t *testing.T) {
+	callbackServer, requests, _ := createMockCallbackServer(http.StatusInternalServerError)
+	tracker := newTestingActivationTracker("")
+	activation := Activation{ItemType: testingValidItemType, ID: testingValidItemID, AssetType: "udm2", Status: activationStatusActive}
+
+	tracker.deliverCallback(callbackServer.URL, activation)
+
+	assert.Len(t, requests, 3, "Expected every attempt to reach the callback")
+	store := tracker.store.(*memoryActivationStore)
+	assert.Len(t, store.deadLetters, 1)
+	letter := store.deadLetters[0]
+	assert.Equal(t, callbackServer.URL, letter.CallbackURL)
+	assert.Equal(t, testingValidItemID, letter.ID)
+	assert.Equal(t, "udm2", letter.AssetType)
+	assert.Equal(t, 3, letter.Attempts)
+	assert.Contains(t, letter.Error, "500")
+	assert.Contains(t, string(letter.Payload), `"event":"activation.active"`)
+}
+
+func TestActivationCallbackRequiresSecret(t *testing.T) {
+	tracker := newTestingActivationTracker("")
+	tracker.CallbackSecret = ""
+
+	assert.False(t, tracker.AcceptsCallbacks())
+	assert.NotNil(t, tracker.Track(testingActivatingOptions, testingValidKey, "http://example.com/callback"))
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	tracker := newTestingActivationTracker("")
+	assert.Nil(t, tracker.validateCallbackURL("https://93.184.216.34/callback?job=1"))
+	assert.Nil(t, tracker.validateCallbackURL("http://localhost:8080/callback"))
+	assert.NotNil(t, tracker.validateCallbackURL("/callback"))
+	assert.NotNil(t, tracker.validateCallbackURL("ftp://example.com/callback"))
+	assert.NotNil(t, tracker.validateCallbackURL("http:///callback"))
+	assert.NotNil(t, tracker.validateCallbackURL("%zz"))
+}
+
+func TestValidateCallbackURLInternal(t *testing.T) {
+	tracker := newTestingActivationTracker("")
+	tracker.CallbackAllowedNetworks = nil
+
+	for _, callbackURL := range []string{
+		"http://localhost:8080/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]/callback",
+		"http://0.0.0.0/callback",
+		"http://10.1.2.3/callback",
+		"http://172.16.0.1/callback",
+		"http://192.168.1.1/callback",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/callback",
+		"http://[fd00::1]/callback",
+		"http://[::ffff:127.0.0.1]/callback",
+	} {
+		assert.NotNil(t, tracker.validateCallbackURL(callbackURL), "Expected %v to be refused", callbackURL)
+	}
+
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	tracker.CallbackAllowedNetworks = []*net.IPNet{network}
+	assert.Nil(t, tracker.validateCallbackURL("http://10.1.2.3/callback"))
+	assert.NotNil(t, tracker.validateCallbackURL("http://192.168.1.1/callback"))
+}
+
+func TestSendCallbackRefusesInternalAddress(t *testing.T) {
+	callbackServer, requests, _ := createMockCallbackServer(http.StatusOK)
+	tracker := newTestingActivationTracker("")
+	tracker.CallbackAllowedNetworks = nil
+
+	// A host validated earlier may since resolve to an internal address
+	err := tracker.sendCallback(callbackServer.URL, []byte("{}"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "internal address")
+	assert.Len(t, requests, 0)
+}
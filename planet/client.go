@@ -0,0 +1,230 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+const defaultPlanetMaxAttempts = 4
+const defaultPlanetInitialBackoff = 500 * time.Millisecond
+const defaultPlanetMaxBackoff = 8 * time.Second
+
+// defaultPlanetMaxWait is the budget for retrying a request to Planet,
+// including any time spent waiting for a free slot
+const defaultPlanetMaxWait = 30 * time.Second
+
+const planetRateLimited = "Planet Labs is rate limiting requests for this API key. Retry after %d seconds."
+const planetBusy = "Too many requests to Planet Labs are in progress for this API key. Retry after %d seconds."
+
+// planetClient sends requests to Planet, retrying those that are rate limited
+// or fail transiently and capping the requests in flight for each API key
+type planetClient struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxWait        time.Duration
+	maxConcurrent  int
+
+	mutex sync.Mutex
+	slots map[[sha256.Size]byte]*keySlots
+}
+
+// keySlots is the semaphore for an API key, and how many requests are holding
+// or waiting for one of its slots; it is dropped once none are
+type keySlots struct {
+	requests chan struct{}
+	users    int
+}
+
+var defaultPlanetClient = newPlanetClient(util.GetPlanetMaxConcurrentRequests())
+
+func newPlanetClient(maxConcurrent int) *planetClient {
+	return &planetClient{
+		maxAttempts:    defaultPlanetMaxAttempts,
+		initialBackoff: defaultPlanetInitialBackoff,
+		maxBackoff:     defaultPlanetMaxBackoff,
+		maxWait:        defaultPlanetMaxWait,
+		maxConcurrent:  maxConcurrent,
+		slots:          map[[sha256.Size]byte]*keySlots{},
+	}
+}
+
+// do sends the request built by newRequest until Planet accepts it. A 429 is
+// always safe to retry, since Planet has not acted on the request; other
+// failures are only retried for idempotent requests. Once the budget is spent
+// on a rate limited request, a 503 saying when to retry is returned.
+func (c *planetClient) do(newRequest func() (*http.Request, error), apiKey string, idempotent bool) (*http.Response, error) {
+	deadline := time.Now().Add(c.maxWait)
+	for attempt := 1; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		var delay time.Duration
+		response, err := c.send(request, apiKey, deadline)
+		switch {
+		case err != nil:
+			if _, ok := err.(util.HTTPErr); ok || !idempotent || attempt >= c.maxAttempts {
+				return nil, err
+			}
+			delay = c.backoff(attempt)
+		case response.StatusCode == http.StatusTooManyRequests:
+			delay = c.backoff(attempt)
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After"), time.Now()); ok {
+				delay = retryAfter
+			}
+			discardResponse(response)
+			if attempt >= c.maxAttempts || time.Now().Add(delay).After(deadline) {
+				return nil, retryLaterErr(planetRateLimited, delay)
+			}
+		case response.StatusCode >= 500 && idempotent && attempt < c.maxAttempts:
+			delay = c.backoff(attempt)
+			if time.Now().Add(delay).After(deadline) {
+				return response, nil
+			}
+			discardResponse(response)
+		default:
+			return response, nil
+		}
+		time.Sleep(delay)
+	}
+}
+
+// send waits for a free slot for the API key, then sends the request. The
+// slot is held until the response body is closed, as the response is still
+// being read from Planet until then.
+func (c *planetClient) send(request *http.Request, apiKey string, deadline time.Time) (*http.Response, error) {
+	release, err := c.acquire(apiKey, deadline)
+	if err != nil {
+		return nil, err
+	}
+	response, err := util.HTTPClient().Do(request)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	response.Body = &slotBody{ReadCloser: response.Body, release: release}
+	return response, nil
+}
+
+// acquire waits until the deadline for a free slot for the API key, and
+// returns the function that frees it again. Keys are hashed, and are not kept
+// any longer than the requests using them.
+func (c *planetClient) acquire(apiKey string, deadline time.Time) (func(), error) {
+	key := sha256.Sum256([]byte(apiKey))
+	c.mutex.Lock()
+	slots, ok := c.slots[key]
+	if !ok {
+		slots = &keySlots{requests: make(chan struct{}, c.maxConcurrent)}
+		c.slots[key] = slots
+	}
+	slots.users++
+	c.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case slots.requests <- struct{}{}:
+	case <-timer.C:
+		c.leave(key, slots)
+		return nil, retryLaterErr(planetBusy, c.initialBackoff)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-slots.requests
+			c.leave(key, slots)
+		})
+	}, nil
+}
+
+// leave drops the semaphore for a key once no request is using it
+func (c *planetClient) leave(key [sha256.Size]byte, slots *keySlots) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	slots.users--
+	if slots.users == 0 {
+		delete(c.slots, key)
+	}
+}
+
+// slotBody frees the slot of a response when it is closed
+type slotBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// backoff is an exponential backoff with equal jitter, so clients rate
+// limited together do not all retry together
+func (c *planetClient) backoff(attempt int) time.Duration {
+	delay := exponentialBackoff(c.initialBackoff, c.maxBackoff, attempt)
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if date.Before(now) {
+			return 0, true
+		}
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+func retryLaterErr(format string, delay time.Duration) util.HTTPErr {
+	seconds := int(math.Ceil(delay.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return util.HTTPErr{Status: http.StatusServiceUnavailable, Message: fmt.Sprintf(format, seconds), RetryAfter: seconds}
+}
+
+// discardResponse reads and closes a response that is being retried, so its
+// connection can be reused
+func discardResponse(response *http.Response) {
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+}
@@ -0,0 +1,149 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+func TestPlanetClientRetriesRateLimited(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+	atomic.StoreInt32(&testingRateLimitsRemaining, int32(defaultPlanetClient.maxAttempts-1))
+
+	options := MetadataOptions{ID: testingRateLimitedItemID, ItemType: testingValidItemType}
+	result, err := GetPlanetItem(options, &context)
+	assert.Nil(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, int32(-1), atomic.LoadInt32(&testingRateLimitsRemaining))
+}
+
+func TestPlanetClientRateLimitExhausted(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+	atomic.StoreInt32(&testingRateLimitsRemaining, 100)
+	defer atomic.StoreInt32(&testingRateLimitsRemaining, 0)
+
+	options := MetadataOptions{ID: testingRateLimitedItemID, ItemType: testingValidItemType}
+	_, err := GetPlanetItem(options, &context)
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr but got %v", err)
+	assert.Equal(t, http.StatusServiceUnavailable, herr.Status)
+	assert.Equal(t, 1, herr.RetryAfter)
+	assert.Equal(t, int32(100-defaultPlanetClient.maxAttempts), atomic.LoadInt32(&testingRateLimitsRemaining))
+}
+
+func TestPlanetClientRetriesOnlyIdempotentFailures(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writer.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	client := newPlanetClient(1)
+	client.initialBackoff = time.Millisecond
+	client.maxBackoff = time.Millisecond
+	newRequest := func() (*http.Request, error) { return http.NewRequest("POST", server.URL, nil) }
+
+	response, err := client.do(newRequest, testingValidKey, false)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	response.Body.Close()
+
+	response, err = client.do(newRequest, testingValidKey, true)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+	assert.Equal(t, int32(1+client.maxAttempts), atomic.LoadInt32(&calls))
+	response.Body.Close()
+}
+
+func TestPlanetClientConcurrencyCap(t *testing.T) {
+	client := newPlanetClient(1)
+	client.initialBackoff = time.Millisecond
+	release, err := client.acquire(testingValidKey, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+
+	request, _ := http.NewRequest("GET", "http://localhost/unused", nil)
+	_, err = client.send(request, testingValidKey, time.Now().Add(10*time.Millisecond))
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr but got %v", err)
+	assert.Equal(t, http.StatusServiceUnavailable, herr.Status)
+	assert.True(t, herr.RetryAfter > 0)
+
+	// Other keys have their own slots
+	releaseOther, err := client.acquire(testingInvalidKey, time.Now().Add(10*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Len(t, client.slots, 2)
+
+	// Idle keys are not kept
+	releaseOther()
+	release()
+	release()
+	assert.Empty(t, client.slots)
+}
+
+func TestPlanetClientHoldsSlotUntilBodyClosed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte("scenes"))
+	}))
+	defer server.Close()
+	client := newPlanetClient(1)
+	client.initialBackoff = time.Millisecond
+
+	request, _ := http.NewRequest("GET", server.URL, nil)
+	response, err := client.send(request, testingValidKey, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+
+	// The body is still being read, so the slot is still taken
+	request, _ = http.NewRequest("GET", server.URL, nil)
+	_, err = client.send(request, testingValidKey, time.Now().Add(10*time.Millisecond))
+	assert.NotNil(t, err, "Expected no slot to be free before the body is closed")
+
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "scenes", string(body))
+	assert.Nil(t, response.Body.Close())
+	assert.Empty(t, client.slots)
+
+	request, _ = http.NewRequest("GET", server.URL, nil)
+	response, err = client.send(request, testingValidKey, time.Now().Add(10*time.Millisecond))
+	assert.Nil(t, err, "Expected the slot to be free once the body is closed")
+	response.Body.Close()
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("7", now)
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, delay)
+
+	delay, ok = parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+
+	_, ok = parseRetryAfter("", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
@@ -15,34 +15,90 @@
 package planet
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/bf-ia-broker/util"
 	"github.com/venicegeo/geojson-go/geojson"
 )
 
 const noPlanetKey = "This operation requires a Planet Labs API key."
 const noPlanetImageID = "This operation requires a Planet Labs image ID."
+const noItemTypes = "This operation requires an item type, or a comma-separated list of them in itemTypes."
 const invalidCloudCover = "Cloud Cover value of %v is invalid."
+const invalidPageSize = "The page_size value of %v is invalid; it must be between 1 and %d."
+const invalidRangeParameter = "The %v value of %v is invalid; it must be a number between %v and %v."
+const invalidGroundControl = "The groundControl value of %v is invalid; it must be true or false."
+const invalidGeometry = "The request body is not a valid GeoJSON Polygon or MultiPolygon: %v"
+const invalidOrder = "The order request is invalid: %v"
+const invalidCallback = "The callback value of %v is invalid: %v"
+const noCallbacks = "Activation callbacks are not available on this broker."
+const callbackNotTracked = "Scene %v is activating, but its callback could not be registered: %v"
+const invalidWait = "The wait value of %v is invalid; it must be between 0 and %d seconds."
+const invalidAssetType = "The assetType value of %v is invalid; it must be one of %v."
+const invalidTileCoordinates = "The tile %v/%v/%v is invalid; z must be between 0 and %d, and x and y between 0 and 2^z - 1."
+const invalidWidth = "The width value of %v is invalid; it must be between 1 and %d."
+const unfederatedSort = "Planet Labs sorts only by acquiredDate, so it cannot be searched with the %v sort."
+const invalidInterval = "The interval value of %v is invalid; it must be one of %v."
+
+// maxActivationWait caps, in seconds, how long an activation status request may be held open
+const maxActivationWait = 120
+
+// maxGeometryBodySize caps the size of an AOI geometry POSTed to discovery
+const maxGeometryBodySize = 4 << 20
+
+// maxTileZoom is the deepest zoom level Planet serves tiles for
+const maxTileZoom = 22
+
+// maxThumbnailWidth is the widest thumbnail Planet serves
+const maxThumbnailWidth = 2048
 
 // DiscoverHandler is a handler for /planet/discover
 // @Title planetDiscoverHandler
-// @Description discovers scenes from Planet Labs
-// @Accept  plain
-// @Param   PL_API_KEY      query   string  true         "Planet Labs API Key"
-// @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Description discovers scenes from Planet Labs; POST a GeoJSON Polygon or MultiPolygon to search an arbitrary AOI
+// @Accept  plain,json
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   itemType        path    string  false        "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Param   itemTypes       query   string  false        "Without an itemType in the path: comma-separated Planet Labs Item Types to search together, e.g., rapideye,planetscope"
 // @Param   bbox            query   string  false        "The bounding box, as a GeoJSON Bounding box (x1,y1,x2,y2)"
 // @Param   cloudCover      query   string  false        "The maximum cloud cover, as a percentage (0-100)"
 // @Param   acquiredDate    query   string  false        "The minimum (earliest) acquired date, as RFC 3339"
 // @Param   maxAcquiredDate query   string  false        "The maximum acquired date, as RFC 3339"
 // @Param   tides           query   bool    false        "True: incorporate tide prediction in the output"
-// @Success 200 {object}  geojson.FeatureCollection
+// @Param   minSunElevation query   number  false        "The minimum sun elevation, in degrees"
+// @Param   maxSunElevation query   number  false        "The maximum sun elevation, in degrees"
+// @Param   minViewAngle    query   number  false        "The minimum off-nadir view angle, in degrees"
+// @Param   maxViewAngle    query   number  false        "The maximum off-nadir view angle, in degrees"
+// @Param   minGsd          query   number  false        "The minimum ground sample distance, in meters"
+// @Param   maxGsd          query   number  false        "The maximum ground sample distance, in meters"
+// @Param   minUsableData   query   number  false        "The minimum usable data, as a percentage (0-100)"
+// @Param   qualityCategory query   string  false        "Comma-separated quality categories to accept, e.g., standard"
+// @Param   instrument      query   string  false        "Comma-separated instruments to accept, e.g., PS2,PS2.SD"
+// @Param   groundControl   query   bool    false        "True: only scenes with ground control; false: only those without"
+// @Param   page_size       query   int     false        "The number of results to request from Planet per page (1-250)"
+// @Param   limit           query   int     false        "The maximum number of results to return; defaults to one page"
+// @Param   sort            query   string  false        "Sort by acquiredDate, cloudCover, aoiCoverage or tide (with tides=true); prefix with - for descending, e.g., -acquiredDate. Planet sorts only by acquiredDate; the other sorts order just the scenes of one response, up to limit, which has no next page"
+// @Param   cursor          query   string  false        "The opaque cursor from a previous response's next link; only acquiredDate sorts are paged"
+// @Param   permissions     query   string  false        "Scenes the key may not download: filter drops them, annotate marks each result downloadable true or false, off ignores permissions"
+// @Param   assetType       query   string  false        "The asset whose download permission is checked: analytic (default), analytic_sr, basic_analytic, udm, udm2 or visual"
+// @Param   geometry        body    string  false        "POST only: the AOI, as a GeoJSON Polygon or MultiPolygon (overrides bbox)"
+// @Param   Accept          header  string  false        "application/geo+json; profile=stac: return a STAC ItemCollection instead"
+// @Param   format          query   string  false        "atom: return an Atom feed with GeoRSS footprints, as does an Accept of application/atom+xml"
+// @Success 200 {object}  model.PagedFeatureCollection
 // @Failure 400 {object}  string
-// @Router /planet/discover/{itemType} [get]
+// @Router /planet/discover/{itemType} [get,post]
+// @Router /planet/discover [get,post]
 type DiscoverHandler struct {
 	Context Context
 }
@@ -64,13 +120,12 @@ func NewDiscoverHandler() DiscoverHandler {
 // ServeHTTP implements the http.Handler interface for the DiscoverHandler type
 func (h DiscoverHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	var (
-		fc         *geojson.FeatureCollection
+		result     *model.MultiBrokerResult
 		err        error
-		itemType   string
 		bytes      []byte
-		bbox       geojson.BoundingBox
-		ccStr      string
-		cloudCover float64
+		options    SearchOptions
+		nextCursor string
+		nextURL    string
 	)
 	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/discover request", Severity: util.INFO})
 
@@ -78,92 +133,370 @@ func (h DiscoverHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
-	h.Context.PlanetKey = request.FormValue("PL_API_KEY")
-	if h.Context.PlanetKey == "" {
-		util.LogSimpleErr(&h.Context, noPlanetKey, nil)
-		util.HTTPError(request, writer, &h.Context, noPlanetKey, http.StatusBadRequest)
+	if planetKeyMissing(writer, request, &h.Context) {
 		return
 	}
 
-	tides, _ := strconv.ParseBool(request.FormValue("tides"))
+	if options, err = parseSearchOptions(writer, request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options.Tides, _ = strconv.ParseBool(request.FormValue("tides"))
+	options.Cursor = request.FormValue("cursor")
 
-	ccStr = request.FormValue("cloudCover")
-	if ccStr != "" {
-		if cloudCover, err = strconv.ParseFloat(ccStr, 64); err != nil {
-			message := fmt.Sprintf(invalidCloudCover, ccStr)
+	if pageSizeStr := request.FormValue("page_size"); pageSizeStr != "" {
+		if options.PageSize, err = strconv.Atoi(pageSizeStr); err != nil || options.PageSize < 1 || options.PageSize > maxPageSize {
+			message := fmt.Sprintf(invalidPageSize, pageSizeStr, maxPageSize)
 			util.LogSimpleErr(&h.Context, message, err)
 			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
 			return
 		}
-		cloudCover = cloudCover / 100.0
-	}
-
-	itemType = mux.Vars(request)["itemType"]
-	switch itemType {
-	case "REOrthoTile", "rapideye":
-		itemType = "REOrthoTile"
-	case "PSOrthoTile", "planetscope":
-		itemType = "PSOrthoTile"
-	case "Landsat8L1G", "landsat":
-		itemType = "Landsat8L1G"
-	case "Sentinel2L1C", "sentinel_s3", "sentinel_planet":
-		itemType = "Sentinel2L1C"
-	case "PSScene4Band":
-		// No op
+	}
+
+	if options.Limit, err = model.ParseSearchLimit(request.FormValue("limit"), maxSearchLimit); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if options.Sort, err = model.ParseSearchSort(request.FormValue("sort"), options.Tides); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if options.Permissions, err = parsePermissions(request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if options.AssetType, err = parseAssetType(request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if result, nextCursor, err = SearchScenes(options, &h.Context); err != nil {
+		switch herr := err.(type) {
+		case util.HTTPErr:
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
+		default:
+			err = util.LogSimpleErr(&h.Context, "Failed to get Planet Labs scenes. ", err)
+			util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if nextCursor != "" {
+		nextURL = util.NextPageURL(request, nextCursor)
+	}
+	contentType := "application/json"
+	switch {
+	case util.AcceptsSTAC(request):
+		bytes, err = writeSTACItemCollection(result, nextURL, request)
+		contentType = util.STACContentType
+	case util.AcceptsAtom(request):
+		bytes, err = writeAtomFeed(result, nextURL, request)
+		contentType = model.AtomContentType
 	default:
-		message := fmt.Sprintf("The item type value of %v is invalid", itemType)
-		util.LogSimpleErr(&h.Context, message, nil)
-		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+		bytes, err = writePagedFeatureCollection(result, nextURL)
+	}
+	if err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output GeoJSON from:\n%#v", result), err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Write(bytes)
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/discover response", Severity: util.INFO})
 
-	bboxString := request.FormValue("bbox")
-	if bboxString != "" {
-		if bbox, err = geojson.NewBoundingBox(bboxString); err != nil {
-			message := fmt.Sprintf("The bbox value of %v is invalid", bboxString)
-			util.LogSimpleErr(&h.Context, message, err)
-			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
-			return
+}
+
+// FederatedSearch searches Planet for federated discovery. The request takes
+// the filters of /planet/discover, a limit and a sort by acquiredDate, which
+// defaults to -acquiredDate; itemTypes defaults to landsat. Planet applies the
+// sort, so the scenes found are the first in that order. The request's Form
+// is set, so it must not be shared. Failures the request or Planet is at
+// fault for are util.HTTPErr.
+func (h DiscoverHandler) FederatedSearch(writer http.ResponseWriter, request *http.Request) (*model.MultiBrokerResult, error) {
+	planetKey, err := planetKeyFromRequest(request)
+	if err != nil {
+		util.LogAlert(&h.Context, err.(util.HTTPErr).Message)
+		return nil, err
+	}
+	if planetKey == "" {
+		util.LogAlert(&h.Context, noPlanetKey)
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: noPlanetKey}
+	}
+	h.Context.PlanetKey = planetKey
+
+	if request.FormValue("itemTypes") == "" {
+		request.Form.Set("itemTypes", "landsat")
+	}
+	options, err := parseSearchOptions(writer, request)
+	if err == nil {
+		options.Limit, err = model.ParseSearchLimit(request.FormValue("limit"), maxSearchLimit)
+	}
+	if err == nil {
+		options.Sort, err = model.ParseSearchSort(request.FormValue("sort"), false)
+	}
+	if err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	if options.Sort == nil {
+		options.Sort = &model.SearchSort{Field: model.SortByAcquiredDate, Descending: true}
+	} else if !planetSorts(options.Sort) {
+		message := fmt.Sprintf(unfederatedSort, options.Sort)
+		util.LogSimpleErr(&h.Context, message, nil)
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: message}
+	}
+
+	result, _, err := SearchScenes(options, &h.Context)
+	if err != nil {
+		if _, ok := err.(util.HTTPErr); !ok {
+			err = util.LogSimpleErr(&h.Context, "Failed to get Planet Labs scenes. ", err)
 		}
+		return nil, err
 	}
+	return result, nil
+}
 
-	options := SearchOptions{
-		ItemType:        itemType,
-		CloudCover:      cloudCover,
-		AcquiredDate:    request.FormValue("acquiredDate"),
-		MaxAcquiredDate: request.FormValue("maxAcquiredDate"),
-		Tides:           tides,
-		Bbox:            bbox}
+// StatsHandler is a handler for /planet/stats
+// @Title planetStatsHandler
+// @Description counts the Planet Labs scenes matching a search by acquisition date; takes the filters of /planet/discover
+// @Accept  plain,json
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   itemType        path    string  false        "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Param   itemTypes       query   string  false        "Without an itemType in the path: comma-separated Planet Labs Item Types to count together, e.g., rapideye,planetscope"
+// @Param   interval        query   string  false        "The length of each bucket: hour, day (default) or month"
+// @Param   bbox            query   string  false        "The bounding box, as a GeoJSON Bounding box (x1,y1,x2,y2)"
+// @Param   cloudCover      query   string  false        "The maximum cloud cover, as a percentage (0-100)"
+// @Param   acquiredDate    query   string  false        "The minimum (earliest) acquired date, as RFC 3339"
+// @Param   maxAcquiredDate query   string  false        "The maximum acquired date, as RFC 3339"
+// @Param   geometry        body    string  false        "POST only: the AOI, as a GeoJSON Polygon or MultiPolygon (overrides bbox)"
+// @Success 200 {object}  planet.Stats
+// @Failure 400 {object}  string
+// @Router /planet/stats/{itemType} [get,post]
+// @Router /planet/stats [get,post]
+type StatsHandler struct {
+	Context Context
+}
+
+// NewStatsHandler creates a new handler using configuration
+// from environment variables
+func NewStatsHandler() StatsHandler {
+	return StatsHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+		},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the StatsHandler type
+func (h StatsHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err     error
+		bytes   []byte
+		options SearchOptions
+		stats   *Stats
+	)
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/stats request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
+
+	interval := request.FormValue("interval")
+	if interval == "" {
+		interval = StatsByDay
+	} else if !containsString(statsIntervals, interval) {
+		message := fmt.Sprintf(invalidInterval, interval, strings.Join(statsIntervals, ", "))
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+		return
+	}
 
-	if fc, err = GetScenes(options, &h.Context); err != nil {
+	if options, err = parseSearchOptions(writer, request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if stats, err = GetStats(options, interval, &h.Context); err != nil {
 		switch herr := err.(type) {
 		case util.HTTPErr:
-			util.HTTPError(request, writer, &h.Context, herr.Message, herr.Status)
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
 		default:
-			err = util.LogSimpleErr(&h.Context, "Failed to get Planet Labs scenes. ", err)
+			err = util.LogSimpleErr(&h.Context, "Failed to get Planet Labs scene stats. ", err)
 			util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
 		}
+		return
 	}
 
-	if bytes, err = geojson.Write(fc); err != nil {
-		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output GeoJSON from:\n%#v", fc), err)
+	if bytes, err = json.Marshal(stats); err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output JSON from:\n%#v", stats), err)
 		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	writer.Header().Set("Content-Type", "application/json")
 	writer.Write(bytes)
-	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/discover response", Severity: util.INFO})
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/stats response", Severity: util.INFO})
+}
+
+// parseSearchOptions reads the item types and filters of a discover or stats
+// request: a single item type in the path or several in itemTypes, cloud
+// cover, acquired dates, a bbox or a POSTed AOI, and the other filters
+func parseSearchOptions(writer http.ResponseWriter, request *http.Request) (SearchOptions, error) {
+	var (
+		err     error
+		options SearchOptions
+	)
+
+	requestedItemTypes := []string{mux.Vars(request)["itemType"]}
+	if requestedItemTypes[0] == "" {
+		if requestedItemTypes = parseListParameter(request, "itemTypes"); len(requestedItemTypes) == 0 {
+			return options, errors.New(noItemTypes)
+		}
+	}
+	for _, requestedItemType := range requestedItemTypes {
+		itemType, err := discoverItemType(requestedItemType)
+		if err != nil {
+			return options, err
+		}
+		if !containsString(options.ItemTypes, itemType) {
+			options.ItemTypes = append(options.ItemTypes, itemType)
+		}
+	}
+
+	if ccStr := request.FormValue("cloudCover"); ccStr != "" {
+		if options.CloudCover, err = strconv.ParseFloat(ccStr, 64); err != nil {
+			return options, fmt.Errorf(invalidCloudCover, ccStr)
+		}
+		options.CloudCover = options.CloudCover / 100.0
+	}
+	options.AcquiredDate = request.FormValue("acquiredDate")
+	options.MaxAcquiredDate = request.FormValue("maxAcquiredDate")
+
+	if bboxString := request.FormValue("bbox"); bboxString != "" {
+		if options.Bbox, err = geojson.NewBoundingBox(bboxString); err != nil {
+			return options, fmt.Errorf("The bbox value of %v is invalid", bboxString)
+		}
+	}
+
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxGeometryBodySize))
+		if err == nil {
+			options.Geometry, err = model.NewAOI(body)
+		}
+		if err != nil {
+			return options, fmt.Errorf(invalidGeometry, err)
+		}
+	}
+
+	err = parseSearchFilters(request, &options)
+	return options, err
+}
+
+// discoverItemType maps an item type or its alias to the Planet item type to search
+func discoverItemType(itemType string) (string, error) {
+	if registered, ok := lookupItemType(itemType); ok {
+		return registered.PlanetItemType, nil
+	}
+	return "", fmt.Errorf("The item type value of %v is invalid", itemType)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSearchFilters reads the optional range, list and flag filters of a
+// discover request into its search options
+func parseSearchFilters(request *http.Request, options *SearchOptions) error {
+	var err error
+	ranges := []struct {
+		name        string
+		min, max    float64
+		destination **float64
+	}{
+		{"minSunElevation", -90, 90, &options.MinSunElevation},
+		{"maxSunElevation", -90, 90, &options.MaxSunElevation},
+		{"minViewAngle", -90, 90, &options.MinViewAngle},
+		{"maxViewAngle", -90, 90, &options.MaxViewAngle},
+		{"minGsd", 0, math.MaxFloat64, &options.MinGSD},
+		{"maxGsd", 0, math.MaxFloat64, &options.MaxGSD},
+		{"minUsableData", 0, 100, &options.MinUsableData},
+	}
+	for _, r := range ranges {
+		if *r.destination, err = parseRangeParameter(request, r.name, r.min, r.max); err != nil {
+			return err
+		}
+	}
+	if options.MinUsableData != nil {
+		usableData := *options.MinUsableData / 100.0
+		options.MinUsableData = &usableData
+	}
+
+	options.QualityCategories = parseListParameter(request, "qualityCategory")
+	options.Instruments = parseListParameter(request, "instrument")
+
+	if gcStr := request.FormValue("groundControl"); gcStr != "" {
+		groundControl, err := strconv.ParseBool(gcStr)
+		if err != nil {
+			return fmt.Errorf(invalidGroundControl, gcStr)
+		}
+		options.GroundControl = &groundControl
+	}
+	return nil
+}
 
+// parseRangeParameter parses an optional numeric query parameter, which must
+// lie between min and max
+func parseRangeParameter(request *http.Request, name string, min float64, max float64) (*float64, error) {
+	str := request.FormValue(name)
+	if str == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(value) || value < min || value > max {
+		return nil, fmt.Errorf(invalidRangeParameter, name, str, min, max)
+	}
+	return &value, nil
+}
+
+// parseListParameter splits an optional comma-separated query parameter
+func parseListParameter(request *http.Request, name string) []string {
+	var values []string
+	for _, value := range strings.Split(request.FormValue(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
 }
 
 // MetadataHandler is a handler for /planet
 // @Title planetMetadataHandler
 // @Description Gets image metadata from Planet Labs
 // @Accept  plain
-// @Param   PL_API_KEY      query   string  true         "Planet Labs API Key"
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
 // @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
 // @Param   id              path    string  true         "Planet Labs image ID"
+// @Param   assetType       query   string  false        "The asset to use: analytic (default), analytic_sr, basic_analytic, udm, udm2 or visual"
 // @Param   tides           query   bool    false        "True: incorporate tide prediction in the output"
+// @Param   Cache-Control   header  string  false        "no-cache: ask Planet Labs rather than answering from the broker's cache"
+// @Param   Accept          header  string  false        "application/geo+json; profile=stac: return a STAC Item instead"
 // @Success 200 {object}  geojson.Feature
 // @Failure 400 {object}  string
 // @Router /planet/{itemType}/{id} [get]
@@ -189,7 +522,7 @@ func NewMetadataHandler() MetadataHandler {
 func (h MetadataHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	var (
 		err     error
-		feature *geojson.Feature
+		result  model.GeoJSONFeatureSTACItemCreator
 		bytes   []byte
 		options MetadataOptions
 	)
@@ -208,58 +541,52 @@ func (h MetadataHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
-	h.Context.PlanetKey = request.FormValue("PL_API_KEY")
-
-	if h.Context.PlanetKey == "" {
-		util.LogAlert(&h.Context, noPlanetKey)
-		util.HTTPError(request, writer, &h.Context, noPlanetKey, http.StatusBadRequest)
+	if planetKeyMissing(writer, request, &h.Context) {
 		return
 	}
 
 	options.Tides, _ = strconv.ParseBool(request.FormValue("tides"))
 
-	itemType := vars["itemType"]
-	switch itemType {
-	case "REOrthoTile", "rapideye":
-		options.ItemType = "REOrthoTile"
-		options.ImagerySource = rapidEye
-	case "PSOrthoTile", "planetscope":
-		options.ItemType = "PSOrthoTile"
-		options.ImagerySource = planetScope
-	case "Landsat8L1G", "landsat":
-		options.ItemType = "Landsat8L1G"
-		options.ImagerySource = landsatFromS3
-	case "Sentinel2L1C", "sentinel_planet":
-		options.ItemType = "Sentinel2L1C"
-		options.ImagerySource = sentinelFromPlanet
-	case "sentinel_s3":
-		options.ItemType = "Sentinel2L1C"
-		options.ImagerySource = sentinelFromS3
-	case "PSScene4Band":
-		// No op
-	default:
-		message := fmt.Sprintf("The item type value of %v is invalid", itemType)
+	itemType, ok := lookupItemType(vars["itemType"])
+	if !ok {
+		message := fmt.Sprintf("The item type value of %v is invalid", vars["itemType"])
 		util.LogSimpleErr(&h.Context, message, nil)
 		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
 		return
 	}
+	options.ItemType = itemType.PlanetItemType
+	options.ImagerySource = itemType.ImagerySource
+	if options.AssetType, err = parseAssetType(request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options.NoCache = requestsNoCache(request)
 
-	if feature, err = GetItemWithAssetMetadata(&h.Context, options); err != nil {
+	if result, err = GetItemResult(&h.Context, options); err != nil {
 		switch herr := err.(type) {
 		case util.HTTPErr:
-			util.HTTPError(request, writer, &h.Context, herr.Message, herr.Status)
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
 		default:
 			err = util.LogSimpleErr(&h.Context, "Failed to get Planet Labs scene metadata. ", err)
 			util.HTTPError(request, writer, &h.Context, err.Error(), 0)
 		}
+		return
 	}
 
-	if bytes, err = geojson.Write(feature); err != nil {
-		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output GeoJSON from:\n%#v", feature), err)
+	contentType := "application/json"
+	if util.AcceptsSTAC(request) {
+		bytes, err = writeSTACItem(result)
+		contentType = util.STACContentType
+	} else {
+		bytes, err = writeFeature(result)
+	}
+	if err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output GeoJSON from:\n%#v", result), err)
 		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Content-Type", contentType)
 	writer.Write(bytes)
 
 	util.LogAudit(&h.Context, util.LogAuditInput{Actor: request.URL.String(), Action: request.Method + " response", Actee: "anon user", Message: "Sending planet/{itemType}/{id} response", Severity: util.INFO})
@@ -269,14 +596,19 @@ func (h MetadataHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 // @Title planetActivateHandler
 // @Description Activates a scene
 // @Accept  plain
-// @Param   PL_API_KEY      query   string  true         "Planet Labs API Key"
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
 // @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
 // @Param   id              path    string  true         "Planet Labs image ID"
+// @Param   assetType       query   string  false        "The asset to use: analytic (default), analytic_sr, basic_analytic, udm, udm2 or visual"
+// @Param   callback        query   string  false        "A URL to POST a signed JSON notification to once the asset is active or its activation has failed"
 // @Success 200 {object}  geojson.Feature
 // @Failure 400 {object}  string
 // @Router /planet/activate/{itemType}/{id} [post]
 type ActivateHandler struct {
 	Context Context
+	// Tracker, if set, follows each activation to completion
+	Tracker *ActivationTracker
 }
 
 // NewActivateHandler creates a new handler using configuration
@@ -314,44 +646,52 @@ func (h ActivateHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
-	h.Context.PlanetKey = request.FormValue("PL_API_KEY")
-
-	if h.Context.PlanetKey == "" {
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
 
-		util.LogAlert(&h.Context, noPlanetKey)
-		util.HTTPError(request, writer, &h.Context, noPlanetKey, http.StatusBadRequest)
-		return
-	}
-
-	itemType := vars["itemType"]
-	switch itemType {
-	case "REOrthoTile", "rapideye":
-		options.ItemType = "REOrthoTile"
-		options.ImagerySource = rapidEye
-	case "PSOrthoTile", "planetscope":
-		options.ItemType = "PSOrthoTile"
-		options.ImagerySource = planetScope
-	case "Sentinel2L1C", "sentinel_planet":
-		options.ItemType = "Sentinel2L1C"
-		options.ImagerySource = sentinelFromPlanet
-	case "PSScene4Band":
-		// No op
-	case "sentinel_s3", "landsat":
-		message := fmt.Sprintf("The item type `%v` does not require activation", itemType)
-		util.LogSimpleErr(&h.Context, message, nil)
-		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+	if options.ItemType, options.ImagerySource, err = activatableItemType(vars["itemType"]); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
 		return
-	default:
-		message := fmt.Sprintf("The item type value of %v is invalid", itemType)
-		util.LogSimpleErr(&h.Context, message, nil)
-		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+	}
+	if options.AssetType, err = parseAssetType(request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	options.NoCache = requestsNoCache(request)
+
+	callbackURL := request.FormValue("callback")
+	if callbackURL != "" {
+		if h.Tracker == nil || !h.Tracker.AcceptsCallbacks() {
+			util.LogAlert(&h.Context, noCallbacks)
+			util.HTTPError(request, writer, &h.Context, noCallbacks, http.StatusBadRequest)
+			return
+		}
+		if err = h.Tracker.validateCallbackURL(callbackURL); err != nil {
+			message := fmt.Sprintf(invalidCallback, callbackURL, err)
+			util.LogSimpleErr(&h.Context, message, nil)
+			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+			return
+		}
+	}
+
 	if response, err = Activate(options, &h.Context); err == nil {
 		defer response.Body.Close()
-		writer.Header().Set("Content-Type", response.Header.Get("Content-Type"))
 		if (response.StatusCode >= 200) && (response.StatusCode < 300) {
+			if h.Tracker != nil {
+				if err = h.Tracker.Track(options, h.Context.PlanetKey, callbackURL); err != nil {
+					util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to track activation of scene %v.", options.ID), err)
+					// The client is relying on the callback, which will never come
+					if callbackURL != "" {
+						util.HTTPError(request, writer, &h.Context, fmt.Sprintf(callbackNotTracked, options.ID, err), http.StatusInternalServerError)
+						return
+					}
+				}
+			}
+			writer.Header().Set("Content-Type", response.Header.Get("Content-Type"))
 			bytes, _ := ioutil.ReadAll(response.Body)
 			writer.Write(bytes)
 			util.LogAudit(&h.Context, util.LogAuditInput{Actor: request.URL.String(), Action: request.Method + " response", Actee: "anon user", Message: "Sending planet/{itemType}/{id} response", Severity: util.INFO})
@@ -363,10 +703,517 @@ func (h ActivateHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 	} else {
 		switch herr := err.(type) {
 		case util.HTTPErr:
-			util.HTTPError(request, writer, &h.Context, herr.Message, herr.Status)
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
 		default:
 			err = util.LogSimpleErr(&h.Context, "Failed to activate Planet Labs scene. ", err)
 			util.HTTPError(request, writer, &h.Context, err.Error(), 0)
 		}
 	}
 }
+
+// activatableItemType maps an item type or its alias to the Planet item type
+// and imagery source, rejecting those that need no activation
+func activatableItemType(itemType string) (string, ImagerySource, error) {
+	registered, ok := lookupItemType(itemType)
+	switch {
+	case !ok:
+		return "", 0, fmt.Errorf("The item type value of %v is invalid", itemType)
+	case !registered.RequiresActivation:
+		return "", 0, fmt.Errorf("The item type `%v` does not require activation", itemType)
+	default:
+		return registered.PlanetItemType, registered.ImagerySource, nil
+	}
+}
+
+// parseAssetType reads the asset type a request selects, defaulting to analytic
+func parseAssetType(request *http.Request) (string, error) {
+	assetType := request.FormValue("assetType")
+	if assetType == "" {
+		return defaultAssetType, nil
+	}
+	if !containsString(assetTypes, assetType) {
+		return "", fmt.Errorf(invalidAssetType, assetType, strings.Join(assetTypes, ", "))
+	}
+	return assetType, nil
+}
+
+// ActivationStatusHandler is a handler for /planet/activation
+// @Title planetActivationStatusHandler
+// @Description Reports the state of a scene's activation, as tracked by the broker; with wait, holds the request until the asset is active or the activation has failed
+// @Accept  plain
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Param   id              path    string  true         "Planet Labs image ID"
+// @Param   assetType       query   string  false        "The asset to use: analytic (default), analytic_sr, basic_analytic, udm, udm2 or visual"
+// @Param   wait            query   int     false        "The number of seconds to wait for the activation to settle (0-120)"
+// @Success 200 {object}  planet.Activation
+// @Failure 400 {object}  string
+// @Router /planet/activation/{itemType}/{id} [get]
+type ActivationStatusHandler struct {
+	Context Context
+	Tracker *ActivationTracker
+}
+
+// NewActivationStatusHandler creates a new handler reporting on
+// the activations followed by the given tracker
+func NewActivationStatusHandler(tracker *ActivationTracker) ActivationStatusHandler {
+	return ActivationStatusHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+		},
+		Tracker: tracker,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the ActivationStatusHandler type
+func (h ActivationStatusHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err        error
+		bytes      []byte
+		options    MetadataOptions
+		activation *Activation
+		wait       int
+	)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/activation/{itemType}/{id} request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+	vars := mux.Vars(request)
+	options.ID = vars["id"]
+	if options.ID == "" {
+		util.LogSimpleErr(&h.Context, noPlanetImageID, nil)
+		util.HTTPError(request, writer, &h.Context, noPlanetImageID, http.StatusBadRequest)
+		return
+	}
+
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
+
+	if options.ItemType, options.ImagerySource, err = activatableItemType(vars["itemType"]); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if options.AssetType, err = parseAssetType(request); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if waitStr := request.FormValue("wait"); waitStr != "" {
+		if wait, err = strconv.Atoi(waitStr); err != nil || wait < 0 || wait > maxActivationWait {
+			message := fmt.Sprintf(invalidWait, waitStr, maxActivationWait)
+			util.LogSimpleErr(&h.Context, message, err)
+			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if activation, err = h.Tracker.Wait(options, h.Context.PlanetKey, time.Duration(wait)*time.Second); err != nil {
+		switch herr := err.(type) {
+		case util.HTTPErr:
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
+		default:
+			err = util.LogSimpleErr(&h.Context, "Failed to get activation status. ", err)
+			util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if bytes, err = json.Marshal(activation); err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output JSON from:\n%#v", activation), err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(bytes)
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/activation/{itemType}/{id} response", Severity: util.INFO})
+}
+
+// OrderHandler is a handler for /planet/order
+// @Title planetOrderHandler
+// @Description Orders a set of scenes with the Planet Labs Orders API, optionally clipped to an AOI and reprojected
+// @Accept  json
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   order           body    string  true         "JSON with itemType (e.g., PSScene4Band), itemIds, and optionally name, bundle (default analytic), clip (a GeoJSON Polygon or MultiPolygon) and reproject ({projection, resolution, kernel})"
+// @Success 202 {object}  planet.Order
+// @Failure 400 {object}  string
+// @Router /planet/order [post]
+type OrderHandler struct {
+	Context Context
+}
+
+// NewOrderHandler creates a new handler using configuration
+// from environment variables
+func NewOrderHandler() OrderHandler {
+	return OrderHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+		},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the OrderHandler type
+func (h OrderHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err     error
+		body    []byte
+		reqBody orderRequestBody
+		options OrderOptions
+		order   *Order
+	)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/order request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
+
+	if body, err = ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxGeometryBodySize)); err == nil {
+		err = json.Unmarshal(body, &reqBody)
+	}
+	if err != nil {
+		message := fmt.Sprintf(invalidOrder, err)
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+		return
+	}
+
+	options = OrderOptions{
+		Name:      reqBody.Name,
+		ItemType:  reqBody.ItemType,
+		ItemIDs:   reqBody.ItemIDs,
+		Bundle:    reqBody.Bundle,
+		Reproject: reqBody.Reproject,
+	}
+	if registered, ok := lookupItemType(options.ItemType); ok {
+		options.ItemType = registered.PlanetItemType
+	}
+	if len(reqBody.Clip) > 0 && string(reqBody.Clip) != "null" {
+		if options.Clip, err = model.NewAOI(reqBody.Clip); err != nil {
+			message := fmt.Sprintf(invalidOrder, "the clip AOI is not a valid GeoJSON Polygon or MultiPolygon: "+err.Error())
+			util.LogSimpleErr(&h.Context, message, nil)
+			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+			return
+		}
+	}
+	if options.Reproject != nil && options.Reproject.Projection == "" {
+		message := fmt.Sprintf(invalidOrder, "reproject requires a projection")
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+		return
+	}
+
+	if order, err = CreateOrder(options, &h.Context); err != nil {
+		switch herr := err.(type) {
+		case util.HTTPErr:
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
+		default:
+			err = util.LogSimpleErr(&h.Context, "Failed to place Planet Labs order. ", err)
+			util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if body, err = json.Marshal(order); err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output JSON from:\n%#v", order), err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Location", strings.TrimSuffix(request.URL.Path, "/")+"/"+url.PathEscape(order.ID))
+	writer.WriteHeader(http.StatusAccepted)
+	writer.Write(body)
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/order response", Severity: util.INFO})
+}
+
+// OrderStatusHandler is a handler for /planet/order/{id}
+// @Title planetOrderStatusHandler
+// @Description Polls the state of a Planet Labs order; its delivery locations are listed once it has been fulfilled
+// @Accept  plain
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   id              path    string  true         "Planet Labs order ID"
+// @Success 200 {object}  planet.Order
+// @Failure 400 {object}  string
+// @Router /planet/order/{id} [get]
+type OrderStatusHandler struct {
+	Context Context
+}
+
+// NewOrderStatusHandler creates a new handler using configuration
+// from environment variables
+func NewOrderStatusHandler() OrderStatusHandler {
+	return OrderStatusHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+		},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the OrderStatusHandler type
+func (h OrderStatusHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err   error
+		bytes []byte
+		order *Order
+	)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/order/{id} request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
+
+	if order, err = GetOrder(mux.Vars(request)["id"], &h.Context); err != nil {
+		switch herr := err.(type) {
+		case util.HTTPErr:
+			util.HTTPErrResponse(request, writer, &h.Context, herr)
+		default:
+			err = util.LogSimpleErr(&h.Context, "Failed to get Planet Labs order. ", err)
+			util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if bytes, err = json.Marshal(order); err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output JSON from:\n%#v", order), err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(bytes)
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/order/{id} response", Severity: util.INFO})
+}
+
+// ItemTypesHandler is a handler for /planet/itemtypes
+// @Title planetItemTypesHandler
+// @Description Lists the item types the broker supports, with the Planet Labs item type each maps to, whether it needs activation, and its file format
+// @Accept  plain
+// @Success 200 {object}  []planet.ItemType
+// @Router /planet/itemtypes [get]
+type ItemTypesHandler struct {
+	Context Context
+}
+
+// NewItemTypesHandler creates a new handler using configuration
+// from environment variables
+func NewItemTypesHandler() ItemTypesHandler {
+	return ItemTypesHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+		},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the ItemTypesHandler type
+func (h ItemTypesHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err   error
+		bytes []byte
+	)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/itemtypes request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+
+	itemTypes := ItemTypes()
+	if bytes, err = json.Marshal(itemTypes); err != nil {
+		err = util.LogSimpleErr(&h.Context, fmt.Sprintf("Failed to write output JSON from:\n%#v", itemTypes), err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(bytes)
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/itemtypes response", Severity: util.INFO})
+}
+
+// PreviewHandler is a handler for /planet/preview
+// @Title planetPreviewHandler
+// @Description Gets the thumbnail of a scene from Planet Labs as a PNG, with caching headers
+// @Accept  plain
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Param   id              path    string  true         "Planet Labs image ID"
+// @Param   width           query   int     false        "The width of the thumbnail, in pixels (1-2048)"
+// @Success 200 {object}  string
+// @Success 304 {object}  string
+// @Failure 400 {object}  string
+// @Router /planet/preview/{itemType}/{id} [get]
+type PreviewHandler struct {
+	Context Context
+}
+
+// NewPreviewHandler creates a new handler using configuration
+// from environment variables
+func NewPreviewHandler() PreviewHandler {
+	return PreviewHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+			BaseTilesURL:  util.GetPlanetTilesURL(),
+		},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the PreviewHandler type
+func (h PreviewHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err      error
+		width    int
+		response *http.Response
+	)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/preview/{itemType}/{id} request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
+
+	vars := mux.Vars(request)
+	itemType, ok := lookupItemType(vars["itemType"])
+	if !ok {
+		message := fmt.Sprintf("The item type value of %v is invalid", vars["itemType"])
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+		return
+	}
+	if widthStr := request.FormValue("width"); widthStr != "" {
+		if width, err = strconv.Atoi(widthStr); err != nil || width < 1 || width > maxThumbnailWidth {
+			message := fmt.Sprintf(invalidWidth, widthStr, maxThumbnailWidth)
+			util.LogSimpleErr(&h.Context, message, err)
+			util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if response, err = GetThumbnail(itemType.PlanetItemType, vars["id"], width, request.Header, &h.Context); err != nil {
+		writeImageError(writer, request, &h.Context, "Failed to get the Planet Labs thumbnail. ", err)
+		return
+	}
+	defer response.Body.Close()
+	if err = writeImageResponse(writer, response); err != nil {
+		util.LogSimpleErr(&h.Context, "Failed to send the Planet Labs thumbnail. ", err)
+		return
+	}
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/preview/{itemType}/{id} response", Severity: util.INFO})
+}
+
+// TileHandler is a handler for /planet/tiles
+// @Title planetTileHandler
+// @Description Gets an XYZ map tile of a scene from Planet Labs as a PNG, with caching headers
+// @Accept  plain
+// @Param   Authorization   header  string  false        "Bearer and a broker token, or api-key and a Planet Labs API Key"
+// @Param   PL_API_KEY      query   string  false        "Deprecated: Planet Labs API Key; use the Authorization header instead"
+// @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Param   id              path    string  true         "Planet Labs image ID"
+// @Param   z               path    int     true         "The zoom level (0-22)"
+// @Param   x               path    int     true         "The tile column"
+// @Param   y               path    int     true         "The tile row"
+// @Success 200 {object}  string
+// @Success 304 {object}  string
+// @Failure 400 {object}  string
+// @Router /planet/tiles/{itemType}/{id}/{z}/{x}/{y}.png [get]
+type TileHandler struct {
+	Context Context
+}
+
+// NewTileHandler creates a new handler using configuration
+// from environment variables
+func NewTileHandler() TileHandler {
+	return TileHandler{
+		Context: Context{
+			BasePlanetURL: util.GetPlanetAPIURL(),
+			BaseTilesURL:  util.GetPlanetTilesURL(),
+		},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the TileHandler type
+func (h TileHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var (
+		err      error
+		z, x, y  int
+		response *http.Response
+	)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/tiles/{itemType}/{id}/{z}/{x}/{y}.png request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+	if planetKeyMissing(writer, request, &h.Context) {
+		return
+	}
+
+	vars := mux.Vars(request)
+	itemType, ok := lookupItemType(vars["itemType"])
+	if !ok {
+		message := fmt.Sprintf("The item type value of %v is invalid", vars["itemType"])
+		util.LogSimpleErr(&h.Context, message, nil)
+		util.HTTPError(request, writer, &h.Context, message, http.StatusBadRequest)
+		return
+	}
+	if z, x, y, err = parseTileCoordinates(vars["z"], vars["x"], vars["y"]); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if response, err = GetTile(itemType.PlanetItemType, vars["id"], z, x, y, request.Header, &h.Context); err != nil {
+		writeImageError(writer, request, &h.Context, "Failed to get the Planet Labs tile. ", err)
+		return
+	}
+	defer response.Body.Close()
+	if err = writeImageResponse(writer, response); err != nil {
+		util.LogSimpleErr(&h.Context, "Failed to send the Planet Labs tile. ", err)
+		return
+	}
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/tiles/{itemType}/{id}/{z}/{x}/{y}.png response", Severity: util.INFO})
+}
+
+// parseTileCoordinates checks that z, x and y name a tile in the XYZ scheme
+func parseTileCoordinates(zStr string, xStr string, yStr string) (int, int, int, error) {
+	invalid := fmt.Errorf(invalidTileCoordinates, zStr, xStr, yStr, maxTileZoom)
+	z, err := strconv.Atoi(zStr)
+	if err != nil || z < 0 || z > maxTileZoom {
+		return 0, 0, 0, invalid
+	}
+	x, err := strconv.Atoi(xStr)
+	if err != nil || x < 0 || x >= 1<<uint(z) {
+		return 0, 0, 0, invalid
+	}
+	y, err := strconv.Atoi(yStr)
+	if err != nil || y < 0 || y >= 1<<uint(z) {
+		return 0, 0, 0, invalid
+	}
+	return z, x, y, nil
+}
+
+func writeImageError(writer http.ResponseWriter, request *http.Request, context *Context, message string, err error) {
+	switch herr := err.(type) {
+	case util.HTTPErr:
+		util.HTTPErrResponse(request, writer, context, herr)
+	default:
+		err = util.LogSimpleErr(context, message, err)
+		util.HTTPError(request, writer, context, err.Error(), 0)
+	}
+}
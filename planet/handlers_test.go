@@ -15,12 +15,22 @@
 package planet
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
 	"github.com/venicegeo/geojson-go/geojson"
 )
 
@@ -60,6 +70,131 @@ func TestDiscoverHandlerSuccess(t *testing.T) {
 	assert.Nil(t, err, "Expected to parse GeoJSON but received: %v", err)
 }
 
+func TestDiscoverHandlerSTAC(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey)
+	recorder := httptest.NewRecorder()
+
+	request := httptest.NewRequest("GET", url, nil)
+	request.Header.Set("Accept", "application/geo+json; profile=stac")
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "application/geo+json", recorder.Header().Get("Content-Type"))
+
+	var collection model.STACItemCollection
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.NotEmpty(t, collection.Features)
+	assert.Equal(t, len(collection.Features), collection.NumberReturned)
+	assert.Equal(t, model.STACVersion, collection.Features[0].STACVersion)
+}
+
+func TestDiscoverHandlerAtom(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey) + "&format=atom"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "application/atom+xml", recorder.Header().Get("Content-Type"))
+	assert.NotContains(t, recorder.Body.String(), testingValidKey)
+
+	var feed struct {
+		Entries []struct {
+			ID       string   `xml:"id"`
+			Polygons []string `xml:"polygon"`
+		} `xml:"entry"`
+	}
+	assert.Nil(t, xml.Unmarshal(recorder.Body.Bytes(), &feed))
+	assert.NotEmpty(t, feed.Entries)
+	assert.Contains(t, feed.Entries[0].ID, "/planet/rapideye/")
+	assert.NotEmpty(t, feed.Entries[0].Polygons)
+}
+
+func TestOpenSearchHandler(t *testing.T) {
+	_, _, router := createTestFixtures()
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/planet/opensearch/rapideye", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "application/opensearchdescription+xml", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "/planet/discover/rapideye?bbox={geo:box?}&amp;")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/planet/opensearch/nosuchtype", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestDiscoverHandlerNextLink(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey) + "&page_size=2"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	var result model.PagedFeatureCollection
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.Nil(t, err, "Expected to parse paged GeoJSON but received: %v", err)
+	assert.Len(t, result.Features, 2)
+	if assert.Len(t, result.Links, 1) {
+		assert.Equal(t, "next", result.Links[0].Rel)
+		assert.Contains(t, result.Links[0].Href, "/planet/discover/rapideye?")
+		assert.Contains(t, result.Links[0].Href, "cursor=")
+		assert.Contains(t, result.Links[0].Href, "page_size=2")
+	}
+}
+
+func TestDiscoverHandlerInvalidPaging(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	for _, params := range []string{"&page_size=0", "&page_size=251", "&limit=x", "&limit=100000"} {
+		url := makeDiscoverTestingURL(mockServer.URL, testingValidKey) + params
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code,
+			"Expected request with %v to fail but received: %v, %v", params, recorder.Code, recorder.Body.String(),
+		)
+	}
+}
+
+func TestDiscoverHandlerPostGeometry(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, strings.NewReader(testingAOIGeometry)))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	fc, err := geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Nil(t, err, "Expected to parse GeoJSON but received: %v", err)
+	for _, feature := range fc.Features {
+		_, ok := feature.Properties["aoiCoverage"]
+		assert.True(t, ok, "Expected feature %v to have AOI coverage", feature.IDStr())
+	}
+}
+
+func TestDiscoverHandlerPostInvalidGeometry(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, strings.NewReader(`{"type":"Point","coordinates":[1,2]}`)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code,
+		"Expected request to fail but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+}
+
 func TestMetadataHandlerSuccess(t *testing.T) {
 	mockServer, _, router := createTestFixtures()
 	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID)
@@ -71,6 +206,28 @@ func TestMetadataHandlerSuccess(t *testing.T) {
 	)
 }
 
+func TestMetadataHandlerSTAC(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID)
+	recorder := httptest.NewRecorder()
+
+	request := httptest.NewRequest("GET", url, nil)
+	request.Header.Set("Accept", "application/geo+json; profile=stac")
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "application/geo+json", recorder.Header().Get("Content-Type"))
+
+	var item model.STACItem
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &item))
+	assert.Equal(t, model.STACVersion, item.STACVersion)
+	assert.Equal(t, testingValidItemID, item.ID)
+	assert.Contains(t, item.Properties, "datetime")
+	assert.Contains(t, item.Properties, "eo:cloud_cover")
+	assert.NotEmpty(t, item.Assets)
+}
+
 func TestMetadataHandlerImageIDNotFound(t *testing.T) {
 	mockServer, _, router := createTestFixtures()
 	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", "")
@@ -82,6 +239,67 @@ func TestMetadataHandlerImageIDNotFound(t *testing.T) {
 	)
 }
 
+func TestMetadataHandlerAssetType(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID) + "&assetType=udm2"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	var feature struct {
+		Properties struct {
+			Type   string                            `json:"type"`
+			Status string                            `json:"status"`
+			Assets map[string]map[string]interface{} `json:"assets"`
+		} `json:"properties"`
+	}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &feature))
+	assert.Equal(t, "udm2", feature.Properties.Type)
+	assert.Equal(t, "inactive", feature.Properties.Status)
+	for _, assetType := range []string{"analytic", "udm", "udm2", "visual"} {
+		assert.Contains(t, feature.Properties.Assets, assetType)
+		assert.NotEmpty(t, feature.Properties.Assets[assetType]["activate"], assetType)
+	}
+	assert.Equal(t, "active", feature.Properties.Assets["analytic"]["status"])
+}
+
+func TestMetadataHandlerUnavailableAssetType(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID) + "&assetType=analytic_sr"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code,
+		"Expected request to return a 404 but it returned a %v.", recorder.Code,
+	)
+}
+
+func TestMetadataHandlerInvalidAssetType(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID) + "&assetType=thumbnail"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code,
+		"Expected request to return a 400 but it returned a %v.", recorder.Code,
+	)
+}
+
+func TestActivateHandlerAssetType(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeActivateTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingValidItemID) + "&assetType=udm2"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Unexpected error in response to request. %v %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, testingSampleActivateResult, recorder.Body.String())
+}
+
 func TestActivateHandlerInvalidKey(t *testing.T) {
 	mockServer, _, router := createTestFixtures()
 	url := makeActivateTestingURL(mockServer.URL, testingInvalidKey, testingValidItemType, testingValidItemID)
@@ -107,3 +325,532 @@ func TestActivateHandlerSuccess(t *testing.T) {
 		"Unexpected result for asset activation query",
 	)
 }
+
+func TestOrderHandlerSuccess(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeOrderTestingURL(mockServer.URL, testingValidKey)
+	recorder := httptest.NewRecorder()
+	body := `{"itemType":"REOrthoTile","itemIds":["foobar123"],"clip":` + testingAOIGeometry + `,"reproject":{"projection":"EPSG:32618"}}`
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, recorder.Code,
+		"Expected order to be accepted but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "/planet/order/"+testingValidOrderID, recorder.Header().Get("Location"))
+
+	var order Order
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &order))
+	assert.Equal(t, testingValidOrderID, order.ID)
+	assert.Equal(t, "queued", order.State)
+	assert.Contains(t, string(testingLastOrderBody), `"clip"`)
+	assert.Contains(t, string(testingLastOrderBody), `"reproject":{"projection":"EPSG:32618"}`)
+}
+
+func TestOrderHandlerInvalidBody(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeOrderTestingURL(mockServer.URL, testingValidKey)
+
+	for _, body := range []string{
+		`not json`,
+		`{"itemType":"REOrthoTile","itemIds":["foobar123"],"clip":{"type":"Point","coordinates":[1,2]}}`,
+		`{"itemType":"REOrthoTile","itemIds":["foobar123"],"reproject":{"kernel":"cubic"}}`,
+		`{"itemType":"REOrthoTile","itemIds":[]}`,
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("POST", url, strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code,
+			"Expected order %v to be rejected but received: %v, %v", body, recorder.Code, recorder.Body.String(),
+		)
+	}
+}
+
+func TestOrderStatusHandlerSuccess(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeOrderStatusTestingURL(mockServer.URL, testingValidKey, testingValidOrderID)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	var order Order
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &order))
+	assert.Equal(t, "success", order.State)
+	assert.Len(t, order.Locations, 2)
+}
+
+func TestOrderStatusHandlerInvalidKey(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeOrderStatusTestingURL(mockServer.URL, testingInvalidKey, testingValidOrderID)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code,
+		"Expected request to return a 401 but it returned a %v.", recorder.Code,
+	)
+}
+
+func TestActivationStatusHandlerSuccess(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeActivationTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingValidItemID)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	var activation map[string]interface{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &activation))
+	assert.Equal(t, "active", activation["status"])
+	assert.NotEmpty(t, activation["location"])
+	assert.NotEmpty(t, activation["expires_at"])
+}
+
+func TestActivationStatusHandlerWait(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	// Activating the scene looks up its assets once before the tracker polls
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 3)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", makeActivateTestingURL(mockServer.URL, testingValidKey, "rapideye", testingActivatingItemID), nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected activation to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	recorder = httptest.NewRecorder()
+	url := makeActivationTestingURL(mockServer.URL, testingValidKey, "rapideye", testingActivatingItemID) + "&wait=5"
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	var activation Activation
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &activation))
+	assert.Equal(t, "active", activation.Status)
+	assert.NotEmpty(t, activation.Location)
+}
+
+func TestActivationStatusHandlerInvalidParameters(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+
+	for _, url := range []string{
+		makeActivationTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingValidItemID) + "&wait=500",
+		makeActivationTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingValidItemID) + "&wait=soon",
+		makeActivationTestingURL(mockServer.URL, testingValidKey, "landsat", testingValidItemID),
+		makeActivationTestingURL(mockServer.URL, "", testingValidItemType, testingValidItemID),
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code,
+			"Expected %v to be rejected but received: %v, %v", url, recorder.Code, recorder.Body.String(),
+		)
+	}
+}
+
+func TestActivateHandlerCallback(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	callbackServer, requests, _ := createMockCallbackServer(http.StatusOK)
+	atomic.StoreInt32(&testingActivationPollsUntilActive, 1)
+	url := makeActivateTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingActivatingItemID) + "&callback=" + callbackServer.URL
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Unexpected error in response to request. %v %v", recorder.Code, recorder.Body.String(),
+	)
+
+	select {
+	case request := <-requests:
+		assert.NotEmpty(t, request.Header.Get(callbackSignatureHeader))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a callback")
+	}
+}
+
+func TestActivateHandlerCallbackNotTracked(t *testing.T) {
+	mockServer, _, _ := createTestFixtures()
+	callbackServer, _, _ := createMockCallbackServer(http.StatusOK)
+	tracker := newTestingActivationTracker(mockServer.URL)
+	tracker.store.(*memoryActivationStore).putErr = errors.New("database unavailable")
+	activateHandler := NewActivateHandler()
+	activateHandler.Tracker = tracker
+	router := mux.NewRouter()
+	router.Handle("/planet/activate/{itemType}/{id}", activateHandler)
+	url := makeActivateTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingActivatingItemID)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url+"&callback="+callbackServer.URL, nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code,
+		"Expected an untracked callback to fail the request but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Contains(t, recorder.Body.String(), "callback could not be registered")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected activation without a callback to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+}
+
+func TestActivateHandlerInvalidCallback(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeActivateTestingURL(mockServer.URL, testingValidKey, testingValidItemType, testingValidItemID) + "&callback=ftp://example.com/callback"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", url, nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code,
+		"Expected request to return a 400 but it returned a %v.", recorder.Code,
+	)
+}
+
+func TestDiscoverHandlerExtendedFilters(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey) +
+		"&minSunElevation=25&maxViewAngle=5&maxGsd=4&minUsableData=80&qualityCategory=standard&instrument=PS2,PS2.SD&groundControl=true"
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	body := string(testingLastQuickSearchBody)
+	assert.Contains(t, body, `"field_name":"sun_elevation","config":{"gte":25}`)
+	assert.Contains(t, body, `"field_name":"view_angle","config":{"lte":5}`)
+	assert.Contains(t, body, `"field_name":"gsd","config":{"lte":4}`)
+	assert.Contains(t, body, `"field_name":"usable_data","config":{"gte":0.8}`)
+	assert.Contains(t, body, `"field_name":"quality_category","config":["standard"]`)
+	assert.Contains(t, body, `"field_name":"instrument","config":["PS2","PS2.SD"]`)
+	assert.Contains(t, body, `"field_name":"ground_control","config":["true"]`)
+}
+
+func TestDiscoverHandlerInvalidFilters(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+
+	for _, parameter := range []string{"minSunElevation=high", "maxSunElevation=91", "maxViewAngle=NaN", "minGsd=-1", "minUsableData=101", "groundControl=maybe"} {
+		url := makeDiscoverTestingURL(mockServer.URL, testingValidKey) + "&" + parameter
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code,
+			"Expected %v to be rejected but received: %v, %v", parameter, recorder.Code, recorder.Body.String(),
+		)
+	}
+}
+
+func TestDiscoverHandlerMultipleItemTypes(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := fmt.Sprintf("%s/planet/discover?PL_API_KEY=%s&itemTypes=%s", mockServer.URL, testingValidKey, "rapideye,PSOrthoTile,planetscope,landsat")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Contains(t, string(testingLastQuickSearchBody), `"item_types":["REOrthoTile","PSOrthoTile","Landsat8L1G"]`)
+
+	fc, err := geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Nil(t, err, "Expected to parse GeoJSON but received: %v", err)
+	assert.Equal(t, "Landsat8L1G", fc.Features[1].PropertyString("itemType"))
+}
+
+func TestDiscoverHandlerInvalidItemTypes(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+
+	for _, query := range []string{"", "&itemTypes=", "&itemTypes=rapideye,worldview"} {
+		url := fmt.Sprintf("%s/planet/discover?PL_API_KEY=%s%s", mockServer.URL, testingValidKey, query)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code,
+			"Expected %v to be rejected but received: %v, %v", query, recorder.Code, recorder.Body.String(),
+		)
+	}
+}
+
+func TestItemTypesHandler(t *testing.T) {
+	_, _, router := createTestFixtures()
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/planet/itemtypes", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+
+	var itemTypes []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &itemTypes))
+	assert.Len(t, itemTypes, len(ItemTypes()))
+	for _, itemType := range itemTypes {
+		if itemType["name"] == "landsat" {
+			assert.Equal(t, "Landsat8L1G", itemType["planetItemType"])
+			assert.Equal(t, false, itemType["requiresActivation"])
+		}
+	}
+}
+
+func TestMetadataHandlerRateLimited(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	atomic.StoreInt32(&testingRateLimitsRemaining, 100)
+	defer atomic.StoreInt32(&testingRateLimitsRemaining, 0)
+	url := makeMetadataTestingURL(mockServer.URL, testingValidKey, "rapideye", testingRateLimitedItemID)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code,
+		"Expected request to return a 503 but it returned a %v.", recorder.Code,
+	)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+}
+
+func TestDiscoverHandlerRateLimited(t *testing.T) {
+	rateLimitedServer := createRateLimitedPlanetServer()
+	defer rateLimitedServer.Close()
+	router := createTestRouter(rateLimitedServer.URL, rateLimitedServer.URL)
+	url := makeDiscoverTestingURL(rateLimitedServer.URL, testingValidKey)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code,
+		"Expected request to return a 503 but it returned a %v.", recorder.Code,
+	)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+}
+
+func TestStatsHandlerRateLimited(t *testing.T) {
+	rateLimitedServer := createRateLimitedPlanetServer()
+	defer rateLimitedServer.Close()
+	router := createTestRouter(rateLimitedServer.URL, rateLimitedServer.URL)
+	url := makeStatsTestingURL(rateLimitedServer.URL, testingValidKey, "rapideye")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code,
+		"Expected request to return a 503 but it returned a %v.", recorder.Code,
+	)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+}
+
+func TestDiscoverHandlerAuthorizationHeader(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	keyStore, _ := NewKeyStore([]byte(testingKeyStoreJSON()))
+	SetKeyStore(keyStore)
+	defer SetKeyStore(&KeyStore{})
+	url := mockServer.URL + "/planet/discover/rapideye"
+
+	for _, authorization := range []string{"api-key " + testingValidKey, "Bearer " + testingBrokerToken} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", url, nil)
+		request.Header.Set("Authorization", authorization)
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusOK, recorder.Code,
+			"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+		)
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", url, nil)
+	request.Header.Set("Authorization", "Bearer SOME_OTHER_TOKEN")
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code,
+		"Expected request to return a 401 but it returned a %v.", recorder.Code,
+	)
+}
+
+func TestPreviewHandler(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	recorder := httptest.NewRecorder()
+
+	url := makePreviewTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidItemID)
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&width=256", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "image/png", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, testingImageETag, recorder.Header().Get("ETag"))
+	assert.Equal(t, defaultImageCacheControl, recorder.Header().Get("Cache-Control"))
+	assert.Equal(t, testingSampleImage, recorder.Body.Bytes())
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest("GET", url, nil)
+	request.Header.Set("If-None-Match", testingImageETag)
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Empty(t, recorder.Body.Bytes())
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&width=0", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	url = makePreviewTestingURL(mockServer.URL, testingValidKey, "rapideye", testingValidSceneIDWithNoMetadata)
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	url = makePreviewTestingURL(mockServer.URL, testingInvalidKey, "rapideye", testingValidItemID)
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestTileHandler(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	recorder := httptest.NewRecorder()
+
+	url := makeTileTestingURL(mockServer.URL, testingValidKey, "planetscope", testingValidItemID, "10", "300", "400")
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code,
+		"Expected request to succeed but received: %v, %v", recorder.Code, recorder.Body.String(),
+	)
+	assert.Equal(t, "image/png", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, testingSampleImage, recorder.Body.Bytes())
+
+	for _, zxy := range [][3]string{{"23", "0", "0"}, {"2", "4", "0"}, {"2", "0", "-1"}, {"a", "0", "0"}} {
+		recorder = httptest.NewRecorder()
+		url = makeTileTestingURL(mockServer.URL, testingValidKey, "planetscope", testingValidItemID, zxy[0], zxy[1], zxy[2])
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Expected tile %v to be rejected", zxy)
+	}
+
+	recorder = httptest.NewRecorder()
+	url = makeTileTestingURL(mockServer.URL, testingValidKey, "nonsense", testingValidItemID, "0", "0", "0")
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestDiscoverHandlerPermissions(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&permissions=annotate", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	fc, err := geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Len(t, fc.Features, 2)
+	assert.Equal(t, true, fc.Features[0].Properties["downloadable"])
+	assert.Equal(t, true, fc.Features[1].Properties["downloadable"], "Landsat imagery is hosted on S3, so needs no Planet permissions")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&permissions=annotate&assetType=visual", nil))
+	fc, _ = geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Len(t, fc.Features, 2)
+	assert.Equal(t, false, fc.Features[0].Properties["downloadable"])
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&permissions=filter&assetType=visual", nil))
+	fc, _ = geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Len(t, fc.Features, 1)
+	assert.Equal(t, "Landsat8L1G", fc.Features[0].PropertyString("itemType"))
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&permissions=off&assetType=visual", nil))
+	fc, _ = geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Len(t, fc.Features, 2)
+	_, ok := fc.Features[0].Properties["downloadable"]
+	assert.False(t, ok)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&permissions=sometimes", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestParsePermissionsDefault(t *testing.T) {
+	defer func(disabled bool) { disablePermissionsCheck = disabled }(disablePermissionsCheck)
+	request := httptest.NewRequest("GET", "/planet/discover/rapideye", nil)
+
+	disablePermissionsCheck = false
+	permissions, err := parsePermissions(request)
+	assert.Nil(t, err)
+	assert.Equal(t, permissionsAnnotate, permissions)
+
+	disablePermissionsCheck = true
+	permissions, err = parsePermissions(request)
+	assert.Nil(t, err)
+	assert.Equal(t, permissionsOff, permissions)
+}
+
+func TestFederatedSearchSort(t *testing.T) {
+	mockServer, _, _ := createTestFixtures()
+	handler := NewDiscoverHandler()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey)
+
+	testingLastQuickSearchSort = ""
+	_, err := handler.FederatedSearch(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	assert.Nil(t, err, "Expected the search to succeed; received: %v", err)
+	assert.Equal(t, "acquired desc", testingLastQuickSearchSort, "Expected Planet to sort by the federated default")
+
+	_, err = handler.FederatedSearch(httptest.NewRecorder(), httptest.NewRequest("GET", url+"&sort=acquiredDate", nil))
+	assert.Nil(t, err, "Expected the search to succeed; received: %v", err)
+	assert.Equal(t, "acquired asc", testingLastQuickSearchSort)
+
+	_, err = handler.FederatedSearch(httptest.NewRecorder(), httptest.NewRequest("GET", url+"&sort=-cloudCover", nil))
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr but got %v", err)
+	assert.Equal(t, http.StatusBadRequest, herr.Status)
+	assert.Contains(t, herr.Message, "-cloudCover")
+}
+
+func TestDiscoverHandlerSort(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeDiscoverTestingURL(mockServer.URL, testingValidKey)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&sort=-cloudCover", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "", testingLastQuickSearchSort, "Planet cannot sort by cloud cover")
+	fc, err := geojson.FeatureCollectionFromBytes(recorder.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Len(t, fc.Features, 2)
+	assert.True(t, fc.Features[0].PropertyFloat("cloudCover") > fc.Features[1].PropertyFloat("cloudCover"))
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&sort=acquiredDate", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "acquired asc", testingLastQuickSearchSort)
+
+	for _, invalid := range []string{"&sort=resolution", "&sort=tide", "&sort=--cloudCover", "&limit=0"} {
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url+invalid, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Expected %v to be rejected", invalid)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	mockServer, _, router := createTestFixtures()
+	url := makeStatsTestingURL(mockServer.URL, testingValidKey, "rapideye")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url+"&interval=month&cloudCover=20&bbox=-10,-10,10,10", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	body := string(testingLastStatsBody)
+	assert.Contains(t, body, `"item_types":["REOrthoTile"]`)
+	assert.Contains(t, body, `"interval":"month"`)
+	assert.Contains(t, body, `"field_name":"cloud_cover","config":{"lte":0.2}`)
+
+	var stats Stats
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &stats))
+	assert.Equal(t, "month", stats.Interval)
+	assert.Equal(t, []string{"REOrthoTile"}, stats.ItemTypes)
+	assert.Equal(t, []StatsBucket{{Start: "2018-01-01T00:00:00.000000Z", Count: 3}, {Start: "2018-01-02T00:00:00.000000Z", Count: 5}}, stats.Buckets)
+	assert.Equal(t, 8, stats.Total)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, string(testingLastStatsBody), `"interval":"day"`)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", fmt.Sprintf("%s/planet/stats?itemTypes=rapideye,landsat&PL_API_KEY=%s", mockServer.URL, testingValidKey), nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, string(testingLastStatsBody), `"item_types":["REOrthoTile","Landsat8L1G"]`)
+
+	for _, invalid := range []string{"&interval=year", "&cloudCover=cloudy", "&bbox=1,2"} {
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url+invalid, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Expected %v to be rejected", invalid)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", makeStatsTestingURL(mockServer.URL, testingInvalidKey, "rapideye"), nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,84 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"github.com/venicegeo/bf-ia-broker/model"
+)
+
+// BandSource says where the imagery of an item type's results is found,
+// and so which mixin describes it
+type BandSource string
+
+const (
+	// PlanetAssetBands are activateable assets hosted by Planet itself
+	PlanetAssetBands BandSource = "planetAssets"
+	// LandsatS3Bands are hosted on an external Landsat S3 archive
+	LandsatS3Bands BandSource = "landsatS3"
+	// SentinelS3Bands are hosted on an external Sentinel-2 S3 archive
+	SentinelS3Bands BandSource = "sentinelS3"
+)
+
+// ItemType describes an item type the broker supports
+type ItemType struct {
+	Name               string                 `json:"name"`
+	PlanetItemType     string                 `json:"planetItemType"`
+	ImagerySource      ImagerySource          `json:"-"`
+	RequiresActivation bool                   `json:"requiresActivation"`
+	FileFormat         model.BrokerFileFormat `json:"fileFormat"`
+	Bands              BandSource             `json:"bands"`
+}
+
+// itemTypes is the registry of supported item types. Each may be requested by
+// its name or its Planet item type; where several share a Planet item type,
+// the first listed is the one that Planet item type means.
+var itemTypes = []ItemType{
+	{Name: "rapideye", PlanetItemType: "REOrthoTile", ImagerySource: rapidEye, RequiresActivation: true, FileFormat: model.GeoTIFF, Bands: PlanetAssetBands},
+	{Name: "planetscope", PlanetItemType: "PSOrthoTile", ImagerySource: planetScope, RequiresActivation: true, FileFormat: model.GeoTIFF, Bands: PlanetAssetBands},
+	{Name: "planetscope_scene", PlanetItemType: "PSScene4Band", ImagerySource: planetScopeScene, RequiresActivation: true, FileFormat: model.GeoTIFF, Bands: PlanetAssetBands},
+	{Name: "landsat", PlanetItemType: "Landsat8L1G", ImagerySource: landsatFromS3, RequiresActivation: false, FileFormat: model.GeoTIFF, Bands: LandsatS3Bands},
+	{Name: "sentinel_planet", PlanetItemType: "Sentinel2L1C", ImagerySource: sentinelFromPlanet, RequiresActivation: true, FileFormat: model.GeoTIFF, Bands: PlanetAssetBands},
+	{Name: "sentinel_s3", PlanetItemType: "Sentinel2L1C", ImagerySource: sentinelFromS3, RequiresActivation: false, FileFormat: model.JPEG2000, Bands: SentinelS3Bands},
+}
+
+// ItemTypes lists the supported item types
+func ItemTypes() []ItemType {
+	return append([]ItemType{}, itemTypes...)
+}
+
+// lookupItemType finds a supported item type by its name or Planet item type
+func lookupItemType(name string) (ItemType, bool) {
+	for _, itemType := range itemTypes {
+		if itemType.Name == name {
+			return itemType, true
+		}
+	}
+	for _, itemType := range itemTypes {
+		if itemType.PlanetItemType == name {
+			return itemType, true
+		}
+	}
+	return ItemType{}, false
+}
+
+// itemTypeForImagerySource finds the item type an imagery source belongs to
+func itemTypeForImagerySource(imagerySource ImagerySource) (ItemType, bool) {
+	for _, itemType := range itemTypes {
+		if itemType.ImagerySource == imagerySource {
+			return itemType, true
+		}
+	}
+	return ItemType{}, false
+}
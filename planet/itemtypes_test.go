@@ -0,0 +1,63 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/model"
+)
+
+func TestLookupItemType(t *testing.T) {
+	itemType, ok := lookupItemType("rapideye")
+	assert.True(t, ok)
+	assert.Equal(t, "REOrthoTile", itemType.PlanetItemType)
+	assert.Equal(t, rapidEye, itemType.ImagerySource)
+
+	itemType, ok = lookupItemType("PSOrthoTile")
+	assert.True(t, ok)
+	assert.Equal(t, "planetscope", itemType.Name)
+
+	// A shared Planet item type means the first entry listing it
+	itemType, ok = lookupItemType("Sentinel2L1C")
+	assert.True(t, ok)
+	assert.Equal(t, sentinelFromPlanet, itemType.ImagerySource)
+
+	itemType, ok = lookupItemType("sentinel_s3")
+	assert.True(t, ok)
+	assert.Equal(t, "Sentinel2L1C", itemType.PlanetItemType)
+	assert.Equal(t, model.JPEG2000, itemType.FileFormat)
+	assert.Equal(t, SentinelS3Bands, itemType.Bands)
+
+	_, ok = lookupItemType("not_an_item_type")
+	assert.False(t, ok)
+}
+
+func TestItemTypesAreConsistent(t *testing.T) {
+	names := map[string]bool{}
+	imagerySources := map[ImagerySource]bool{}
+	for _, itemType := range ItemTypes() {
+		assert.False(t, names[itemType.Name], "Duplicate item type name %v", itemType.Name)
+		assert.False(t, imagerySources[itemType.ImagerySource], "Duplicate imagery source for %v", itemType.Name)
+		names[itemType.Name] = true
+		imagerySources[itemType.ImagerySource] = true
+
+		assert.NotEmpty(t, itemType.PlanetItemType)
+		// Only imagery hosted by Planet needs activating
+		assert.Equal(t, itemType.Bands == PlanetAssetBands, itemType.RequiresActivation, itemType.Name)
+		assert.Equal(t, itemType.RequiresActivation, imagerySourceRequiresActivation(itemType.ImagerySource), itemType.Name)
+	}
+}
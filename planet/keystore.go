@@ -0,0 +1,233 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+const invalidAuthorization = "The Authorization header is invalid; use Bearer with a broker token, api-key with a Planet Labs API key, or Basic with the key as the username."
+const unknownBrokerToken = "The broker token is not recognized."
+
+// KeyStore maps the tokens of broker users to their tenants' Planet keys, so
+// that those keys never leave the server
+type KeyStore struct {
+	tenants map[string]string // token hash to tenant
+	keys    map[string]string // tenant to Planet key
+}
+
+// keyStoreFile is the JSON layout of a key store. Tokens are listed as the
+// hex SHA-256 hashes of the tokens users present.
+type keyStoreFile struct {
+	Tenants map[string]struct {
+		PlanetKey string   `json:"planetKey"`
+		Tokens    []string `json:"tokens"`
+	} `json:"tenants"`
+}
+
+// NewKeyStore parses a key store from its JSON form
+func NewKeyStore(data []byte) (*KeyStore, error) {
+	var file keyStoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Failed to parse the key store: %v", err)
+	}
+	keyStore := KeyStore{tenants: map[string]string{}, keys: map[string]string{}}
+	for tenant, entry := range file.Tenants {
+		if entry.PlanetKey == "" {
+			return nil, fmt.Errorf("Tenant %v has no Planet key", tenant)
+		}
+		keyStore.keys[tenant] = entry.PlanetKey
+		for _, token := range entry.Tokens {
+			token = strings.ToLower(token)
+			if other, ok := keyStore.tenants[token]; ok && other != tenant {
+				return nil, fmt.Errorf("A token is listed for both tenant %v and tenant %v", other, tenant)
+			}
+			keyStore.tenants[token] = tenant
+		}
+	}
+	return &keyStore, nil
+}
+
+// LoadKeyStore loads the key store configured in the environment: either the
+// file in BF_PLANET_KEYS_FILE, decrypted with BF_PLANET_KEYS_SECRET, or the
+// JSON in BF_PLANET_KEYS. With neither, the key store is empty.
+func LoadKeyStore() (*KeyStore, error) {
+	if path := util.GetPlanetKeysFile(); path != "" {
+		ciphertext, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		secret, err := KeyStoreSecret()
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := DecryptKeyStore(ciphertext, secret)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeyStore(plaintext)
+	}
+	if data := util.GetPlanetKeys(); data != "" {
+		return NewKeyStore([]byte(data))
+	}
+	return &KeyStore{tenants: map[string]string{}, keys: map[string]string{}}, nil
+}
+
+// KeyStoreSecret returns the key store secret from BF_PLANET_KEYS_SECRET
+func KeyStoreSecret() ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(util.GetPlanetKeysSecret())
+	if err != nil || len(secret) != 32 {
+		return nil, errors.New("The key store secret must be 32 bytes, base64 encoded")
+	}
+	return secret, nil
+}
+
+// EncryptKeyStore encrypts a key store with AES-256-GCM, returning the
+// base64 of the nonce followed by the ciphertext
+func EncryptKeyStore(plaintext []byte, secret []byte) ([]byte, error) {
+	gcm, err := keyStoreCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// DecryptKeyStore reverses EncryptKeyStore
+func DecryptKeyStore(ciphertext []byte, secret []byte) ([]byte, error) {
+	gcm, err := keyStoreCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(ciphertext)))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode the key store: %v", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("The key store is too short to decrypt")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("Failed to decrypt the key store; check the key store secret")
+	}
+	return plaintext, nil
+}
+
+func keyStoreCipher(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PlanetKey returns the tenant a broker token belongs to and its Planet key
+func (ks *KeyStore) PlanetKey(token string) (string, string, bool) {
+	hash := sha256.Sum256([]byte(token))
+	tenant, ok := ks.tenants[hex.EncodeToString(hash[:])]
+	if !ok {
+		return "", "", false
+	}
+	return tenant, ks.keys[tenant], true
+}
+
+var (
+	keyStoreMutex sync.RWMutex
+	keyStore      = &KeyStore{tenants: map[string]string{}, keys: map[string]string{}}
+)
+
+// SetKeyStore sets the key store handlers use to resolve broker tokens
+func SetKeyStore(newKeyStore *KeyStore) {
+	keyStoreMutex.Lock()
+	defer keyStoreMutex.Unlock()
+	keyStore = newKeyStore
+}
+
+func currentKeyStore() *KeyStore {
+	keyStoreMutex.RLock()
+	defer keyStoreMutex.RUnlock()
+	return keyStore
+}
+
+// planetKeyFromRequest finds the Planet key for a request: from a broker token
+// or a Planet key in the Authorization header, or else from the deprecated
+// PL_API_KEY parameter
+func planetKeyFromRequest(request *http.Request) (string, error) {
+	authorization := strings.TrimSpace(request.Header.Get("Authorization"))
+	if authorization == "" {
+		return request.FormValue("PL_API_KEY"), nil
+	}
+
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return "", util.HTTPErr{Status: http.StatusBadRequest, Message: invalidAuthorization}
+	}
+	credentials := strings.TrimSpace(parts[1])
+	switch strings.ToLower(parts[0]) {
+	case "bearer":
+		if _, planetKey, ok := currentKeyStore().PlanetKey(credentials); ok {
+			return planetKey, nil
+		}
+		return "", util.HTTPErr{Status: http.StatusUnauthorized, Message: unknownBrokerToken}
+	case "api-key":
+		return credentials, nil
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return "", util.HTTPErr{Status: http.StatusBadRequest, Message: invalidAuthorization}
+		}
+		return strings.SplitN(string(decoded), ":", 2)[0], nil
+	default:
+		return "", util.HTTPErr{Status: http.StatusBadRequest, Message: invalidAuthorization}
+	}
+}
+
+// planetKeyMissing sets the context's Planet key from the request; if there
+// is none, it writes the error response and returns true
+func planetKeyMissing(writer http.ResponseWriter, request *http.Request, context *Context) bool {
+	planetKey, err := planetKeyFromRequest(request)
+	if err != nil {
+		herr := err.(util.HTTPErr)
+		util.LogAlert(context, herr.Message)
+		util.HTTPErrResponse(request, writer, context, herr)
+		return true
+	}
+	if planetKey == "" {
+		util.LogAlert(context, noPlanetKey)
+		util.HTTPError(request, writer, context, noPlanetKey, http.StatusBadRequest)
+		return true
+	}
+	context.PlanetKey = planetKey
+	return false
+}
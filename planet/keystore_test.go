@@ -0,0 +1,130 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+const testingBrokerToken = "BROKER_TOKEN"
+
+func testingKeyStoreJSON() string {
+	hash := sha256.Sum256([]byte(testingBrokerToken))
+	return fmt.Sprintf(`{"tenants":{"beachfront":{"planetKey":"%v","tokens":["%v"]}}}`, testingValidKey, hex.EncodeToString(hash[:]))
+}
+
+func TestNewKeyStore(t *testing.T) {
+	keyStore, err := NewKeyStore([]byte(testingKeyStoreJSON()))
+	assert.Nil(t, err)
+
+	tenant, planetKey, ok := keyStore.PlanetKey(testingBrokerToken)
+	assert.True(t, ok)
+	assert.Equal(t, "beachfront", tenant)
+	assert.Equal(t, testingValidKey, planetKey)
+
+	_, _, ok = keyStore.PlanetKey("SOME_OTHER_TOKEN")
+	assert.False(t, ok)
+
+	_, err = NewKeyStore([]byte(`{"tenants":{"beachfront":{"tokens":["abc"]}}}`))
+	assert.NotNil(t, err)
+	_, err = NewKeyStore([]byte(`{"tenants":{"a":{"planetKey":"1","tokens":["abc"]},"b":{"planetKey":"2","tokens":["abc"]}}}`))
+	assert.NotNil(t, err)
+}
+
+func TestEncryptKeyStore(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	ciphertext, err := EncryptKeyStore([]byte(testingKeyStoreJSON()), secret)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(ciphertext), testingValidKey)
+
+	plaintext, err := DecryptKeyStore(ciphertext, secret)
+	assert.Nil(t, err)
+	assert.Equal(t, testingKeyStoreJSON(), string(plaintext))
+
+	_, err = DecryptKeyStore(ciphertext, []byte("fedcba9876543210fedcba9876543210"))
+	assert.NotNil(t, err)
+}
+
+func TestLoadKeyStoreFromFile(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	ciphertext, _ := EncryptKeyStore([]byte(testingKeyStoreJSON()), secret)
+	file, err := ioutil.TempFile("", "keystore")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.Write(ciphertext)
+	file.Close()
+
+	os.Setenv(util.BF_PLANET_KEYS_FILE, file.Name())
+	os.Setenv(util.BF_PLANET_KEYS_SECRET, base64.StdEncoding.EncodeToString(secret))
+	defer os.Unsetenv(util.BF_PLANET_KEYS_FILE)
+	defer os.Unsetenv(util.BF_PLANET_KEYS_SECRET)
+
+	keyStore, err := LoadKeyStore()
+	assert.Nil(t, err)
+	_, planetKey, ok := keyStore.PlanetKey(testingBrokerToken)
+	assert.True(t, ok)
+	assert.Equal(t, testingValidKey, planetKey)
+
+	os.Setenv(util.BF_PLANET_KEYS_SECRET, "not a secret")
+	_, err = LoadKeyStore()
+	assert.NotNil(t, err)
+}
+
+func TestPlanetKeyFromRequest(t *testing.T) {
+	keyStore, _ := NewKeyStore([]byte(testingKeyStoreJSON()))
+	SetKeyStore(keyStore)
+	defer SetKeyStore(&KeyStore{})
+
+	cases := []struct {
+		authorization string
+		query         string
+		planetKey     string
+		status        int
+	}{
+		{authorization: "Bearer " + testingBrokerToken, planetKey: testingValidKey},
+		{authorization: "api-key " + testingValidKey, planetKey: testingValidKey},
+		{authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte(testingValidKey+":")), planetKey: testingValidKey},
+		{query: "?PL_API_KEY=" + testingValidKey, planetKey: testingValidKey},
+		{authorization: "Bearer SOME_OTHER_TOKEN", status: http.StatusUnauthorized},
+		{authorization: "Digest something", status: http.StatusBadRequest},
+		{authorization: "Bearer", status: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "/planet/discover/rapideye"+c.query, nil)
+		if c.authorization != "" {
+			request.Header.Set("Authorization", c.authorization)
+		}
+		planetKey, err := planetKeyFromRequest(request)
+		if c.status == 0 {
+			assert.Nil(t, err, c.authorization)
+			assert.Equal(t, c.planetKey, planetKey, c.authorization)
+		} else {
+			herr, ok := err.(util.HTTPErr)
+			assert.True(t, ok, c.authorization)
+			assert.Equal(t, c.status, herr.Status, c.authorization)
+		}
+	}
+}
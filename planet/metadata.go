@@ -10,6 +10,16 @@ import (
 )
 
 func GetItemWithAssetMetadata(context *Context, options MetadataOptions) (*geojson.Feature, error) {
+	result, err := GetItemResult(context, options)
+	if err != nil {
+		return nil, err
+	}
+	return result.GeoJSONFeature()
+}
+
+// GetItemResult returns a scene with the metadata of its assets or bands, as
+// a result that can be written as GeoJSON or as STAC
+func GetItemResult(context *Context, options MetadataOptions) (model.GeoJSONFeatureSTACItemCreator, error) {
 	var (
 		err           error
 		searchResult  *model.BrokerSearchResult
@@ -21,19 +31,17 @@ func GetItemWithAssetMetadata(context *Context, options MetadataOptions) (*geojs
 
 	basicResult := searchResult.BasicBrokerResult
 	tidesData := searchResult.TidesData
-	if options.ImagerySource == sentinelFromS3 {
-		// Sentinel-2 in S3 uses JPEG2000 imagery
-		basicResult.FileFormat = model.JPEG2000
+	itemType, ok := itemTypeForImagerySource(options.ImagerySource)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized imagery source (%v), type: %s", options.ImagerySource, options.ItemType)
 	}
 	// TODO: check if Sentinel-2 in Planet returns as GeoTIFF or JPEG2000
-	if err != nil {
-		return nil, err
-	}
+	basicResult.FileFormat = itemType.FileFormat
 
-	var result model.GeoJSONFeatureCreator
+	var result model.GeoJSONFeatureSTACItemCreator
 
-	switch options.ImagerySource {
-	case rapidEye, planetScope, sentinelFromPlanet:
+	switch itemType.Bands {
+	case PlanetAssetBands:
 		// These are sources with activateable imagery hosted by Planet itself
 		if assetMetadata, err = GetPlanetAssets(options, context); err != nil {
 			return nil, err
@@ -44,7 +52,7 @@ func GetItemWithAssetMetadata(context *Context, options MetadataOptions) (*geojs
 			TidesData:           tidesData,
 		}
 
-	case landsatFromS3:
+	case LandsatS3Bands:
 		// Landsat imagery is hosted on an external S3 archive
 		folderURL, prefix, err := landsat.GetSceneFolderURL(basicResult.ID, basicResult.DataType)
 		if err != nil {
@@ -60,7 +68,7 @@ func GetItemWithAssetMetadata(context *Context, options MetadataOptions) (*geojs
 			TidesData:         tidesData,
 		}
 
-	case sentinelFromS3:
+	case SentinelS3Bands:
 		// Sentinel-2 imagery is hosted on an external S3 archive
 		sentinelBands, err := model.NewSentinelS3Bands(util.GetSentinelHost(), basicResult.ID)
 		if err != nil {
@@ -73,7 +81,7 @@ func GetItemWithAssetMetadata(context *Context, options MetadataOptions) (*geojs
 		}
 
 	default:
-		return nil, fmt.Errorf("Unrecognized imagery source (%v), type: %s", options.ImagerySource, options.ItemType)
+		return nil, fmt.Errorf("Unrecognized band source (%v), type: %s", itemType.Bands, options.ItemType)
 	}
-	return result.GeoJSONFeature()
+	return result, nil
 }
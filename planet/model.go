@@ -1,14 +1,23 @@
 package planet
 
 import (
+	"encoding/json"
 	"log"
+	"net/http"
 
+	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/bf-ia-broker/util"
 	"github.com/venicegeo/geojson-go/geojson"
 )
 
 var disablePermissionsCheck bool
 
+// maxPageSize is both the default and the largest page size Planet allows
+const maxPageSize = 250
+
+// maxSearchLimit caps how many results a single discover request may collect
+const maxSearchLimit = 2500
+
 func init() {
 	disablePermissionsCheck, _ = util.IsPlanetPermissionsDisabled()
 	if disablePermissionsCheck {
@@ -20,6 +29,7 @@ func init() {
 type Context struct {
 	BasePlanetURL string
 	BaseTidesURL  string
+	BaseTilesURL  string
 	PlanetKey     string
 	sessionID     string
 }
@@ -44,15 +54,42 @@ func (c *Context) LogRootDir() string {
 
 // SearchOptions are the search options for a quick-search request
 type SearchOptions struct {
-	ItemType        string
+	ItemTypes       []string
 	Tides           bool
 	AcquiredDate    string
 	MaxAcquiredDate string
 	Bbox            geojson.BoundingBox
+	Geometry        model.AOI
 	CloudCover      float64
+	PageSize        int
+	Limit           int
+	Cursor          string
+	// Sort orders the results; Planet sorts by acquired date itself, while
+	// other sorts apply to the results collected. Nil keeps Planet's order.
+	Sort *model.SearchSort
+
+	// These filters are ignored while nil or empty; usable data is a
+	// fraction (0-1), like cloud cover
+	MinSunElevation   *float64
+	MaxSunElevation   *float64
+	MinViewAngle      *float64
+	MaxViewAngle      *float64
+	MinGSD            *float64
+	MaxGSD            *float64
+	MinUsableData     *float64
+	QualityCategories []string
+	Instruments       []string
+	GroundControl     *bool
+
+	// Permissions is how scenes the key may not download are treated:
+	// filter, annotate or off (the default)
+	Permissions string
+	// AssetType is the asset whose download permission is checked
+	AssetType string
 }
 
 type searchResults struct {
+	Links    Links     `json:"_links"`
 	Features []feature `json:"features"`
 }
 
@@ -64,6 +101,7 @@ type feature struct {
 type request struct {
 	ItemTypes []string `json:"item_types"`
 	Filter    filter   `json:"filter"`
+	Interval  string   `json:"interval,omitempty"` // stats requests only
 }
 
 type filter struct {
@@ -85,20 +123,20 @@ type dateConfig struct {
 }
 
 type rangeConfig struct {
-	GTE float64 `json:"gte,omitempty"`
-	LTE float64 `json:"lte,omitempty"`
-	GT  float64 `json:"gt,omitempty"`
-	LT  float64 `json:"lt,omitempty"`
+	GTE *float64 `json:"gte,omitempty"`
+	LTE *float64 `json:"lte,omitempty"`
+	GT  *float64 `json:"gt,omitempty"`
+	LT  *float64 `json:"lt,omitempty"`
 }
 
-// Assets represents the assets available for a scene
-type Assets struct {
-	Analytic    Asset `json:"analytic"`
-	AnalyticXML Asset `json:"analytic_xml"`
-	UDM         Asset `json:"udm"`
-	Visual      Asset `json:"visual"`
-	VisualXML   Asset `json:"visual_xml"`
-}
+// defaultAssetType is the asset used when a request does not name one
+const defaultAssetType = "analytic"
+
+// assetTypes are the asset types that may be selected for metadata and activation
+var assetTypes = []string{"analytic", "analytic_sr", "basic_analytic", "udm", "udm2", "visual"}
+
+// Assets represents the assets available for a scene, by asset type
+type Assets map[string]Asset
 
 // Asset represents a single asset available for a scene
 type Asset struct {
@@ -115,6 +153,7 @@ type Links struct {
 	Self     string `json:"_self"`
 	Activate string `json:"activate"`
 	Type     string `json:"type"`
+	Next     string `json:"_next"`
 }
 
 type planetRequestInput struct {
@@ -122,6 +161,12 @@ type planetRequestInput struct {
 	inputURL    string // URL may be relative or absolute based on baseURLString
 	body        []byte
 	contentType string
+	idempotent  bool // may be retried even though the method is not GET or HEAD
+	header      http.Header
+}
+
+func (input planetRequestInput) isIdempotent() bool {
+	return input.idempotent || input.method == "GET" || input.method == "HEAD"
 }
 
 // MetadataOptions are the options for the Asset func
@@ -130,6 +175,101 @@ type MetadataOptions struct {
 	Tides         bool
 	ItemType      string
 	ImagerySource ImagerySource
+	// AssetType selects the asset described and activated; empty means analytic
+	AssetType string
+	// NoCache skips cached item and asset lookups, though fresh ones are
+	// still cached
+	NoCache bool
+}
+
+func (o MetadataOptions) assetType() string {
+	if o.AssetType == "" {
+		return defaultAssetType
+	}
+	return o.AssetType
+}
+
+// OrderOptions are the options for placing an order with the Orders API
+type OrderOptions struct {
+	Name      string
+	ItemType  string
+	ItemIDs   []string
+	Bundle    string
+	Clip      model.AOI
+	Reproject *ReprojectOptions
+}
+
+// ReprojectOptions configures Planet's reproject tool for an order
+type ReprojectOptions struct {
+	Projection string  `json:"projection"`
+	Resolution float64 `json:"resolution,omitempty"`
+	Kernel     string  `json:"kernel,omitempty"`
+}
+
+// Order is the state of a Planet order, including its delivery locations
+// once it has been fulfilled
+type Order struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	State        string          `json:"state"`
+	CreatedOn    string          `json:"createdOn,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	ErrorHints   []string        `json:"errorHints,omitempty"`
+	Locations    []OrderLocation `json:"locations"`
+}
+
+// OrderLocation is a single file delivered by an order
+type OrderLocation struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// orderRequestBody is what clients POST to /planet/order
+type orderRequestBody struct {
+	Name      string            `json:"name"`
+	ItemType  string            `json:"itemType"`
+	ItemIDs   []string          `json:"itemIds"`
+	Bundle    string            `json:"bundle"`
+	Clip      json.RawMessage   `json:"clip"`
+	Reproject *ReprojectOptions `json:"reproject"`
+}
+
+type orderRequest struct {
+	Name     string                   `json:"name"`
+	Products []orderProduct           `json:"products"`
+	Tools    []map[string]interface{} `json:"tools,omitempty"`
+}
+
+type orderProduct struct {
+	ItemIDs       []string `json:"item_ids"`
+	ItemType      string   `json:"item_type"`
+	ProductBundle string   `json:"product_bundle"`
+}
+
+type orderClipConfig struct {
+	AOI model.AOI `json:"aoi"`
+}
+
+type orderResponse struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	State        string     `json:"state"`
+	CreatedOn    string     `json:"created_on"`
+	LastModified string     `json:"last_modified"`
+	ErrorHints   []string   `json:"error_hints"`
+	Links        orderLinks `json:"_links"`
+}
+
+type orderLinks struct {
+	Self    string        `json:"_self"`
+	Results []orderResult `json:"results"`
+}
+
+type orderResult struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	ExpiresAt string `json:"expires_at"`
 }
 
 // ImagerySource is an enum of all possible Planet imagery sources
@@ -143,16 +283,14 @@ const (
 	landsatFromS3
 	sentinelFromPlanet
 	sentinelFromS3
+	planetScopeScene
 )
 
 func imagerySourceRequiresActivation(imagerySource ImagerySource) bool {
-	switch imagerySource {
-	case rapidEye, planetScope, sentinelFromPlanet:
-		return true
-	case landsatFromS3, sentinelFromS3:
-		return false
-	default:
+	itemType, ok := itemTypeForImagerySource(imagerySource)
+	if !ok {
 		log.Print("Unrecognized imagery source, assuming activation needed")
 		return true
 	}
+	return itemType.RequiresActivation
 }
@@ -0,0 +1,97 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// OpenSearchHandler is a handler for /planet/opensearch
+// @Title planetOpenSearchHandler
+// @Description Describes Planet Labs discovery as OpenSearch, with the Geo, Time and EO extensions
+// @Accept  plain
+// @Param   itemType        path    string  true         "Planet Labs Item Type, e.g., rapideye or planetscope"
+// @Success 200 {object}  string
+// @Failure 400 {object}  string
+// @Router /planet/opensearch/{itemType} [get]
+type OpenSearchHandler struct {
+	Context Context
+}
+
+// NewOpenSearchHandler creates a new handler
+func NewOpenSearchHandler() OpenSearchHandler {
+	return OpenSearchHandler{}
+}
+
+// ServeHTTP implements the http.Handler interface for the OpenSearchHandler type
+func (h OpenSearchHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method, Actee: request.URL.String(), Message: "Receiving /planet/opensearch request", Severity: util.INFO})
+
+	if util.Preflight(writer, request, &h.Context) {
+		return
+	}
+
+	itemType := mux.Vars(request)["itemType"]
+	if _, err := discoverItemType(itemType); err != nil {
+		util.LogSimpleErr(&h.Context, err.Error(), nil)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	baseURL := util.BaseURL(request)
+	description := model.NewOpenSearchDescription(
+		"Planet Labs "+itemType,
+		fmt.Sprintf("Planet Labs %v scenes, through the Beachfront imagery broker", itemType),
+		baseURL+"/planet/discover/"+itemType,
+		baseURL+util.LinkURI(*request.URL),
+		false,
+	)
+	bytes, err := description.Write()
+	if err != nil {
+		err = util.LogSimpleErr(&h.Context, "Failed to write the OpenSearch description. ", err)
+		util.HTTPError(request, writer, &h.Context, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", model.OpenSearchDescriptionContentType)
+	writer.Write(bytes)
+
+	util.LogAudit(&h.Context, util.LogAuditInput{Actor: "anon user", Action: request.Method + " response", Actee: request.URL.String(), Message: "Sending /planet/opensearch response", Severity: util.INFO})
+}
+
+// writeAtomFeed writes search results as an Atom feed, linking to the next
+// page if there is one. Entries link to their metadata when the search was
+// for a single item type.
+func writeAtomFeed(result *model.MultiBrokerResult, nextURL string, request *http.Request) ([]byte, error) {
+	baseURL := util.BaseURL(request)
+	var entryURL func(string) string
+	if itemType := mux.Vars(request)["itemType"]; itemType != "" {
+		entryURL = func(id string) string { return baseURL + "/planet/" + itemType + "/" + id }
+	}
+	entries, err := result.AtomEntries(entryURL)
+	if err != nil {
+		return nil, err
+	}
+	feed := model.NewAtomFeed("Planet Labs scenes", baseURL+util.LinkURI(*request.URL))
+	feed.SetEntries(entries)
+	if nextURL != "" {
+		feed.Links = append(feed.Links, model.AtomLink{Href: baseURL + nextURL, Rel: "next", Type: model.AtomContentType})
+	}
+	return feed.Write()
+}
@@ -0,0 +1,149 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// ordersURL is the Orders API v2 endpoint, relative to the Planet base URL
+const ordersURL = "compute/ops/orders/v2"
+
+// defaultOrderBundle is the product bundle ordered when none is specified;
+// it matches the analytic asset that Activate uses
+const defaultOrderBundle = "analytic"
+
+// defaultOrderName names orders placed without a name
+const defaultOrderName = "bf-ia-broker order"
+
+// CreateOrder places an order for a set of items with Planet's Orders API,
+// clipping and reprojecting the deliveries if requested
+func CreateOrder(options OrderOptions, context *Context) (*Order, error) {
+	var (
+		requestBody []byte
+		err         error
+	)
+	if options.ItemType == "" || len(options.ItemIDs) == 0 {
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: "An order requires an item type and at least one item ID."}
+	}
+
+	req := orderRequest{
+		Name: options.Name,
+		Products: []orderProduct{{
+			ItemIDs:       options.ItemIDs,
+			ItemType:      options.ItemType,
+			ProductBundle: options.Bundle,
+		}},
+	}
+	if req.Name == "" {
+		req.Name = defaultOrderName
+	}
+	if req.Products[0].ProductBundle == "" {
+		req.Products[0].ProductBundle = defaultOrderBundle
+	}
+	if options.Clip != nil {
+		req.Tools = append(req.Tools, map[string]interface{}{"clip": orderClipConfig{AOI: options.Clip}})
+	}
+	if options.Reproject != nil {
+		req.Tools = append(req.Tools, map[string]interface{}{"reproject": options.Reproject})
+	}
+
+	if requestBody, err = json.Marshal(req); err != nil {
+		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to marshal order request object %#v.", req), err)
+		return nil, err
+	}
+	input := planetRequestInput{method: "POST", inputURL: ordersURL, body: requestBody, contentType: "application/json"}
+	return doOrderRequest(input, "place an order", context)
+}
+
+// GetOrder polls the state of an order; its delivery locations are
+// populated once Planet has fulfilled it
+func GetOrder(id string, context *Context) (*Order, error) {
+	if id == "" {
+		return nil, util.HTTPErr{Status: http.StatusBadRequest, Message: "This operation requires a Planet Labs order ID."}
+	}
+	input := planetRequestInput{method: "GET", inputURL: ordersURL + "/" + url.PathEscape(id)}
+	return doOrderRequest(input, "retrieve order "+id, context)
+}
+
+// doOrderRequest sends a request to the Orders API and translates the
+// order it returns
+func doOrderRequest(input planetRequestInput, description string, context *Context) (*Order, error) {
+	var (
+		response    *http.Response
+		err         error
+		body        []byte
+		planetOrder orderResponse
+	)
+	if response, err = planetRequest(input, context); err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, _ = ioutil.ReadAll(response.Body)
+	switch {
+	case (response.StatusCode == http.StatusUnauthorized) || (response.StatusCode == http.StatusForbidden):
+		message := fmt.Sprintf("Specified API key is invalid or has inadequate permissions. (%v) ", response.Status)
+		err := util.HTTPErr{Status: response.StatusCode, Message: message}
+		util.LogAlert(context, message)
+		return nil, err
+	case (response.StatusCode >= 400) && (response.StatusCode < 500):
+		message := fmt.Sprintf("Failed to %v: %v. %v", description, response.Status, string(body))
+		err := util.HTTPErr{Status: response.StatusCode, Message: message}
+		util.LogAlert(context, message)
+		return nil, err
+	case response.StatusCode >= 500:
+		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to %v. ", description), errors.New(response.Status))
+		return nil, err
+	default:
+		//no op
+	}
+	if err = json.Unmarshal(body, &planetOrder); err != nil {
+		plErr := util.Error{LogMsg: "Failed to Unmarshal response from Planet Orders API request: " + err.Error(),
+			SimpleMsg:  "Planet Labs returned an unexpected response for this request. See log for further details.",
+			Response:   string(body),
+			URL:        input.inputURL,
+			HTTPStatus: response.StatusCode}
+		err = plErr.Log(context, "")
+		return nil, err
+	}
+	return orderFromPlanetOrder(planetOrder), nil
+}
+
+func orderFromPlanetOrder(planetOrder orderResponse) *Order {
+	order := Order{
+		ID:           planetOrder.ID,
+		Name:         planetOrder.Name,
+		State:        planetOrder.State,
+		CreatedOn:    planetOrder.CreatedOn,
+		LastModified: planetOrder.LastModified,
+		ErrorHints:   planetOrder.ErrorHints,
+		Locations:    make([]OrderLocation, 0, len(planetOrder.Links.Results)),
+	}
+	for _, result := range planetOrder.Links.Results {
+		order.Locations = append(order.Locations, OrderLocation{
+			Name:      result.Name,
+			Location:  result.Location,
+			ExpiresAt: result.ExpiresAt,
+		})
+	}
+	return &order
+}
@@ -0,0 +1,119 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+func TestCreateOrder(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	options := OrderOptions{ItemType: testingValidItemType, ItemIDs: []string{testingValidItemID}}
+	order, err := CreateOrder(options, &context)
+	assert.Nil(t, err, "Expected order to succeed; received: %v", err)
+	assert.Equal(t, testingValidOrderID, order.ID)
+	assert.Equal(t, "queued", order.State)
+	assert.Empty(t, order.Locations)
+
+	body := string(testingLastOrderBody)
+	assert.Contains(t, body, `"products":[{"item_ids":["foobar123"],"item_type":"REOrthoTile","product_bundle":"analytic"}]`)
+	assert.Contains(t, body, `"name":"bf-ia-broker order"`)
+	assert.NotContains(t, body, `"tools"`)
+}
+
+func TestCreateOrderTools(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	aoi, err := model.NewAOI([]byte(testingAOIGeometry))
+	assert.Nil(t, err)
+	options := OrderOptions{
+		Name:      "clipped",
+		ItemType:  testingValidItemType,
+		ItemIDs:   []string{testingValidItemID},
+		Bundle:    "analytic_sr",
+		Clip:      aoi,
+		Reproject: &ReprojectOptions{Projection: "EPSG:4326", Kernel: "cubic"},
+	}
+	_, err = CreateOrder(options, &context)
+	assert.Nil(t, err, "Expected order to succeed; received: %v", err)
+
+	body := string(testingLastOrderBody)
+	assert.Contains(t, body, `"name":"clipped"`)
+	assert.Contains(t, body, `"product_bundle":"analytic_sr"`)
+	assert.Contains(t, body, `"tools":[{"clip":{"aoi":{"type":"Polygon","coordinates":[[[-1,0],[1,0],[1,20],[-1,20],[-1,0]]]}}},{"reproject":{"projection":"EPSG:4326","kernel":"cubic"}}]`)
+}
+
+func TestCreateOrderRejected(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	_, err := CreateOrder(OrderOptions{ItemType: testingValidItemType, ItemIDs: []string{"not-mine"}}, &context)
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr; received: %v", err)
+	assert.Equal(t, http.StatusBadRequest, herr.Status)
+	assert.Contains(t, herr.Message, "Unable to accept order")
+}
+
+func TestCreateOrderNoItems(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	_, err := CreateOrder(OrderOptions{ItemType: testingValidItemType}, &context)
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr; received: %v", err)
+	assert.Equal(t, http.StatusBadRequest, herr.Status)
+}
+
+func TestCreateOrderBadKey(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+	context.PlanetKey = testingInvalidKey
+
+	_, err := CreateOrder(OrderOptions{ItemType: testingValidItemType, ItemIDs: []string{testingValidItemID}}, &context)
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr; received: %v", err)
+	assert.Equal(t, http.StatusUnauthorized, herr.Status)
+}
+
+func TestGetOrder(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	order, err := GetOrder(testingValidOrderID, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.Equal(t, "success", order.State)
+	assert.Len(t, order.Locations, 2)
+	assert.Equal(t, planetServer.URL+"/compute/ops/download/?token=abc123", order.Locations[0].Location)
+	assert.Equal(t, "2018-03-03T14:12:40.092Z", order.Locations[0].ExpiresAt)
+	assert.Contains(t, order.Locations[0].Name, "_clip.tif")
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	_, err := GetOrder("no-such-order", &context)
+	herr, ok := err.(util.HTTPErr)
+	assert.True(t, ok, "Expected an HTTPErr; received: %v", err)
+	assert.Equal(t, http.StatusNotFound, herr.Status)
+}
@@ -0,0 +1,88 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/venicegeo/bf-ia-broker/model"
+)
+
+// How discovery treats scenes whose assets the API key may not download
+const (
+	// permissionsFilter drops them from the results
+	permissionsFilter = "filter"
+	// permissionsAnnotate marks every result with whether it is downloadable
+	permissionsAnnotate = "annotate"
+	// permissionsOff ignores permissions
+	permissionsOff = "off"
+)
+
+const invalidPermissions = "The permissions value of %v is invalid; it must be filter, annotate or off."
+
+// parsePermissions reads how a discover request treats permissions. Without a
+// permissions parameter, results are annotated unless PL_DISABLE_PERMISSIONS_CHECK
+// is set.
+func parsePermissions(request *http.Request) (string, error) {
+	switch permissions := request.FormValue("permissions"); permissions {
+	case "":
+		if disablePermissionsCheck {
+			return permissionsOff, nil
+		}
+		return permissionsAnnotate, nil
+	case permissionsFilter, permissionsAnnotate, permissionsOff:
+		return permissions, nil
+	default:
+		return "", fmt.Errorf(invalidPermissions, permissions)
+	}
+}
+
+// isDownloadable checks a search result's _permissions for the download
+// permission of an asset, e.g. assets.analytic:download. Item types whose
+// imagery is hosted elsewhere are always downloadable.
+func isDownloadable(result model.BrokerSearchResult, permissions []string, assetType string) bool {
+	if itemType, ok := lookupItemType(result.ItemType); ok && itemType.Bands != PlanetAssetBands {
+		return true
+	}
+	return containsString(permissions, "assets."+assetType+":download")
+}
+
+// applyPermissions annotates a page of search results with whether each is
+// downloadable and, when filtering, drops those that are not
+func applyPermissions(results []model.BrokerSearchResult, permissions [][]string, options SearchOptions) []model.BrokerSearchResult {
+	if options.Permissions == "" || options.Permissions == permissionsOff {
+		return results
+	}
+	assetType := options.AssetType
+	if assetType == "" {
+		assetType = defaultAssetType
+	}
+
+	applied := make([]model.BrokerSearchResult, 0, len(results))
+	for i, result := range results {
+		var resultPermissions []string
+		if i < len(permissions) {
+			resultPermissions = permissions[i]
+		}
+		downloadable := isDownloadable(result, resultPermissions, assetType)
+		if !downloadable && options.Permissions == permissionsFilter {
+			continue
+		}
+		result.Downloadable = &downloadable
+		applied = append(applied, result)
+	}
+	return applied
+}
@@ -21,10 +21,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/bf-ia-broker/tides"
@@ -34,76 +35,288 @@ import (
 
 var addTidesToSearchResults = tides.AddTidesToSearchResults
 
-// GetScenes returns a FeatureCollection containing the scenes requested
-func GetScenes(options SearchOptions, context *Context) (*geojson.FeatureCollection, error) {
+// GetScenes returns a FeatureCollection containing the scenes requested, and an
+// opaque cursor for the next page of results if Planet has more to offer.
+func GetScenes(options SearchOptions, context *Context) (*geojson.FeatureCollection, string, error) {
+	result, nextCursor, err := SearchScenes(options, context)
+	if err != nil {
+		return nil, "", err
+	}
+	fc, err := result.GeoJSONFeatureCollection()
+	if err != nil {
+		return nil, "", err
+	}
+	return fc, nextCursor, nil
+}
+
+// SearchScenes returns the scenes requested, and an opaque cursor for the next
+// page of results if Planet has more to offer. Planet's pages are followed
+// until the requested limit would be exceeded.
+func SearchScenes(options SearchOptions, context *Context) (*model.MultiBrokerResult, string, error) {
 	var (
-		err          error
-		response     *http.Response
-		requestBody  []byte
-		responseBody []byte
-		req          request
+		err         error
+		requestBody []byte
+		req         request
+		input       planetRequestInput
+		pageURL     string
+		nextCursor  string
 	)
 
-	req.ItemTypes = append(req.ItemTypes, options.ItemType)
-	req.Filter.Type = "AndFilter"
-	req.Filter.Config = make([]interface{}, 0)
-	if options.Bbox != nil {
-		req.Filter.Config = append(req.Filter.Config, objectFilter{Type: "GeometryFilter", FieldName: "geometry", Config: options.Bbox.Geometry()})
+	pageSize, limit := searchPageSizeAndLimit(options)
+
+	if options.Cursor != "" {
+		if !planetSorts(options.Sort) {
+			message := fmt.Sprintf(unpagedSort, options.Sort)
+			util.LogSimpleErr(context, message, nil)
+			return nil, "", util.HTTPErr{Status: http.StatusBadRequest, Message: message}
+		}
+		if pageURL, err = planetURLFromCursor(options.Cursor, pageSize); err != nil {
+			message := fmt.Sprintf("The cursor value of %v is invalid", options.Cursor)
+			util.LogSimpleErr(context, message, err)
+			return nil, "", util.HTTPErr{Status: http.StatusBadRequest, Message: message}
+		}
+		input = planetRequestInput{method: "GET", inputURL: pageURL}
+	} else {
+		req.ItemTypes = options.ItemTypes
+		req.Filter = searchFilter(options)
+		if requestBody, err = json.Marshal(req); err != nil {
+			err = util.LogSimpleErr(context, fmt.Sprintf("Failed to marshal request object %#v.", req), err)
+			return nil, "", err
+		}
+		inputURL := fmt.Sprintf("data/v1/quick-search?_page_size=%d", pageSize)
+		if options.Sort != nil && options.Sort.Field == model.SortByAcquiredDate {
+			inputURL += "&_sort=" + url.QueryEscape(planetSort(*options.Sort))
+		}
+		input = planetRequestInput{method: "POST", inputURL: inputURL, body: requestBody, contentType: "application/json", idempotent: true}
 	}
-	if options.AcquiredDate != "" || options.MaxAcquiredDate != "" {
-		dc := dateConfig{GTE: options.AcquiredDate, LTE: options.MaxAcquiredDate}
-		req.Filter.Config = append(req.Filter.Config, objectFilter{Type: "DateRangeFilter", FieldName: "acquired", Config: dc})
+
+	results := []model.BrokerSearchResult{}
+	for {
+		page, permissions, nextLink, err := getSearchPage(input, context)
+		if err != nil {
+			return nil, "", err
+		}
+		results = append(results, applyPermissions(page, permissions, options)...)
+
+		// A short page means Planet has nothing more, whatever its links say
+		if nextLink == "" || len(page) < pageSize {
+			nextCursor = ""
+			break
+		}
+		pageURL = relativePlanetURL(nextLink, context)
+		nextCursor = base64.RawURLEncoding.EncodeToString([]byte(pageURL))
+		if len(results)+pageSize > limit {
+			break
+		}
+		input = planetRequestInput{method: "GET", inputURL: pageURL}
 	}
-	if options.CloudCover > 0 {
-		cc := rangeConfig{LTE: options.CloudCover}
-		req.Filter.Config = append(req.Filter.Config, objectFilter{Type: "RangeFilter", FieldName: "cloud_cover", Config: cc})
+	// Later pages could hold scenes that sort before these, so a sort Planet
+	// cannot do itself is of this response alone
+	if !planetSorts(options.Sort) {
+		nextCursor = ""
 	}
-	if requestBody, err = json.Marshal(req); err != nil {
-		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to marshal request object %#v.", req), err)
-		return nil, err
+
+	if aoi := searchAOI(options); aoi != nil {
+		for i := range results {
+			coverage := model.AOICoverage(aoi, results[i].Geometry)
+			results[i].AOICoverage = &coverage
+		}
 	}
-	if response, err = planetRequest(planetRequestInput{method: "POST", inputURL: "data/v1/quick-search", body: requestBody, contentType: "application/json"}, context); err != nil {
-		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to complete Planet API request %#v.", string(requestBody)), err)
-		return nil, err
+
+	if options.Tides {
+		tidesContext := tides.Context{TidesURL: context.BaseTidesURL}
+		if err = addTidesToSearchResults(&tidesContext, results); err != nil {
+			return nil, "", err
+		}
 	}
+
+	if options.Sort != nil {
+		model.SortSearchResults(results, *options.Sort)
+	}
+
+	featureCreators := make([]model.GeoJSONFeatureCreator, len(results))
+	for i, result := range results {
+		featureCreators[i] = result
+	}
+
+	return &model.MultiBrokerResult{FeatureCreators: featureCreators}, nextCursor, nil
+}
+
+// unpagedSort refuses a cursor for a sort of a single response
+const unpagedSort = "The %v sort orders only the scenes of a single response, so it cannot be paged with a cursor"
+
+// planetSorts reports whether Planet sorts the search itself, so that the sort
+// holds across pages; Planet only sorts by acquired date
+func planetSorts(searchSort *model.SearchSort) bool {
+	return searchSort == nil || searchSort.Field == model.SortByAcquiredDate
+}
+
+// planetSort is the quick-search _sort value for a sort by acquired date
+func planetSort(searchSort model.SearchSort) string {
+	if searchSort.Descending {
+		return "acquired desc"
+	}
+	return "acquired asc"
+}
+
+// getSearchPage performs a single quick-search (or search results page) request
+// and returns its results, their permissions and the link to the following
+// page, if any
+func getSearchPage(input planetRequestInput, context *Context) ([]model.BrokerSearchResult, [][]string, string, error) {
+	var (
+		err          error
+		response     *http.Response
+		responseBody []byte
+	)
+	if response, err = planetRequest(input, context); err != nil {
+		logErr := util.LogSimpleErr(context, fmt.Sprintf("Failed to complete Planet API request %#v.", string(input.body)), err)
+		// Keep the status and retry guidance of a rate limited request
+		if _, ok := err.(util.HTTPErr); ok {
+			return nil, nil, "", err
+		}
+		return nil, nil, "", logErr
+	}
+	defer response.Body.Close()
 	switch {
 	case (response.StatusCode == http.StatusUnauthorized) || (response.StatusCode == http.StatusForbidden):
 		message := fmt.Sprintf("Specified API key is invalid or has inadequate permissions. (%v) ", response.Status)
 		err := util.HTTPErr{Status: response.StatusCode, Message: message}
 		util.LogAlert(context, message)
-		return nil, err
+		return nil, nil, "", err
 	case (response.StatusCode >= 400) && (response.StatusCode < 500):
 		message := fmt.Sprintf("Failed to discover scenes from Planet API: %v. ", response.Status)
 		err := util.HTTPErr{Status: response.StatusCode, Message: message}
 		util.LogAlert(context, message)
-		return nil, err
+		return nil, nil, "", err
 	case response.StatusCode >= 500:
 		err = util.LogSimpleErr(context, "Failed to discover scenes from Planet API.", errors.New(response.Status))
-		return nil, err
+		return nil, nil, "", err
 	default:
 		//no op
 	}
 
-	defer response.Body.Close()
 	responseBody, _ = ioutil.ReadAll(response.Body)
 
 	results, err := parseSearchResults(context, responseBody)
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
-	if options.Tides {
-		tidesContext := tides.Context{TidesURL: context.BaseTidesURL}
-		if err = addTidesToSearchResults(&tidesContext, results); err != nil {
-			return nil, err
+	return results, parseSearchPermissions(responseBody), parseNextLink(responseBody), nil
+}
+
+// searchFilter builds the AndFilter of every search option that was set
+func searchFilter(options SearchOptions) filter {
+	result := filter{Type: "AndFilter", Config: make([]interface{}, 0)}
+	if options.Geometry != nil {
+		result.Config = append(result.Config, objectFilter{Type: "GeometryFilter", FieldName: "geometry", Config: options.Geometry})
+	} else if options.Bbox != nil {
+		result.Config = append(result.Config, objectFilter{Type: "GeometryFilter", FieldName: "geometry", Config: options.Bbox.Geometry()})
+	}
+	if options.AcquiredDate != "" || options.MaxAcquiredDate != "" {
+		dc := dateConfig{GTE: options.AcquiredDate, LTE: options.MaxAcquiredDate}
+		result.Config = append(result.Config, objectFilter{Type: "DateRangeFilter", FieldName: "acquired", Config: dc})
+	}
+	if options.CloudCover > 0 {
+		cc := rangeConfig{LTE: &options.CloudCover}
+		result.Config = append(result.Config, objectFilter{Type: "RangeFilter", FieldName: "cloud_cover", Config: cc})
+	}
+
+	ranges := []struct {
+		fieldName string
+		min, max  *float64
+	}{
+		{"sun_elevation", options.MinSunElevation, options.MaxSunElevation},
+		{"view_angle", options.MinViewAngle, options.MaxViewAngle},
+		{"gsd", options.MinGSD, options.MaxGSD},
+		{"usable_data", options.MinUsableData, nil},
+	}
+	for _, r := range ranges {
+		if r.min != nil || r.max != nil {
+			result.Config = append(result.Config, objectFilter{Type: "RangeFilter", FieldName: r.fieldName, Config: rangeConfig{GTE: r.min, LTE: r.max}})
 		}
 	}
 
-	featureCreators := make([]model.GeoJSONFeatureCreator, len(results))
-	for i, result := range results {
-		featureCreators[i] = result
+	if len(options.QualityCategories) > 0 {
+		result.Config = append(result.Config, objectFilter{Type: "StringInFilter", FieldName: "quality_category", Config: options.QualityCategories})
+	}
+	if len(options.Instruments) > 0 {
+		result.Config = append(result.Config, objectFilter{Type: "StringInFilter", FieldName: "instrument", Config: options.Instruments})
+	}
+	if options.GroundControl != nil {
+		gc := []string{strconv.FormatBool(*options.GroundControl)}
+		result.Config = append(result.Config, objectFilter{Type: "StringInFilter", FieldName: "ground_control", Config: gc})
+	}
+	return result
+}
+
+// searchAOI returns the area of interest of a search, if it has one
+func searchAOI(options SearchOptions) model.AOI {
+	if options.Geometry != nil {
+		return options.Geometry
+	}
+	if options.Bbox != nil {
+		if aoi, err := model.NewAOIFromBoundingBox(options.Bbox); err == nil {
+			return aoi
+		}
+	}
+	return nil
+}
+
+// searchPageSizeAndLimit applies defaults to the paging options, making sure
+// that a single page never exceeds the overall limit
+func searchPageSizeAndLimit(options SearchOptions) (int, int) {
+	pageSize := options.PageSize
+	if pageSize <= 0 || pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = pageSize
+	}
+	if limit < pageSize {
+		pageSize = limit
+	}
+	return pageSize, limit
+}
+
+// relativePlanetURL strips the Planet base URL from a link Planet returned,
+// so that it can be handed back to clients without naming a host
+func relativePlanetURL(link string, context *Context) string {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	basePath := "/"
+	if baseURL, err := url.Parse(context.BasePlanetURL); err == nil && baseURL.Path != "" {
+		basePath = baseURL.Path
+	}
+	result := strings.TrimPrefix(strings.TrimPrefix(linkURL.Path, basePath), "/")
+	if linkURL.RawQuery != "" {
+		result += "?" + linkURL.RawQuery
 	}
+	return result
+}
 
-	return model.MultiBrokerResult{FeatureCreators: featureCreators}.GeoJSONFeatureCollection()
+// planetURLFromCursor decodes a cursor created by GetScenes back into a
+// relative Planet search results URL, with the requested page size
+func planetURLFromCursor(cursor string, pageSize int) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	pageURL, err := url.Parse(string(decoded))
+	if err != nil {
+		return "", err
+	}
+	// Only relative search result pages may be requested, so that a forged
+	// cursor cannot send the Planet API key elsewhere
+	if pageURL.IsAbs() || pageURL.Host != "" || !strings.HasPrefix(pageURL.Path, "data/v1/searches/") {
+		return "", errors.New("Cursor does not refer to a page of Planet search results")
+	}
+	query := pageURL.Query()
+	query.Set("_page_size", strconv.Itoa(pageSize))
+	pageURL.RawQuery = query.Encode()
+	return pageURL.String(), nil
 }
 
 // GetPlanetAssets returns the asset metadata related to a particular item
@@ -116,45 +329,50 @@ func GetPlanetAssets(options MetadataOptions, context *Context) (*model.PlanetAs
 	)
 	// Note: trailing `/` is needed here to avoid a redirect which causes a Go 1.7 redirect bug issue
 	inputURL := "data/v1/item-types/" + options.ItemType + "/items/" + options.ID + "/assets/"
-	if response, err = planetRequest(planetRequestInput{method: "GET", inputURL: inputURL}, context); err != nil {
+	status := http.StatusOK
+	cached := getCachedLookup(context, cachedAssets, options)
+	if cached != nil {
+		body = cached
+	} else if response, err = planetRequest(planetRequestInput{method: "GET", inputURL: inputURL}, context); err != nil {
 		return nil, err
+	} else {
+		status = response.StatusCode
+		defer response.Body.Close()
+		body, _ = ioutil.ReadAll(response.Body)
 	}
 	switch {
-	case (response.StatusCode == http.StatusUnauthorized) || (response.StatusCode == http.StatusForbidden):
+	case (status == http.StatusUnauthorized) || (status == http.StatusForbidden):
 		message := fmt.Sprintf("Specified API key is invalid or has inadequate permissions. (%v) ", response.Status)
 		err := util.HTTPErr{Status: response.StatusCode, Message: message}
 		util.LogAlert(context, message)
 		return nil, err
-	case (response.StatusCode >= 400) && (response.StatusCode < 500):
+	case (status >= 400) && (status < 500):
 		message := fmt.Sprintf("Failed to get asset information for scene %v: %v. ", options.ID, response.Status)
 		err := util.HTTPErr{Status: response.StatusCode, Message: message}
 		util.LogAlert(context, message)
 		return nil, err
-	case response.StatusCode >= 500:
+	case status >= 500:
 		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to get asset information for scene %v. ", options.ID), errors.New(response.Status))
 		return nil, err
 	default:
 		//no op
 	}
-	defer response.Body.Close()
-	body, _ = ioutil.ReadAll(response.Body)
 	if err = json.Unmarshal(body, &assets); err != nil {
 		plErr := util.Error{LogMsg: "Failed to Unmarshal response from Planet API data request: " + err.Error(),
 			SimpleMsg:  "Planet Labs returned an unexpected response for this request. See log for further details.",
 			Response:   string(body),
 			URL:        inputURL,
-			HTTPStatus: response.StatusCode}
+			HTTPStatus: status}
 		err = plErr.Log(context, "")
 		return nil, err
 	}
 
-	assetMetadata, err := planetAssetMetadataFromAssets(assets)
-
-	log.Print("XXXXXXXX")
-	log.Print(string(body))
-	log.Print(assets)
-	log.Print(assetMetadata)
-	log.Print(err)
+	assetMetadata, err := planetAssetMetadataFromAssets(assets, options.assetType())
+	if err == nil && assetMetadata == nil && len(assets) > 0 {
+		message := fmt.Sprintf("Asset type %v is not available for scene %v.", options.assetType(), options.ID)
+		util.LogAlert(context, message)
+		return nil, util.HTTPErr{Status: http.StatusNotFound, Message: message}
+	}
 
 	if err == nil && imagerySourceRequiresActivation(options.ImagerySource) {
 		if assetMetadata == nil {
@@ -175,11 +393,15 @@ func GetPlanetAssets(options MetadataOptions, context *Context) (*model.PlanetAs
 			SimpleMsg:  "Planet Labs returned invalid metadata for this scene's assets.",
 			Response:   string(body),
 			URL:        inputURL,
-			HTTPStatus: response.StatusCode}
+			HTTPStatus: status}
 		err = plErr.Log(context, "")
 		return assetMetadata, util.HTTPErr{Status: http.StatusBadGateway, Message: plErr.SimpleMsg}
 	}
 
+	// Assets still activating change soon, and are polled by the tracker
+	if cached == nil && assetMetadata != nil && assetMetadata.Status != activationStatusActivating {
+		putCachedLookup(context, cachedAssets, options, body, assetMetadata.ExpiresAt)
+	}
 	return assetMetadata, nil
 }
 
@@ -193,23 +415,29 @@ func GetPlanetItem(options MetadataOptions, context *Context) (*model.BrokerSear
 	)
 	inputURL := "data/v1/item-types/" + options.ItemType + "/items/" + options.ID
 	input := planetRequestInput{method: "GET", inputURL: inputURL}
-	if response, err = planetRequest(input, context); err != nil {
+	status := http.StatusOK
+	cached := getCachedLookup(context, cachedItem, options)
+	if cached != nil {
+		body = cached
+	} else if response, err = planetRequest(input, context); err != nil {
 		return nil, err
+	} else {
+		status = response.StatusCode
+		defer response.Body.Close()
+		body, _ = ioutil.ReadAll(response.Body)
 	}
-	defer response.Body.Close()
-	body, _ = ioutil.ReadAll(response.Body)
 	switch {
-	case (response.StatusCode == http.StatusUnauthorized) || (response.StatusCode == http.StatusForbidden):
+	case (status == http.StatusUnauthorized) || (status == http.StatusForbidden):
 		message := fmt.Sprintf("Specified API key is invalid or has inadequate permissions. (%v) ", response.Status)
 		err := util.HTTPErr{Status: response.StatusCode, Message: message}
 		util.LogAlert(context, message)
 		return nil, err
-	case (response.StatusCode >= 400) && (response.StatusCode < 500):
+	case (status >= 400) && (status < 500):
 		message := fmt.Sprintf("Failed to find metadata for scene %v: %v. ", options.ID, response.Status)
 		err := util.HTTPErr{Status: response.StatusCode, Message: message}
 		util.LogAlert(context, message)
 		return nil, err
-	case response.StatusCode >= 500:
+	case status >= 500:
 		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to retrieve metadata for scene %v. ", options.ID), errors.New(response.Status))
 		return nil, err
 	default:
@@ -220,7 +448,7 @@ func GetPlanetItem(options MetadataOptions, context *Context) (*model.BrokerSear
 			SimpleMsg:  "Planet Labs returned an unexpected response for this request. See log for further details.",
 			Response:   string(body),
 			URL:        inputURL,
-			HTTPStatus: response.StatusCode}
+			HTTPStatus: status}
 		err = plErr.Log(context, "")
 		return nil, err
 	}
@@ -229,6 +457,9 @@ func GetPlanetItem(options MetadataOptions, context *Context) (*model.BrokerSear
 	if err != nil {
 		return nil, err
 	}
+	if cached == nil {
+		putCachedLookup(context, cachedItem, options, body, time.Time{})
+	}
 
 	if options.Tides {
 		// Hacky way to use the multi-tides query for a single query
@@ -245,7 +476,7 @@ func GetPlanetItem(options MetadataOptions, context *Context) (*model.BrokerSear
 	return result, nil
 }
 
-// Activate retrieves and activates the analytic asset.
+// Activate retrieves and activates the selected asset, analytic by default.
 func Activate(options MetadataOptions, context *Context) (*http.Response, error) {
 	var (
 		assetMetadata *model.PlanetAssetMetadata
@@ -254,7 +485,10 @@ func Activate(options MetadataOptions, context *Context) (*http.Response, error)
 	if assetMetadata, err = GetPlanetAssets(options, context); err != nil {
 		return nil, err
 	}
-	return planetRequest(planetRequestInput{method: "POST", inputURL: assetMetadata.ActivationURL.String()}, context)
+	// The asset's status is about to change
+	deleteCachedLookup(context, cachedAssets, options)
+	// Activating an asset again has no further effect, so it can be retried
+	return planetRequest(planetRequestInput{method: "POST", inputURL: assetMetadata.ActivationURL.String(), idempotent: true}, context)
 }
 
 // planetRequest performs the request
@@ -282,17 +516,25 @@ func planetRequest(input planetRequestInput, context *Context) (*http.Response,
 	if bodyStr != "" {
 		message += ": " + bodyStr
 	}
-	if request, err = http.NewRequest(input.method, inputURL, bytes.NewBuffer(input.body)); err != nil {
-		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to make a new HTTP request for %v.", inputURL), err)
-		return nil, err
+	newRequest := func() (*http.Request, error) {
+		if request, err = http.NewRequest(input.method, inputURL, bytes.NewBuffer(input.body)); err != nil {
+			err = util.LogSimpleErr(context, fmt.Sprintf("Failed to make a new HTTP request for %v.", inputURL), err)
+			return nil, err
+		}
+		if input.contentType != "" {
+			request.Header.Set("Content-Type", input.contentType)
+		}
+		for name, values := range input.header {
+			request.Header[name] = values
+		}
+
+		request.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(context.PlanetKey+":")))
+		util.LogAudit(context, util.LogAuditInput{Actor: "planet/doRequest", Action: input.method, Actee: inputURL, Message: fmt.Sprintf("%v\nHeader:\n%#v", message, request.Header), Severity: util.INFO})
+		return request, nil
 	}
-	if input.contentType != "" {
-		request.Header.Set("Content-Type", input.contentType)
+	response, err := defaultPlanetClient.do(newRequest, context.PlanetKey, input.isIdempotent())
+	if err == nil {
+		util.LogAudit(context, util.LogAuditInput{Actor: inputURL, Action: input.method + " response", Actee: "planet/doRequest", Message: "Receiving data from Planet API", Severity: util.INFO})
 	}
-
-	request.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(context.PlanetKey+":")))
-	message = fmt.Sprintf("%v\nHeader:\n%#v", message, request.Header)
-	util.LogAudit(context, util.LogAuditInput{Actor: "planet/doRequest", Action: input.method, Actee: inputURL, Message: message, Severity: util.INFO})
-	util.LogAudit(context, util.LogAuditInput{Actor: inputURL, Action: input.method + " response", Actee: "planet/doRequest", Message: "Receiving data from Planet API", Severity: util.INFO})
-	return util.HTTPClient().Do(request)
+	return response, err
 }
@@ -15,11 +15,14 @@
 package planet
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/bf-ia-broker/model"
 	"github.com/venicegeo/bf-ia-broker/util"
 	"github.com/venicegeo/geojson-go/geojson"
 )
@@ -67,7 +70,7 @@ func TestGetScenesBoundingBox(t *testing.T) {
 	assert.Nil(t, err, "Failed creating bounding box %v", err)
 	options.Bbox = bbox
 
-	_, err = GetScenes(options, &context)
+	_, _, err = GetScenes(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 }
 
@@ -77,7 +80,7 @@ func TestGetScenesCloudCover(t *testing.T) {
 
 	options := SearchOptions{CloudCover: 0.1}
 
-	_, err := GetScenes(options, &context)
+	_, _, err := GetScenes(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 }
 
@@ -87,7 +90,7 @@ func TestGetScenesAcquiredDate(t *testing.T) {
 
 	options := SearchOptions{AcquiredDate: "2016-01-01T00:00:00Z"}
 
-	_, err := GetScenes(options, &context)
+	_, _, err := GetScenes(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 }
 
@@ -98,11 +101,157 @@ func TestGetScenesTides(t *testing.T) {
 
 	options := SearchOptions{Tides: true}
 
-	_, err := GetScenes(options, &context)
+	_, _, err := GetScenes(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 
 }
 
+func TestGetScenesSinglePage(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	options := SearchOptions{PageSize: testingSampleSearchResultSize}
+
+	fc, cursor, err := GetScenes(options, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.Len(t, fc.Features, testingSampleSearchResultSize)
+	assert.NotEmpty(t, cursor, "Expected a cursor for the next page")
+
+	pageURL, err := planetURLFromCursor(cursor, testingSampleSearchResultSize)
+	assert.Nil(t, err)
+	assert.Contains(t, pageURL, "data/v1/searches/"+testingSearchID+"/results")
+	assert.NotContains(t, pageURL, planetServer.URL)
+}
+
+func TestGetScenesFollowsNextLinks(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	options := SearchOptions{PageSize: testingSampleSearchResultSize, Limit: 10}
+
+	fc, cursor, err := GetScenes(options, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.Len(t, fc.Features, 2*testingSampleSearchResultSize)
+	assert.Empty(t, cursor, "Expected no cursor after the last page")
+}
+
+func TestGetScenesWithCursor(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	_, cursor, err := GetScenes(SearchOptions{PageSize: testingSampleSearchResultSize}, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+
+	fc, nextCursor, err := GetScenes(SearchOptions{PageSize: testingSampleSearchResultSize, Cursor: cursor}, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.Len(t, fc.Features, testingSampleSearchResultSize)
+	assert.Empty(t, nextCursor)
+}
+
+func TestGetScenesPageSortNotPaged(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+	cloudCoverSort := &model.SearchSort{Field: model.SortByCloudCover}
+
+	_, cursor, err := GetScenes(SearchOptions{PageSize: testingSampleSearchResultSize}, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.NotEmpty(t, cursor)
+
+	fc, sortedCursor, err := GetScenes(SearchOptions{PageSize: testingSampleSearchResultSize, Sort: cloudCoverSort}, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.Len(t, fc.Features, testingSampleSearchResultSize)
+	assert.Empty(t, sortedCursor, "Expected no cursor for a sort of one response")
+
+	_, _, err = GetScenes(SearchOptions{PageSize: testingSampleSearchResultSize, Sort: cloudCoverSort, Cursor: cursor}, &context)
+	if httpErr, ok := err.(util.HTTPErr); !ok {
+		t.Errorf("Expected an HTTPErr, got a %T", err)
+	} else {
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+	}
+
+	acquiredSort := &model.SearchSort{Field: model.SortByAcquiredDate, Descending: true}
+	_, _, err = GetScenes(SearchOptions{PageSize: testingSampleSearchResultSize, Sort: acquiredSort, Cursor: cursor}, &context)
+	assert.Nil(t, err, "Expected a cursor to page an acquired date sort; received: %v", err)
+}
+
+func TestGetScenesInvalidCursor(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("https://evil.localdomain/data/v1/searches/x/results")),
+		base64.RawURLEncoding.EncodeToString([]byte("data/v1/item-types/REOrthoTile/items/foobar123")),
+	} {
+		_, _, err := GetScenes(SearchOptions{Cursor: cursor}, &context)
+		if httpErr, ok := err.(util.HTTPErr); !ok {
+			t.Errorf("Expected an HTTPErr, got a %T", err)
+		} else {
+			assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+		}
+	}
+}
+
+func TestGetScenesGeometry(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+
+	aoi, err := model.NewAOI([]byte(testingAOIGeometry))
+	assert.Nil(t, err)
+	options := SearchOptions{Geometry: aoi}
+
+	fc, _, err := GetScenes(options, &context)
+	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
+	assert.Contains(t, string(testingLastQuickSearchBody), `"type":"GeometryFilter","field_name":"geometry","config":{"type":"Polygon","coordinates":[[[-1,0],[1,0],[1,20],[-1,20],[-1,0]]]}`)
+	// The AOI sticks halfway out of the first sample scene's footprint
+	assert.InDelta(t, 50.0, fc.Features[0].PropertyFloat("aoiCoverage"), 1e-9)
+}
+
+func TestSearchFilterExtended(t *testing.T) {
+	minSunElevation, maxViewAngle, maxGSD, minUsableData := 30.0, 0.0, 5.0, 0.9
+	groundControl := true
+	options := SearchOptions{
+		MinSunElevation:   &minSunElevation,
+		MaxViewAngle:      &maxViewAngle,
+		MaxGSD:            &maxGSD,
+		MinUsableData:     &minUsableData,
+		QualityCategories: []string{"standard"},
+		Instruments:       []string{"PS2", "PS2.SD"},
+		GroundControl:     &groundControl,
+	}
+
+	bytes, err := json.Marshal(searchFilter(options))
+	assert.Nil(t, err)
+	body := string(bytes)
+	assert.Contains(t, body, `{"type":"RangeFilter","field_name":"sun_elevation","config":{"gte":30}}`)
+	assert.Contains(t, body, `{"type":"RangeFilter","field_name":"view_angle","config":{"lte":0}}`)
+	assert.Contains(t, body, `{"type":"RangeFilter","field_name":"gsd","config":{"lte":5}}`)
+	assert.Contains(t, body, `{"type":"RangeFilter","field_name":"usable_data","config":{"gte":0.9}}`)
+	assert.Contains(t, body, `{"type":"StringInFilter","field_name":"quality_category","config":["standard"]}`)
+	assert.Contains(t, body, `{"type":"StringInFilter","field_name":"instrument","config":["PS2","PS2.SD"]}`)
+	assert.Contains(t, body, `{"type":"StringInFilter","field_name":"ground_control","config":["true"]}`)
+}
+
+func TestSearchFilterDefaults(t *testing.T) {
+	bytes, err := json.Marshal(searchFilter(SearchOptions{CloudCover: 0.1}))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type":"AndFilter","config":[{"type":"RangeFilter","field_name":"cloud_cover","config":{"lte":0.1}}]}`, string(bytes))
+}
+
+func TestSearchPageSizeAndLimit(t *testing.T) {
+	pageSize, limit := searchPageSizeAndLimit(SearchOptions{})
+	assert.Equal(t, maxPageSize, pageSize)
+	assert.Equal(t, maxPageSize, limit)
+
+	pageSize, limit = searchPageSizeAndLimit(SearchOptions{PageSize: 100, Limit: 50})
+	assert.Equal(t, 50, pageSize)
+	assert.Equal(t, 50, limit)
+
+	pageSize, limit = searchPageSizeAndLimit(SearchOptions{PageSize: 100, Limit: 1000})
+	assert.Equal(t, 100, pageSize)
+	assert.Equal(t, 1000, limit)
+}
+
 func TestGetMetadata(t *testing.T) {
 	planetServer, tidesServer, _ := createTestFixtures()
 	context := makeTestingContext(planetServer, tidesServer)
@@ -110,7 +259,7 @@ func TestGetMetadata(t *testing.T) {
 
 	options := SearchOptions{Tides: true}
 
-	scenes, err := GetScenes(options, &context)
+	scenes, _, err := GetScenes(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 
 	aOptions := MetadataOptions{ID: scenes.Features[0].IDStr(), Tides: true, ItemType: "REOrthoTile", ImagerySource: rapidEye}
@@ -126,7 +275,7 @@ func TestGetAsset(t *testing.T) {
 
 	options := SearchOptions{Tides: true}
 
-	scenes, err := GetScenes(options, &context)
+	scenes, _, err := GetScenes(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 
 	aOptions := MetadataOptions{ID: scenes.Features[0].IDStr(), Tides: true, ItemType: "REOrthoTile", ImagerySource: rapidEye}
@@ -232,3 +381,30 @@ func TestGetMetadataSentinelFromS3(t *testing.T) {
 	_, err := GetPlanetItem(options, &context)
 	assert.Nil(t, err, "Expected request to succeed; received: %v", err)
 }
+
+func TestGetPlanetAssetsRedactsLocations(t *testing.T) {
+	planetServer, tidesServer, _ := createTestFixtures()
+	context := makeTestingContext(planetServer, tidesServer)
+	// The download token in every location of the sample assets
+	const locationToken = "eyJhbGciOiJIUzUxMiIsInR5cCI6IkpXVCJ9"
+	malformedServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(`{"analytic":{"status":"active","location":"https://api.planet.com/data/v1/download?token=` + locationToken))
+	}))
+	defer malformedServer.Close()
+	malformedContext := context
+	malformedContext.BasePlanetURL = malformedServer.URL
+	options := MetadataOptions{ID: testingValidItemID, ItemType: "REOrthoTile", ImagerySource: rapidEye, NoCache: true}
+
+	var assetMetadata *model.PlanetAssetMetadata
+	var err, malformedErr error
+	logged := util.CaptureLogs(func() {
+		assetMetadata, err = GetPlanetAssets(options, &context)
+		_, malformedErr = GetPlanetAssets(options, &malformedContext)
+	})
+
+	assert.Nil(t, err)
+	assert.Contains(t, assetMetadata.AssetURL.String(), locationToken)
+	assert.NotNil(t, malformedErr)
+	assert.NotContains(t, logged, locationToken)
+	assert.Contains(t, logged, "token="+util.Redacted)
+}
@@ -0,0 +1,107 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// thumbnailURL and tileURL are relative to the Planet tiles URL
+const thumbnailURL = "data/v1/item-types/%v/items/%v/thumb"
+const tileURL = "data/v1/%v/%v/%v/%v/%v.png"
+
+// defaultImageCacheControl applies when Planet does not say how long an image
+// may be cached; images are private to the keys that may see them
+const defaultImageCacheControl = "private, max-age=86400"
+
+// imageHeaders are passed on from Planet's image responses
+var imageHeaders = []string{"Content-Type", "Content-Length", "Cache-Control", "ETag", "Last-Modified", "Expires"}
+
+// conditionalHeaders are passed on to Planet so it can answer 304 Not Modified
+var conditionalHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+// GetThumbnail requests the thumbnail of an item, optionally at the given
+// width; the caller must close the response body
+func GetThumbnail(itemType string, id string, width int, header http.Header, context *Context) (*http.Response, error) {
+	inputURL := tilesURL(context, fmt.Sprintf(thumbnailURL, url.PathEscape(itemType), url.PathEscape(id)))
+	if width > 0 {
+		inputURL += "?width=" + strconv.Itoa(width)
+	}
+	return doImageRequest(inputURL, "thumbnail of scene "+id, header, context)
+}
+
+// GetTile requests an XYZ map tile of an item; the caller must close the
+// response body
+func GetTile(itemType string, id string, z int, x int, y int, header http.Header, context *Context) (*http.Response, error) {
+	inputURL := tilesURL(context, fmt.Sprintf(tileURL, url.PathEscape(itemType), url.PathEscape(id), z, x, y))
+	return doImageRequest(inputURL, fmt.Sprintf("tile %d/%d/%d of scene %v", z, x, y, id), header, context)
+}
+
+func tilesURL(context *Context, path string) string {
+	return strings.TrimRight(context.BaseTilesURL, "/") + "/" + path
+}
+
+func doImageRequest(inputURL string, description string, header http.Header, context *Context) (*http.Response, error) {
+	input := planetRequestInput{method: "GET", inputURL: inputURL, header: http.Header{}}
+	for _, name := range conditionalHeaders {
+		if value := header.Get(name); value != "" {
+			input.header.Set(name, value)
+		}
+	}
+	response, err := planetRequest(input, context)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case response.StatusCode == http.StatusOK || response.StatusCode == http.StatusNotModified:
+		return response, nil
+	case (response.StatusCode == http.StatusUnauthorized) || (response.StatusCode == http.StatusForbidden):
+		response.Body.Close()
+		message := fmt.Sprintf("Specified API key is invalid or has inadequate permissions. (%v) ", response.Status)
+		util.LogAlert(context, message)
+		return nil, util.HTTPErr{Status: response.StatusCode, Message: message}
+	case (response.StatusCode >= 400) && (response.StatusCode < 500):
+		response.Body.Close()
+		message := fmt.Sprintf("Failed to get the %v: %v. ", description, response.Status)
+		util.LogAlert(context, message)
+		return nil, util.HTTPErr{Status: response.StatusCode, Message: message}
+	default:
+		response.Body.Close()
+		return nil, util.LogSimpleErr(context, fmt.Sprintf("Failed to get the %v. ", description), errors.New(response.Status))
+	}
+}
+
+// writeImageResponse streams an image from Planet with its caching headers
+func writeImageResponse(writer http.ResponseWriter, response *http.Response) error {
+	for _, name := range imageHeaders {
+		if value := response.Header.Get(name); value != "" {
+			writer.Header().Set(name, value)
+		}
+	}
+	if writer.Header().Get("Cache-Control") == "" {
+		writer.Header().Set("Cache-Control", defaultImageCacheControl)
+	}
+	writer.WriteHeader(response.StatusCode)
+	_, err := io.Copy(writer, response.Body)
+	return err
+}
@@ -1,6 +1,7 @@
 package planet
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"time"
@@ -28,6 +29,30 @@ func parseSearchResults(context *Context, body []byte) ([]model.BrokerSearchResu
 	return results, nil
 }
 
+// parseSearchPermissions extracts the _permissions of each search result, in
+// the order of the results
+func parseSearchPermissions(body []byte) [][]string {
+	var results searchResults
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil
+	}
+	permissions := make([][]string, len(results.Features))
+	for i, feature := range results.Features {
+		permissions[i] = feature.Permissions
+	}
+	return permissions
+}
+
+// parseNextLink extracts the link to the following page of search results,
+// returning an empty string if there is none
+func parseNextLink(body []byte) string {
+	var results searchResults
+	if err := json.Unmarshal(body, &results); err != nil {
+		return ""
+	}
+	return results.Links.Next
+}
+
 func planetRawBytesToFeatureCollection(context *Context, body []byte) (*geojson.FeatureCollection, error) {
 	var (
 		planetFeatureCollection *geojson.FeatureCollection
@@ -68,6 +93,7 @@ func basicBrokerResultFromPlanetFeature(feature *geojson.Feature, fileFormat mod
 		Resolution:   feature.PropertyFloat("gsd"),
 		SensorName:   feature.PropertyString("satellite_id"),
 		DataType:     feature.PropertyString("data_type"),
+		ItemType:     feature.PropertyString("item_type"),
 	}, nil
 }
 
@@ -83,38 +109,50 @@ func planetSearchBrokerResultFromFeature(feature *geojson.Feature) (*model.Broke
 }
 
 // planetAssetMetadataFromAssets constructs a PlanetAssetMetadata by extracting
-// data from a planet.Assets response container
-func planetAssetMetadataFromAssets(assets Assets) (*model.PlanetAssetMetadata, error) {
+// data about the given asset type from a planet.Assets response container
+func planetAssetMetadataFromAssets(assets Assets, assetType string) (*model.PlanetAssetMetadata, error) {
 	var err error
-	if assets.Analytic.Type == "" {
+	asset := assets[assetType]
+	if asset.Type == "" {
 		// No data means just return nil
 		return nil, nil
 	}
 
 	expiresAt := time.Time{}
-	if assets.Analytic.ExpiresAt != "" {
-		expiresAt, err = model.ParsePlanetTime(assets.Analytic.ExpiresAt)
+	if asset.ExpiresAt != "" {
+		expiresAt, err = model.ParsePlanetTime(asset.ExpiresAt)
 		if err != nil {
 			return nil, err
 		}
 	}
-	permissionsCopy := append([]string{}, assets.Analytic.Permissions...)
+	permissionsCopy := append([]string{}, asset.Permissions...)
 
-	assetURL, err := url.Parse(assets.Analytic.Location)
+	assetURL, err := url.Parse(asset.Location)
 	if err != nil {
 		return nil, err
 	}
-	activationURL, err := url.Parse(assets.Analytic.Links.Activate)
+	activationURL, err := url.Parse(asset.Links.Activate)
 	if err != nil {
 		return nil, err
 	}
 
+	summaries := make(map[string]model.PlanetAssetSummary, len(assets))
+	for name, available := range assets {
+		summaries[name] = model.PlanetAssetSummary{
+			Status:        available.Status,
+			ActivationURL: available.Links.Activate,
+			Location:      available.Location,
+			ExpiresAt:     available.ExpiresAt,
+		}
+	}
+
 	return &model.PlanetAssetMetadata{
 		AssetURL:      *assetURL,
 		ActivationURL: *activationURL,
 		ExpiresAt:     expiresAt,
 		Permissions:   permissionsCopy,
-		Status:        assets.Analytic.Status,
-		Type:          assets.Analytic.Type,
+		Status:        asset.Status,
+		Type:          asset.Type,
+		Assets:        summaries,
 	}, nil
 }
@@ -13,7 +13,7 @@ func TestPlanetAssetMetadataFromAssets_Success(t *testing.T) {
 	// Mock
 	mockExpiresAt := time.Unix(123, 0).UTC()
 	validAssets := Assets{
-		Analytic: Asset{
+		"analytic": Asset{
 			Location:    "https://example.localdomain/path/to/asset.JP2",
 			ExpiresAt:   mockExpiresAt.Format(model.StandardTimeLayout),
 			Permissions: []string{"a", "b", "c"},
@@ -26,7 +26,7 @@ func TestPlanetAssetMetadataFromAssets_Success(t *testing.T) {
 	}
 
 	// Tested code
-	data, err := planetAssetMetadataFromAssets(validAssets)
+	data, err := planetAssetMetadataFromAssets(validAssets, "analytic")
 
 	// Asserts
 	assert.Nil(t, err)
@@ -43,7 +43,7 @@ func TestPlanetAssetMetadataFromAssets_Error(t *testing.T) {
 	// Mock
 	emptyAssets := Assets{}
 	badTimeAssets := Assets{
-		Analytic: Asset{
+		"analytic": Asset{
 			Type:      "REOrthoTile",
 			Location:  "https://example.localdomain/path/to/asset.JP2",
 			ExpiresAt: "this-is-not-a-time-format",
@@ -54,8 +54,8 @@ func TestPlanetAssetMetadataFromAssets_Error(t *testing.T) {
 	}
 
 	// Tested code
-	emptyResult, emptyErr := planetAssetMetadataFromAssets(emptyAssets)
-	_, badTimeErr := planetAssetMetadataFromAssets(badTimeAssets)
+	emptyResult, emptyErr := planetAssetMetadataFromAssets(emptyAssets, "analytic")
+	_, badTimeErr := planetAssetMetadataFromAssets(badTimeAssets, "analytic")
 
 	// Asserts
 	assert.Nil(t, emptyResult)
@@ -63,6 +63,38 @@ func TestPlanetAssetMetadataFromAssets_Error(t *testing.T) {
 	assert.NotNil(t, badTimeErr)
 }
 
+func TestPlanetAssetMetadataFromAssets_AssetType(t *testing.T) {
+	// Mock
+	assets := Assets{
+		"analytic": Asset{
+			Status: "active",
+			Type:   "analytic",
+			Links:  Links{Activate: "https://example.localdomain/analytic/activate"},
+		},
+		"udm2": Asset{
+			Status: "inactive",
+			Type:   "udm2",
+			Links:  Links{Activate: "https://example.localdomain/udm2/activate"},
+		},
+	}
+
+	// Tested code
+	data, err := planetAssetMetadataFromAssets(assets, "udm2")
+	missing, missingErr := planetAssetMetadataFromAssets(assets, "analytic_sr")
+
+	// Asserts
+	assert.Nil(t, err)
+	assert.NotNil(t, data)
+	assert.Equal(t, "udm2", data.Type)
+	assert.Equal(t, "inactive", data.Status)
+	assert.Equal(t, "https://example.localdomain/udm2/activate", data.ActivationURL.String())
+	assert.Len(t, data.Assets, 2)
+	assert.Equal(t, "active", data.Assets["analytic"].Status)
+	assert.Equal(t, "https://example.localdomain/analytic/activate", data.Assets["analytic"].ActivationURL)
+	assert.Nil(t, missing)
+	assert.Nil(t, missingErr)
+}
+
 func TestBasicBrokerResultFromPlanetFeature_MissingCloudCover(t *testing.T) {
 	// Mock
 	mockAcquired := time.Unix(123, 0).UTC()
@@ -0,0 +1,63 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/venicegeo/bf-ia-broker/model"
+	"github.com/venicegeo/bf-ia-broker/util"
+	"github.com/venicegeo/geojson-go/geojson"
+)
+
+// writePagedFeatureCollection writes search results as the broker's GeoJSON,
+// linking to the next page if there is one
+func writePagedFeatureCollection(result *model.MultiBrokerResult, nextURL string) ([]byte, error) {
+	fc, err := result.GeoJSONFeatureCollection()
+	if err != nil {
+		return nil, err
+	}
+	return geojson.Write(model.NewPagedFeatureCollection(fc, nextURL))
+}
+
+// writeSTACItemCollection writes search results as a STAC ItemCollection,
+// linking to the next page if there is one
+func writeSTACItemCollection(result *model.MultiBrokerResult, nextURL string, request *http.Request) ([]byte, error) {
+	collection, err := result.STACItemCollection()
+	if err != nil {
+		return nil, err
+	}
+	if nextURL != "" {
+		collection.Links = append(collection.Links, model.STACLink{Href: util.BaseURL(request) + nextURL, Rel: "next", Type: util.STACContentType})
+	}
+	return json.Marshal(collection)
+}
+
+func writeFeature(result model.GeoJSONFeatureCreator) ([]byte, error) {
+	feature, err := result.GeoJSONFeature()
+	if err != nil {
+		return nil, err
+	}
+	return geojson.Write(feature)
+}
+
+func writeSTACItem(result model.STACItemCreator) ([]byte, error) {
+	item, err := result.STACItem()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(item)
+}
@@ -0,0 +1,119 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/venicegeo/bf-ia-broker/util"
+)
+
+// The intervals Planet can bucket stats by
+const (
+	StatsByHour  = "hour"
+	StatsByDay   = "day"
+	StatsByMonth = "month"
+)
+
+var statsIntervals = []string{StatsByHour, StatsByDay, StatsByMonth}
+
+// Stats is a time series of the number of scenes matching a search
+type Stats struct {
+	ItemTypes []string      `json:"itemTypes"`
+	Interval  string        `json:"interval"`
+	Buckets   []StatsBucket `json:"buckets"`
+	Total     int           `json:"total"`
+}
+
+// StatsBucket is the number of matching scenes acquired in the interval
+// starting at Start
+type StatsBucket struct {
+	Start string `json:"start"`
+	Count int    `json:"count"`
+}
+
+type statsResults struct {
+	Buckets []struct {
+		Count     int    `json:"count"`
+		StartTime string `json:"start_time"`
+	} `json:"buckets"`
+	Interval string `json:"interval"`
+}
+
+// GetStats counts the scenes matching the search options by acquisition
+// date, in buckets of the given interval
+func GetStats(options SearchOptions, interval string, context *Context) (*Stats, error) {
+	var (
+		err          error
+		requestBody  []byte
+		response     *http.Response
+		responseBody []byte
+		results      statsResults
+	)
+
+	req := request{ItemTypes: options.ItemTypes, Filter: searchFilter(options), Interval: interval}
+	if requestBody, err = json.Marshal(req); err != nil {
+		err = util.LogSimpleErr(context, fmt.Sprintf("Failed to marshal request object %#v.", req), err)
+		return nil, err
+	}
+	input := planetRequestInput{method: "POST", inputURL: "data/v1/stats", body: requestBody, contentType: "application/json", idempotent: true}
+	if response, err = planetRequest(input, context); err != nil {
+		logErr := util.LogSimpleErr(context, fmt.Sprintf("Failed to complete Planet API request %#v.", string(requestBody)), err)
+		// Keep the status and retry guidance of a rate limited request
+		if _, ok := err.(util.HTTPErr); ok {
+			return nil, err
+		}
+		return nil, logErr
+	}
+	defer response.Body.Close()
+	switch {
+	case (response.StatusCode == http.StatusUnauthorized) || (response.StatusCode == http.StatusForbidden):
+		message := fmt.Sprintf("Specified API key is invalid or has inadequate permissions. (%v) ", response.Status)
+		util.LogAlert(context, message)
+		return nil, util.HTTPErr{Status: response.StatusCode, Message: message}
+	case (response.StatusCode >= 400) && (response.StatusCode < 500):
+		message := fmt.Sprintf("Failed to get scene stats from Planet API: %v. ", response.Status)
+		util.LogAlert(context, message)
+		return nil, util.HTTPErr{Status: response.StatusCode, Message: message}
+	case response.StatusCode >= 500:
+		return nil, util.LogSimpleErr(context, "Failed to get scene stats from Planet API.", errors.New(response.Status))
+	default:
+		//no op
+	}
+
+	responseBody, _ = ioutil.ReadAll(response.Body)
+	if err = json.Unmarshal(responseBody, &results); err != nil {
+		plErr := util.Error{LogMsg: "Failed to Unmarshal response from Planet API stats request: " + err.Error(),
+			SimpleMsg:  "Planet Labs returned an unexpected response for this request. See log for further details.",
+			Response:   string(responseBody),
+			URL:        input.inputURL,
+			HTTPStatus: response.StatusCode}
+		return nil, plErr.Log(context, "")
+	}
+
+	stats := Stats{ItemTypes: options.ItemTypes, Interval: interval, Buckets: make([]StatsBucket, len(results.Buckets))}
+	if results.Interval != "" {
+		stats.Interval = results.Interval
+	}
+	for i, bucket := range results.Buckets {
+		stats.Buckets[i] = StatsBucket{Start: bucket.StartTime, Count: bucket.Count}
+		stats.Total += bucket.Count
+	}
+	return &stats, nil
+}
@@ -16,13 +16,19 @@ package planet
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/venicegeo/bf-ia-broker/model"
@@ -36,6 +42,14 @@ const testingValidItemIDWithBadMetadata = "badmetadata54321"
 const testingValidSentinelID = "S2A_MSIL1C_20160513T183921_N0204_R070_T11SKD_20160513T185132"
 const testingValidItemType = "REOrthoTile"
 const testingValidSceneIDWithNoMetadata = "nometadata321"
+const testingSearchID = "test-search-id"
+const testingSampleSearchResultSize = 2
+const testingActivatingItemID = "activating123"
+const testingRateLimitedItemID = "ratelimited123"
+const testingCallbackSecret = "CALLBACK_SECRET"
+const testingValidOrderID = "2b4b7c3e-0f3c-4b5e-9a43-1d1b1f6a2c9d"
+const testingImageETag = `"a1b2c3"`
+const testingAOIGeometry = `{"type":"Polygon","coordinates":[[[-1,0],[1,0],[1,20],[-1,20],[-1,0]]]}`
 
 var testingSampleSearchResult string
 var testingSampleFeatureResult string
@@ -43,10 +57,30 @@ var testingSampleSentinelFeatureResult string
 var testingSampleAssetsResult string
 var testingSampleAssetsResultBadMetadata string
 var testingSampleActivateResult string
+var testingSampleOrderQueuedResult string
+var testingSampleOrderSuccessResult string
+var testingSampleImage = []byte("\x89PNG\r\n\x1a\nnot really an image")
+var testingLastQuickSearchBody []byte
+var testingLastQuickSearchSort string
+var testingLastStatsBody []byte
+var testingLastOrderBody []byte
+
+// testingActivationPollsUntilActive counts down the asset requests for
+// testingActivatingItemID that report it as still activating
+var testingActivationPollsUntilActive int32
+
+// testingRateLimitsRemaining counts down the item requests for
+// testingRateLimitedItemID that are refused with a 429
+var testingRateLimitsRemaining int32
+
+// testingItemRequests counts the item requests the mock Planet server receives
+var testingItemRequests int32
 
 func TestMain(m *testing.M) {
 	initSampleTestingFiles()
 	disablePermissionsCheck = true
+	defaultPlanetClient.initialBackoff = time.Millisecond
+	defaultPlanetClient.maxBackoff = time.Millisecond
 	os.Exit(m.Run())
 }
 
@@ -82,12 +116,33 @@ func initSampleTestingFiles() {
 	data, err = ioutil.ReadFile("testdata/testingSampleActivateResult.json")
 	panicCheck(err)
 	testingSampleActivateResult = string(data)
+
+	data, err = ioutil.ReadFile("testdata/testingSampleOrderResult-Queued.json")
+	panicCheck(err)
+	testingSampleOrderQueuedResult = string(data)
+
+	data, err = ioutil.ReadFile("testdata/testingSampleOrderResult-Success.json")
+	panicCheck(err)
+	testingSampleOrderSuccessResult = string(data)
 }
 
 func makeDiscoverTestingURL(host string, apiKey string) string {
 	return fmt.Sprintf("%s/planet/discover/%s?PL_API_KEY=%s", host, "rapideye", apiKey)
 }
 
+func testingSearchResultWithNextLink(nextURL string) string {
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(testingSampleSearchResult), &result); err != nil {
+		panic(err)
+	}
+	result["_links"] = map[string]interface{}{"_next": nextURL}
+	data, err := json.Marshal(result)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
 func makeMetadataTestingURL(host string, apiKey string, itemType string, id string) string {
 	return fmt.Sprintf("%s/planet/%s/%s?PL_API_KEY=%s", host, itemType, id, apiKey)
 }
@@ -96,6 +151,45 @@ func makeActivateTestingURL(host string, apiKey string, itemType string, id stri
 	return fmt.Sprintf("%s/planet/activate/%s/%s?PL_API_KEY=%s", host, itemType, id, apiKey)
 }
 
+func makeActivationTestingURL(host string, apiKey string, itemType string, id string) string {
+	return fmt.Sprintf("%s/planet/activation/%s/%s?PL_API_KEY=%s", host, itemType, id, apiKey)
+}
+
+func testingAssetsResultActivating(apiURL string) string {
+	var result map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.Replace(testingSampleAssetsResult, "++API_URL_PLACEHOLDER++", apiURL, -1)), &result); err != nil {
+		panic(err)
+	}
+	result["analytic"]["status"] = "activating"
+	delete(result["analytic"], "location")
+	delete(result["analytic"], "expires_at")
+	data, err := json.Marshal(result)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+func makePreviewTestingURL(host string, apiKey string, itemType string, id string) string {
+	return fmt.Sprintf("%s/planet/preview/%s/%s?PL_API_KEY=%s", host, itemType, id, apiKey)
+}
+
+func makeTileTestingURL(host string, apiKey string, itemType string, id string, z string, x string, y string) string {
+	return fmt.Sprintf("%s/planet/tiles/%s/%s/%s/%s/%s.png?PL_API_KEY=%s", host, itemType, id, z, x, y, apiKey)
+}
+
+func makeStatsTestingURL(host string, apiKey string, itemType string) string {
+	return fmt.Sprintf("%s/planet/stats/%s?PL_API_KEY=%s", host, itemType, apiKey)
+}
+
+func makeOrderTestingURL(host string, apiKey string) string {
+	return fmt.Sprintf("%s/planet/order?PL_API_KEY=%s", host, apiKey)
+}
+
+func makeOrderStatusTestingURL(host string, apiKey string, id string) string {
+	return fmt.Sprintf("%s/planet/order/%s?PL_API_KEY=%s", host, id, apiKey)
+}
+
 func testingCheckAuthorization(authHeader string) bool {
 	authFields := strings.Fields(authHeader)
 	if len(authFields) < 2 {
@@ -122,13 +216,54 @@ func createMockPlanetAPIServer() (server *httptest.Server) {
 	router.StrictSlash(false)
 	router.HandleFunc("/data/v1/quick-search", func(writer http.ResponseWriter, request *http.Request) {
 		request.Header.Write(os.Stdout)
-		if testingCheckAuthorization(request.Header.Get("Authorization")) {
-			writer.WriteHeader(200)
-			writer.Write([]byte(testingSampleSearchResult))
+		if !testingCheckAuthorization(request.Header.Get("Authorization")) {
+			writer.WriteHeader(401)
+			writer.Write([]byte("Unauthorized"))
+			return
+		}
+		testingLastQuickSearchBody, _ = ioutil.ReadAll(request.Body)
+		testingLastQuickSearchSort = request.URL.Query().Get("_sort")
+		// The sample holds exactly one full page when paging by the sample's size
+		pageSize := request.URL.Query().Get("_page_size")
+		writer.WriteHeader(200)
+		if pageSize == strconv.Itoa(testingSampleSearchResultSize) {
+			nextURL := fmt.Sprintf("%s/data/v1/searches/%s/results?_page=%s&_page_size=%s", server.URL, testingSearchID, "page2", pageSize)
+			writer.Write([]byte(testingSearchResultWithNextLink(nextURL)))
 		} else {
+			writer.Write([]byte(testingSampleSearchResult))
+		}
+	})
+
+	router.HandleFunc("/data/v1/stats", func(writer http.ResponseWriter, request *http.Request) {
+		if !testingCheckAuthorization(request.Header.Get("Authorization")) {
+			writer.WriteHeader(401)
+			writer.Write([]byte("Unauthorized"))
+			return
+		}
+		testingLastStatsBody, _ = ioutil.ReadAll(request.Body)
+		var body struct {
+			Interval string `json:"interval"`
+		}
+		json.Unmarshal(testingLastStatsBody, &body)
+		writer.WriteHeader(200)
+		fmt.Fprintf(writer, `{"buckets":[{"count":3,"start_time":"2018-01-01T00:00:00.000000Z"},{"count":5,"start_time":"2018-01-02T00:00:00.000000Z"}],"interval":%q,"utc_offset":"+0h"}`, body.Interval)
+	})
+
+	router.HandleFunc("/data/v1/searches/{searchID}/results", func(writer http.ResponseWriter, request *http.Request) {
+		request.Header.Write(os.Stdout)
+		if !testingCheckAuthorization(request.Header.Get("Authorization")) {
 			writer.WriteHeader(401)
 			writer.Write([]byte("Unauthorized"))
+			return
 		}
+		if mux.Vars(request)["searchID"] != testingSearchID || request.URL.Query().Get("_page") != "page2" {
+			writer.WriteHeader(404)
+			writer.Write([]byte("Not found"))
+			return
+		}
+		// The second page is the last one
+		writer.WriteHeader(200)
+		writer.Write([]byte(testingSampleSearchResult))
 	})
 
 	router.HandleFunc("/data/v1/item-types/{itemType}/items/{itemID}", func(writer http.ResponseWriter, request *http.Request) {
@@ -140,6 +275,17 @@ func createMockPlanetAPIServer() (server *httptest.Server) {
 		}
 		itemType := mux.Vars(request)["itemType"]
 		itemID := mux.Vars(request)["itemID"]
+		atomic.AddInt32(&testingItemRequests, 1)
+
+		if itemID == testingRateLimitedItemID {
+			if atomic.AddInt32(&testingRateLimitsRemaining, -1) >= 0 {
+				writer.Header().Set("Retry-After", "0")
+				writer.WriteHeader(http.StatusTooManyRequests)
+				writer.Write([]byte("Too many requests"))
+				return
+			}
+			itemID = testingValidItemID
+		}
 
 		validID := itemID == testingValidItemID
 		validSentinelID := itemID == testingValidSentinelID
@@ -168,7 +314,7 @@ func createMockPlanetAPIServer() (server *httptest.Server) {
 		itemType := mux.Vars(request)["itemType"]
 		itemID := mux.Vars(request)["itemID"]
 
-		validID := itemID == testingValidItemID || itemID == testingValidSceneIDWithNoMetadata || itemID == testingValidItemIDWithBadMetadata
+		validID := itemID == testingValidItemID || itemID == testingValidSceneIDWithNoMetadata || itemID == testingValidItemIDWithBadMetadata || itemID == testingActivatingItemID
 
 		if itemType == "" || !validID {
 			writer.WriteHeader(404)
@@ -180,6 +326,8 @@ func createMockPlanetAPIServer() (server *httptest.Server) {
 
 		if itemID == testingValidSceneIDWithNoMetadata {
 			writer.Write([]byte("{}"))
+		} else if itemID == testingActivatingItemID && atomic.AddInt32(&testingActivationPollsUntilActive, -1) >= 0 {
+			writer.Write([]byte(testingAssetsResultActivating(server.URL)))
 		} else if itemID == testingValidItemIDWithBadMetadata {
 			result := strings.Replace(testingSampleAssetsResultBadMetadata, "++API_URL_PLACEHOLDER++", server.URL, -1)
 			writer.Write([]byte(result))
@@ -195,6 +343,47 @@ func createMockPlanetAPIServer() (server *httptest.Server) {
 		writer.Write([]byte(testingSampleActivateResult))
 	})
 
+	router.HandleFunc("/data/v1/item-types/{itemType}/items/{itemID}/thumb", func(writer http.ResponseWriter, request *http.Request) {
+		testingServeImage(writer, request)
+	})
+
+	router.HandleFunc("/data/v1/{itemType}/{itemID}/{z}/{x}/{y}.png", func(writer http.ResponseWriter, request *http.Request) {
+		testingServeImage(writer, request)
+	})
+
+	router.HandleFunc("/compute/ops/orders/v2", func(writer http.ResponseWriter, request *http.Request) {
+		request.Header.Write(os.Stdout)
+		if !testingCheckAuthorization(request.Header.Get("Authorization")) {
+			writer.WriteHeader(401)
+			writer.Write([]byte("Unauthorized"))
+			return
+		}
+		testingLastOrderBody, _ = ioutil.ReadAll(request.Body)
+		if request.Method != "POST" || !strings.Contains(string(testingLastOrderBody), testingValidItemID) {
+			writer.WriteHeader(400)
+			writer.Write([]byte(`{"field":{"Products":[{"message":"No access to assets"}]},"general":[{"message":"Unable to accept order"}]}`))
+			return
+		}
+		writer.WriteHeader(202)
+		writer.Write([]byte(strings.Replace(testingSampleOrderQueuedResult, "++API_URL_PLACEHOLDER++", server.URL, -1)))
+	})
+
+	router.HandleFunc("/compute/ops/orders/v2/{orderID}", func(writer http.ResponseWriter, request *http.Request) {
+		request.Header.Write(os.Stdout)
+		if !testingCheckAuthorization(request.Header.Get("Authorization")) {
+			writer.WriteHeader(401)
+			writer.Write([]byte("Unauthorized"))
+			return
+		}
+		if mux.Vars(request)["orderID"] != testingValidOrderID {
+			writer.WriteHeader(404)
+			writer.Write([]byte(`{"message":"Order not found"}`))
+			return
+		}
+		writer.WriteHeader(200)
+		writer.Write([]byte(strings.Replace(testingSampleOrderSuccessResult, "++API_URL_PLACEHOLDER++", server.URL, -1)))
+	})
+
 	router.NotFoundHandler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(404)
 		writer.Write([]byte("Route not available in mocked Planet server" + request.URL.String()))
@@ -203,15 +392,59 @@ func createMockPlanetAPIServer() (server *httptest.Server) {
 	return
 }
 
+// testingServeImage serves testingSampleImage for testingValidItemID,
+// honoring If-None-Match
+func testingServeImage(writer http.ResponseWriter, request *http.Request) {
+	if !testingCheckAuthorization(request.Header.Get("Authorization")) {
+		writer.WriteHeader(401)
+		return
+	}
+	if mux.Vars(request)["itemID"] != testingValidItemID {
+		writer.WriteHeader(404)
+		return
+	}
+	writer.Header().Set("ETag", testingImageETag)
+	if request.Header.Get("If-None-Match") == testingImageETag {
+		writer.WriteHeader(304)
+		return
+	}
+	writer.Header().Set("Content-Type", "image/png")
+	writer.Write(testingSampleImage)
+}
+
+// createRateLimitedPlanetServer refuses every request with a 429
+func createRateLimitedPlanetServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Retry-After", "0")
+		writer.WriteHeader(http.StatusTooManyRequests)
+		writer.Write([]byte("Too many requests"))
+	}))
+}
+
 // createTestRouter creates a router for testing use only,
 // providing a way mock a server for the handlers being tested
 // to live in
 func createTestRouter(planetAPIURL string, tidesAPIURL string) *mux.Router {
 	os.Setenv("PL_API_URL", planetAPIURL)
 	os.Setenv("BF_TIDE_PREDICTION_URL", tidesAPIURL)
+	os.Setenv("PL_TILES_URL", planetAPIURL)
 	router := mux.NewRouter()
+	router.Handle("/planet/discover", NewDiscoverHandler())
 	router.Handle("/planet/discover/{itemType}", NewDiscoverHandler())
-	router.Handle("/planet/activate/{itemType}/{id}", NewActivateHandler())
+	tracker := newTestingActivationTracker(planetAPIURL)
+	go tracker.Run(nil)
+	activateHandler := NewActivateHandler()
+	activateHandler.Tracker = tracker
+	router.Handle("/planet/activate/{itemType}/{id}", activateHandler)
+	router.Handle("/planet/activation/{itemType}/{id}", NewActivationStatusHandler(tracker))
+	router.Handle("/planet/itemtypes", NewItemTypesHandler())
+	router.Handle("/planet/order", NewOrderHandler())
+	router.Handle("/planet/order/{id}", NewOrderStatusHandler())
+	router.Handle("/planet/stats", NewStatsHandler())
+	router.Handle("/planet/stats/{itemType}", NewStatsHandler())
+	router.Handle("/planet/opensearch/{itemType}", NewOpenSearchHandler())
+	router.Handle("/planet/preview/{itemType}/{id}", NewPreviewHandler())
+	router.Handle("/planet/tiles/{itemType}/{id}/{z}/{x}/{y}.png", NewTileHandler())
 	router.Handle("/planet/{itemType}/{id}", NewMetadataHandler())
 	return router
 }
@@ -223,6 +456,103 @@ func createTestFixtures() (mockPlanet *httptest.Server, mockTides *httptest.Serv
 	return
 }
 
+// newTestingActivationTracker creates a tracker that keeps its activations in
+// memory, polls without delay and may call back the loopback test servers
+func newTestingActivationTracker(planetAPIURL string) *ActivationTracker {
+	tracker := NewActivationTracker(newMemoryActivationStore())
+	tracker.Context.BasePlanetURL = planetAPIURL
+	tracker.InitialBackoff = time.Millisecond
+	tracker.MaxBackoff = time.Millisecond
+	tracker.PollTick = 5 * time.Millisecond
+	tracker.CallbackSecret = testingCallbackSecret
+	tracker.CallbackInitialBackoff = time.Millisecond
+	tracker.CallbackMaxBackoff = time.Millisecond
+	tracker.CallbackMaxAttempts = 3
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	tracker.CallbackAllowedNetworks = []*net.IPNet{loopback}
+	return tracker
+}
+
+type memoryActivationStore struct {
+	mutex       sync.Mutex
+	activations map[string]Activation
+	callbacks   []ActivationCallback
+	deadLetters []CallbackDeadLetter
+	// putErr, if set, fails every PutActivation
+	putErr error
+}
+
+func newMemoryActivationStore() *memoryActivationStore {
+	return &memoryActivationStore{activations: map[string]Activation{}}
+}
+
+func (s *memoryActivationStore) GetActivation(keyHash string, itemType string, id string, assetType string) (*Activation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if activation, ok := s.activations[activationKey(keyHash, itemType, id, assetType)]; ok {
+		return &activation, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryActivationStore) PutActivation(activation Activation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.putErr != nil {
+		return s.putErr
+	}
+	s.activations[activationKey(activation.KeyHash, activation.ItemType, activation.ID, activation.AssetType)] = activation
+	return nil
+}
+
+func (s *memoryActivationStore) PutCallback(callback ActivationCallback) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+	return nil
+}
+
+func (s *memoryActivationStore) GetCallbacks() ([]ActivationCallback, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ActivationCallback{}, s.callbacks...), nil
+}
+
+func (s *memoryActivationStore) DeleteCallbacks(keyHash string, itemType string, id string, assetType string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := activationKey(keyHash, itemType, id, assetType)
+	remaining := []ActivationCallback{}
+	for _, callback := range s.callbacks {
+		if activationKey(callback.KeyHash, callback.ItemType, callback.ID, callback.AssetType) != key {
+			remaining = append(remaining, callback)
+		}
+	}
+	s.callbacks = remaining
+	return nil
+}
+
+func (s *memoryActivationStore) PutCallbackDeadLetter(letter CallbackDeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deadLetters = append(s.deadLetters, letter)
+	return nil
+}
+
+// createMockCallbackServer records the callbacks it receives, answering
+// each with the given status
+func createMockCallbackServer(status int) (*httptest.Server, chan *http.Request, chan []byte) {
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		requests <- request
+		bodies <- body
+		writer.WriteHeader(status)
+	}))
+	return server, requests, bodies
+}
+
 type mockLogContext struct{}
 
 func (ctx mockLogContext) AppName() string    { return "bf-ia-broker TESTING" }
@@ -16,8 +16,10 @@ package util
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Environment variables
@@ -28,9 +30,25 @@ const (
 	PL_API_URL                   = "PL_API_URL"
 	BF_TIDE_PREDICTION_URL       = "BF_TIDE_PREDICTION_URL"
 	PL_DISABLE_PERMISSIONS_CHECK = "PL_DISABLE_PERMISSIONS_CHECK"
+	BF_CALLBACK_SECRET           = "BF_CALLBACK_SECRET"
+	BF_CALLBACK_ALLOWED_NETWORKS = "BF_CALLBACK_ALLOWED_NETWORKS"
+	PL_MAX_CONCURRENT_REQUESTS   = "PL_MAX_CONCURRENT_REQUESTS"
+	BF_PLANET_KEYS               = "BF_PLANET_KEYS"
+	BF_PLANET_KEYS_FILE          = "BF_PLANET_KEYS_FILE"
+	BF_PLANET_KEYS_SECRET        = "BF_PLANET_KEYS_SECRET"
+	BF_REDACT_PATTERNS           = "BF_REDACT_PATTERNS"
+	PL_TILES_URL                 = "PL_TILES_URL"
+	BF_METADATA_CACHE            = "BF_METADATA_CACHE"
+	BF_METADATA_CACHE_SIZE       = "BF_METADATA_CACHE_SIZE"
+	BF_METADATA_CACHE_TTL        = "BF_METADATA_CACHE_TTL"
 )
 
 const defaultTidesURL = "https://bf-tideprediction.int.geointservices.io/tides"
+const defaultPlanetMaxConcurrentRequests = 5
+const defaultPlanetTilesURL = "https://tiles.planet.com/"
+const defaultMetadataCache = "memory"
+const defaultMetadataCacheSize = 1000
+const defaultMetadataCacheTTL = 300
 
 // GetBeachfrontDomain returns a string for the DOMAIN environment variable
 func GetBeachfrontDomain() string {
@@ -90,3 +108,110 @@ func GetTidesURL() string {
 func IsPlanetPermissionsDisabled() (bool, error) {
 	return strconv.ParseBool(os.Getenv(PL_DISABLE_PERMISSIONS_CHECK))
 }
+
+// GetCallbackSecret returns the key used to sign callback payloads, from the
+// BF_CALLBACK_SECRET environment variable; callbacks are refused without one
+func GetCallbackSecret() string {
+	secret, ok := os.LookupEnv(BF_CALLBACK_SECRET)
+	if !ok {
+		LogInfo(&BasicLogContext{}, "Did not get a callback signing secret from the environment. Activation callbacks will not be available.")
+	}
+	return secret
+}
+
+// GetCallbackAllowedNetworks returns the internal networks that callbacks may
+// be sent to, from the comma-separated CIDRs in the BF_CALLBACK_ALLOWED_NETWORKS
+// environment variable; callbacks to any other internal address are refused
+func GetCallbackAllowedNetworks() []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, value := range strings.Split(os.Getenv(BF_CALLBACK_ALLOWED_NETWORKS), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			LogAlert(&BasicLogContext{}, fmt.Sprintf("Invalid %v network of %v. Ignoring it.", BF_CALLBACK_ALLOWED_NETWORKS, value))
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// GetPlanetMaxConcurrentRequests returns the number of requests to Planet
+// that may be in flight at once for each API key, from the
+// PL_MAX_CONCURRENT_REQUESTS environment variable
+func GetPlanetMaxConcurrentRequests() int {
+	value, ok := os.LookupEnv(PL_MAX_CONCURRENT_REQUESTS)
+	if !ok {
+		return defaultPlanetMaxConcurrentRequests
+	}
+	maxRequests, err := strconv.Atoi(value)
+	if err != nil || maxRequests < 1 {
+		LogAlert(&BasicLogContext{}, fmt.Sprintf("Invalid %v value of %v. Using %d.", PL_MAX_CONCURRENT_REQUESTS, value, defaultPlanetMaxConcurrentRequests))
+		return defaultPlanetMaxConcurrentRequests
+	}
+	return maxRequests
+}
+
+// GetPlanetKeys returns the JSON key store in the BF_PLANET_KEYS
+// environment variable, if any
+func GetPlanetKeys() string {
+	return os.Getenv(BF_PLANET_KEYS)
+}
+
+// GetPlanetKeysFile returns the path of the encrypted key store in the
+// BF_PLANET_KEYS_FILE environment variable, if any
+func GetPlanetKeysFile() string {
+	return os.Getenv(BF_PLANET_KEYS_FILE)
+}
+
+// GetPlanetKeysSecret returns the base64 key that encrypts the key store
+// file, from the BF_PLANET_KEYS_SECRET environment variable
+func GetPlanetKeysSecret() string {
+	return os.Getenv(BF_PLANET_KEYS_SECRET)
+}
+
+// GetPlanetTilesURL returns the location of Planet's thumbnails and tiles,
+// from the PL_TILES_URL environment variable
+func GetPlanetTilesURL() string {
+	if tilesURL, ok := os.LookupEnv(PL_TILES_URL); ok {
+		return tilesURL
+	}
+	return defaultPlanetTilesURL
+}
+
+// GetMetadataCache returns where Planet item and asset lookups are cached:
+// memory (the default) or postgres
+func GetMetadataCache() string {
+	value, ok := os.LookupEnv(BF_METADATA_CACHE)
+	if !ok || value == "" {
+		return defaultMetadataCache
+	}
+	return value
+}
+
+// GetMetadataCacheSize returns how many lookups the in-memory cache holds
+func GetMetadataCacheSize() int {
+	return getNonNegativeInt(BF_METADATA_CACHE_SIZE, defaultMetadataCacheSize)
+}
+
+// GetMetadataCacheTTL returns how many seconds lookups are cached for; zero
+// disables the cache
+func GetMetadataCacheTTL() int {
+	return getNonNegativeInt(BF_METADATA_CACHE_TTL, defaultMetadataCacheTTL)
+}
+
+func getNonNegativeInt(name string, defaultValue int) int {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		LogAlert(&BasicLogContext{}, fmt.Sprintf("Invalid %v value of %v. Using %d.", name, value, defaultValue))
+		return defaultValue
+	}
+	return number
+}
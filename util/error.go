@@ -43,7 +43,7 @@ type Error struct {
 //
 
 // GenExtendedMsg is used to generate extended log messages from Error objects
-// for the cases where that's appropriate
+// for the cases where that's appropriate, with any secrets redacted
 func (err Error) GenExtendedMsg() string {
 	lineBreak := "\n/**************************************/\n"
 	outBody := "Http Error: " + err.LogMsg + lineBreak
@@ -60,7 +60,7 @@ func (err Error) GenExtendedMsg() string {
 		outBody += "\nHTTP Status: " + http.StatusText(err.HTTPStatus) + "\n"
 	}
 	outBody += lineBreak
-	return outBody
+	return Redact(outBody)
 }
 
 // Log is intended as the base way to generate logging information for an Error
@@ -21,8 +21,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 var httpClient *http.Client
@@ -31,6 +35,9 @@ var httpClient *http.Client
 type HTTPErr struct {
 	Status  int
 	Message string
+	// RetryAfter, if set, is the number of seconds the client should wait
+	// before trying again
+	RetryAfter int
 }
 
 func (err HTTPErr) Error() string {
@@ -248,6 +255,99 @@ func Preflight(w http.ResponseWriter, r *http.Request, lc LogContext) bool {
 	return false
 }
 
+// NextPageURL returns the URL of the given request with its "cursor" query
+// parameter replaced, suitable for use as a link to the next page of results
+func NextPageURL(r *http.Request, cursor string) string {
+	nextURL := *r.URL
+	query := nextURL.Query()
+	query.Set("cursor", cursor)
+	nextURL.RawQuery = query.Encode()
+	return LinkURI(nextURL)
+}
+
+// LinkURI returns the request URI of a URL without its credential query
+// parameters (PL_API_KEY and any other key or token), so that links returned
+// to clients never echo a secret
+func LinkURI(linkURL url.URL) string {
+	query := linkURL.Query()
+	for name := range query {
+		if isCredentialParameter(name) {
+			query.Del(name)
+		}
+	}
+	linkURL.RawQuery = query.Encode()
+	return linkURL.RequestURI()
+}
+
+func isCredentialParameter(name string) bool {
+	lower := strings.ToLower(name)
+	for _, secret := range secretParameters {
+		if lower == strings.ToLower(secret) {
+			return true
+		}
+	}
+	return strings.Contains(lower, "key") || strings.Contains(lower, "token")
+}
+
+// BaseURL returns the scheme and host the given request was sent to, as seen
+// by the client, for building absolute links; proxies may say these in the
+// X-Forwarded-Proto and X-Forwarded-Host headers
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	host := r.Host
+	if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+		host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
+// STACContentType is the media type of STAC Items and ItemCollections
+const STACContentType = "application/geo+json"
+
+// AcceptsSTAC reports whether a request asks for STAC rather than the
+// broker's own GeoJSON, with an Accept of application/geo+json; profile=stac
+func AcceptsSTAC(r *http.Request) bool {
+	for _, params := range acceptedParams(r, STACContentType) {
+		for _, profile := range strings.Fields(params["profile"]) {
+			if strings.EqualFold(profile, "stac") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// atomContentType is the media type of Atom feeds
+const atomContentType = "application/atom+xml"
+
+// AcceptsAtom reports whether a request asks for an Atom feed rather than
+// GeoJSON, with an Accept of application/atom+xml or, since OpenSearch URL
+// templates cannot set headers, with format=atom
+func AcceptsAtom(r *http.Request) bool {
+	return r.FormValue("format") == "atom" || len(acceptedParams(r, atomContentType)) > 0
+}
+
+// acceptedParams returns the parameters of each entry of the Accept header
+// naming the given media type
+func acceptedParams(r *http.Request, mediaType string) []map[string]string {
+	var result []map[string]string
+	for _, accept := range r.Header["Accept"] {
+		for _, value := range strings.Split(accept, ",") {
+			accepted, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+			if err == nil && accepted == mediaType {
+				result = append(result, params)
+			}
+		}
+	}
+	return result
+}
+
 // PrintJSON marshals the given object, turns it into a string, and feeds it to
 // the given ResponseWriter.
 func PrintJSON(w http.ResponseWriter, output interface{}, httpStatus int) []byte {
@@ -271,3 +371,12 @@ func HTTPError(r *http.Request, w http.ResponseWriter, context LogContext, messa
 	}
 	http.Error(w, message, status)
 }
+
+// HTTPErrResponse writes an HTTPErr as an error response, telling the client
+// when to retry if the error says
+func HTTPErrResponse(r *http.Request, w http.ResponseWriter, context LogContext, err HTTPErr) {
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(err.RetryAfter))
+	}
+	HTTPError(r, w, context, err.Message, err.Status)
+}
@@ -19,6 +19,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -168,6 +170,18 @@ func TestHTTPError(t *testing.T) {
 	LogInfo(lc, writer.OutputString)
 }
 
+func TestHTTPErrResponse(t *testing.T) {
+	request := httptest.NewRequest("GET", "foo://bar.bas", nil)
+	recorder := httptest.NewRecorder()
+	HTTPErrResponse(request, recorder, &BasicLogContext{}, HTTPErr{Status: http.StatusServiceUnavailable, Message: "Busy.", RetryAfter: 30})
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 but got %v", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected Retry-After of 30 but got %v", recorder.Header().Get("Retry-After"))
+	}
+}
+
 func TestReadBodyJSON(t *testing.T) {
 
 	bStrings := []string{``, `b`, `{}`, `{"PercentComplete":50}`}
@@ -209,3 +223,79 @@ func TestUtils(t *testing.T) {
 	t.Log(SliceToCommaSep(uuidSlice))
 
 }
+
+func TestNextPageURL(t *testing.T) {
+	request := httptest.NewRequest("GET", "http://broker.localdomain/planet/discover/rapideye?bbox=1,2,3,4&cursor=old", nil)
+	nextURL := NextPageURL(request, "new")
+	if nextURL != "/planet/discover/rapideye?bbox=1%2C2%2C3%2C4&cursor=new" {
+		t.Errorf("NextPageURL: unexpected URL %v", nextURL)
+	}
+}
+
+func TestNextPageURLDropsCredentials(t *testing.T) {
+	request := httptest.NewRequest("GET", "http://broker.localdomain/planet/discover/rapideye?PL_API_KEY=secret&api-key=secret&access_token=secret&bbox=1,2,3,4", nil)
+	nextURL := NextPageURL(request, "new")
+	if nextURL != "/planet/discover/rapideye?bbox=1%2C2%2C3%2C4&cursor=new" {
+		t.Errorf("NextPageURL: unexpected URL %v", nextURL)
+	}
+	if strings.Contains(nextURL, "secret") {
+		t.Errorf("NextPageURL: credentials echoed in %v", nextURL)
+	}
+}
+
+func TestLinkURI(t *testing.T) {
+	linkURL, _ := url.Parse("/stac/search?Token=secret&apikey=secret&limit=10")
+	if uri := LinkURI(*linkURL); uri != "/stac/search?limit=10" {
+		t.Errorf("LinkURI: unexpected URI %v", uri)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	request := httptest.NewRequest("GET", "http://broker.example.com/stac?limit=1", nil)
+	if baseURL := BaseURL(request); baseURL != "http://broker.example.com" {
+		t.Errorf("BaseURL: unexpected URL %v", baseURL)
+	}
+
+	request.Header.Set("X-Forwarded-Proto", "https")
+	request.Header.Set("X-Forwarded-Host", "public.example.com, broker.example.com")
+	if baseURL := BaseURL(request); baseURL != "https://public.example.com" {
+		t.Errorf("BaseURL: unexpected URL %v with forwarding headers", baseURL)
+	}
+}
+
+func TestAcceptsSTAC(t *testing.T) {
+	accepts := map[string]bool{
+		"":                                    false,
+		"application/json":                    false,
+		"application/geo+json":                false,
+		"application/geo+json; profile=stac":  true,
+		`application/geo+json;profile="stac"`: true,
+		"application/json, application/geo+json; profile=stac;q=0.9": true,
+		"application/json; profile=stac":                             false,
+	}
+	for accept, expected := range accepts {
+		request := httptest.NewRequest("GET", "http://broker.example.com/planet/discover/rapideye", nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		if AcceptsSTAC(request) != expected {
+			t.Errorf("AcceptsSTAC: expected %v for Accept: %v", expected, accept)
+		}
+	}
+}
+
+func TestAcceptsAtom(t *testing.T) {
+	request := httptest.NewRequest("GET", "http://broker.example.com/planet/discover/rapideye", nil)
+	if AcceptsAtom(request) {
+		t.Errorf("AcceptsAtom: unexpected Atom without an Accept header")
+	}
+	request.Header.Set("Accept", "application/json, application/atom+xml;q=0.5")
+	if !AcceptsAtom(request) {
+		t.Errorf("AcceptsAtom: expected Atom for Accept: %v", request.Header.Get("Accept"))
+	}
+
+	request = httptest.NewRequest("GET", "http://broker.example.com/planet/discover/rapideye?format=atom", nil)
+	if !AcceptsAtom(request) {
+		t.Errorf("AcceptsAtom: expected Atom with format=atom")
+	}
+}
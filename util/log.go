@@ -54,8 +54,8 @@ func init() {
 	}
 }
 
-// logMessage receives a string to put to the logs.  It formats it correctly
-// and puts it in the right place.  This function exists partially in order
+// logMessage receives a string to put to the logs.  It formats it correctly,
+// redacts any secrets, and puts it in the right place.  This function exists partially in order
 // to simplify the task of modifying log behavior in the future.  Note that
 // logMessage will panic if no baseLogFunc has been set.  This is a feature,
 // not a bug.  It helps you identify threads that have not been properly
@@ -70,7 +70,7 @@ func logMessage(lc LogContext, prefix, message string) {
 		}
 	}
 	outMsg := fmt.Sprintf("%s - [%s:%s %s %d] %s", prefix, lc.AppName(), lc.SessionID(), file, line, message)
-	logFunc(outMsg)
+	logFunc(Redact(outMsg))
 }
 
 // LogInfo posts a logMessage call for standard, non-error messages.  The
@@ -116,14 +116,14 @@ type LogAuditInput struct {
 // LogAudit posts a logMessage call for messages that are generated to
 // conform to Audit requirements.  This function is intended to maintain
 // uniformity of appearance and behavior, and also to ease maintainability
-// when routing requirements change.
+// when routing requirements change.  Secrets are redacted from every field.
 func LogAudit(lc LogContext, input LogAuditInput) {
 	time := time.Now().UTC().Format("2006-01-02T15:04:05.999Z")
 
 	hostName, _ := os.Hostname()
 	outStr := fmt.Sprintf(`<%d>1 %s %s %s - ID%d [pzaudit@48851 actor="%s" action="%s" actee="%s"] %s`,
 		8+input.Severity, time, hostName, lc.AppName(), os.Getpid(), input.Actor, input.Action, input.Actee, input.Message)
-	logFunc(outStr)
+	logFunc(Redact(outStr))
 }
 
 // LogAuditResponse is LogAudit for those cases where it needs to include an HTTP response
@@ -0,0 +1,138 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces each secret scrubbed from the logs
+const Redacted = "[REDACTED]"
+
+// secretHeaders are the headers whose values are never logged
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Bf-Signature"}
+
+// secretParameters are the query parameters whose values are never logged
+var secretParameters = []string{"PL_API_KEY", "api_key", "apikey", "access_token", "token"}
+
+var (
+	// A header as dumped with %#v, e.g. "Authorization":[]string{"Basic ..."}
+	secretHeaderDumpPattern = regexp.MustCompile(`(?i)("(?:` + quoteAll(secretHeaders) + `)"\s*:\s*\[\]string\{)[^}]*(\})`)
+	// A header as written on the wire or in JSON, e.g. Authorization: Basic ...
+	secretHeaderPattern    = regexp.MustCompile(`(?i)("?\b(?:` + quoteAll(secretHeaders) + `)"?\s*:\s*)("[^"]*"|[^\[\r\n"][^\r\n]*)`)
+	secretParameterPattern = regexp.MustCompile(`(?i)(\b(?:` + quoteAll(secretParameters) + `)=)[^&#\s"']*`)
+	// Credentials after an authorization scheme, wherever they appear
+	credentialsPattern = regexp.MustCompile(`(?i)(\b(?:Basic|Bearer|api-key)\s+)[A-Za-z0-9+/=._~-]{8,}`)
+)
+
+var (
+	redactionMutex    sync.RWMutex
+	redactionPatterns []*regexp.Regexp
+	redactionLoaded   bool
+)
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = regexp.QuoteMeta(value)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// Redact scrubs secrets from a string before it is logged: the values of
+// secret headers and query parameters, credentials following an
+// authorization scheme, and anything matching the patterns configured in
+// BF_REDACT_PATTERNS or with SetRedactionPatterns
+func Redact(message string) string {
+	message = secretHeaderDumpPattern.ReplaceAllString(message, `${1}"`+Redacted+`"${2}`)
+	message = secretHeaderPattern.ReplaceAllString(message, "${1}"+Redacted)
+	message = secretParameterPattern.ReplaceAllString(message, "${1}"+Redacted)
+	message = credentialsPattern.ReplaceAllString(message, "${1}"+Redacted)
+	for _, pattern := range configuredRedactionPatterns() {
+		message = pattern.ReplaceAllString(message, Redacted)
+	}
+	return message
+}
+
+// SetRedactionPatterns replaces the configured patterns with the given
+// regular expressions
+func SetRedactionPatterns(patterns []string) error {
+	compiled, err := compileRedactionPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	redactionMutex.Lock()
+	defer redactionMutex.Unlock()
+	redactionPatterns = compiled
+	redactionLoaded = true
+	return nil
+}
+
+func compileRedactionPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			// The pattern itself may be a secret, so it is not repeated
+			return nil, fmt.Errorf("Redaction pattern %d is invalid", i+1)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// configuredRedactionPatterns loads BF_REDACT_PATTERNS, a JSON array of
+// regular expressions, the first time it is needed
+func configuredRedactionPatterns() []*regexp.Regexp {
+	redactionMutex.RLock()
+	if redactionLoaded {
+		defer redactionMutex.RUnlock()
+		return redactionPatterns
+	}
+	redactionMutex.RUnlock()
+
+	redactionMutex.Lock()
+	defer redactionMutex.Unlock()
+	if !redactionLoaded {
+		redactionPatterns = loadRedactionPatterns()
+		redactionLoaded = true
+	}
+	return redactionPatterns
+}
+
+// loadRedactionPatterns reports problems through logFunc directly, since the
+// other logging functions redact through it
+func loadRedactionPatterns() []*regexp.Regexp {
+	value, ok := os.LookupEnv(BF_REDACT_PATTERNS)
+	if !ok || value == "" {
+		return nil
+	}
+	var patterns []string
+	if err := json.Unmarshal([]byte(value), &patterns); err != nil {
+		logFunc(fmt.Sprintf("ALERT - %v is not a JSON array of regular expressions; it is ignored.", BF_REDACT_PATTERNS))
+		return nil
+	}
+	compiled, err := compileRedactionPatterns(patterns)
+	if err != nil {
+		logFunc(fmt.Sprintf("ALERT - %v: %v; the patterns are ignored.", BF_REDACT_PATTERNS, err))
+		return nil
+	}
+	return compiled
+}
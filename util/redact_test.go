@@ -0,0 +1,101 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testingSecretKey = "0123456789abcdef0123456789abcdef"
+
+func assertRedacted(t *testing.T, logged string, secrets ...string) {
+	for _, secret := range secrets {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Secret %v was logged in:\n%v", secret, logged)
+		}
+	}
+	if !strings.Contains(logged, Redacted) {
+		t.Errorf("Expected a redaction in:\n%v", logged)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	basic := base64.StdEncoding.EncodeToString([]byte(testingSecretKey + ":"))
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+basic)
+	header.Set("Content-Type", "application/json")
+
+	cases := []string{
+		fmt.Sprintf("Requesting data\nHeader:\n%#v", header),
+		"Authorization: Basic " + basic,
+		`{"Authorization":"api-key ` + testingSecretKey + `"}`,
+		"http://localhost:8080/planet/discover/rapideye?PL_API_KEY=" + testingSecretKey + "&cloudCover=10",
+		"Use Bearer " + testingSecretKey,
+	}
+	for _, message := range cases {
+		redacted := Redact(message)
+		assertRedacted(t, redacted, testingSecretKey, basic)
+	}
+
+	// Everything else is left alone
+	dump := Redact(fmt.Sprintf("%#v", header))
+	if !strings.Contains(dump, `"Content-Type":[]string{"application/json"}`) {
+		t.Errorf("Expected other headers to be kept in %v", dump)
+	}
+	url := Redact("/planet/discover/rapideye?PL_API_KEY=" + testingSecretKey + "&cloudCover=10")
+	if url != "/planet/discover/rapideye?PL_API_KEY="+Redacted+"&cloudCover=10" {
+		t.Errorf("Unexpected redaction of URL: %v", url)
+	}
+	if message := "Nothing secret here"; Redact(message) != message {
+		t.Errorf("Unexpected redaction of %v", message)
+	}
+}
+
+func TestRedactConfiguredPatterns(t *testing.T) {
+	defer SetRedactionPatterns(nil)
+	if err := SetRedactionPatterns([]string{`PLAK[0-9a-f]+`}); err != nil {
+		t.Fatal(err)
+	}
+	assertRedacted(t, Redact("key PLAK"+testingSecretKey+" in a message"), testingSecretKey)
+
+	if err := SetRedactionPatterns([]string{`(`}); err == nil {
+		t.Error("Expected an invalid pattern to be refused")
+	}
+}
+
+func TestLoggingRedactsSecrets(t *testing.T) {
+	context := &BasicLogContext{}
+	url := "http://localhost/planet/discover/rapideye?PL_API_KEY=" + testingSecretKey
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(testingSecretKey+":")))
+
+	logged := CaptureLogs(func() {
+		LogInfo(context, fmt.Sprintf("Header:\n%#v", header))
+		LogAlert(context, "Authorization: api-key "+testingSecretKey)
+		LogSimpleErr(context, "Failed to request "+url+". ", errors.New("Bearer "+testingSecretKey))
+		LogAudit(context, LogAuditInput{Actor: "anon user", Action: "GET", Actee: url, Message: url, Severity: INFO})
+		plErr := Error{LogMsg: "Failed", Request: url, Response: "Authorization: Basic " + testingSecretKey, URL: url}
+		plErr.Log(context, "")
+	})
+	assertRedacted(t, logged, testingSecretKey, base64.StdEncoding.EncodeToString([]byte(testingSecretKey+":")))
+
+	extended := Error{LogMsg: "Failed", URL: url}.GenExtendedMsg()
+	assertRedacted(t, extended, testingSecretKey)
+}
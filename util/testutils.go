@@ -18,7 +18,10 @@ import (
 	"bytes"
 	"crypto/tls"
 	"io"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 type testRC struct{ io.Reader }
@@ -86,6 +89,33 @@ func (t stringSliceMockTransport) RoundTrip(req *http.Request) (*http.Response,
 	return response, nil
 }
 
+// CaptureLogs is a utility function for testing purposes. It returns
+// everything logged while run executes, both through this package and
+// through the standard library's log package.
+func CaptureLogs(run func()) string {
+	var (
+		mutex  sync.Mutex
+		logged []string
+		std    bytes.Buffer
+	)
+	oldLogFunc := logFunc
+	logFunc = func(logString string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		logged = append(logged, logString)
+	}
+	oldOutput := log.Writer()
+	log.SetOutput(&std)
+	defer func() {
+		logFunc = oldLogFunc
+		log.SetOutput(oldOutput)
+	}()
+	run()
+	mutex.Lock()
+	defer mutex.Unlock()
+	return strings.Join(append(logged, std.String()), "\n")
+}
+
 // GetMockResponseWriter returns a simple fake response writer object
 // for testing purposes
 func GetMockResponseWriter() (*FakeRespWriter, string, int) {